@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/mdsavian/budget-tracker-api/internal/backup"
+	"github.com/mdsavian/budget-tracker-api/internal/storage"
+	"github.com/mdsavian/budget-tracker-api/internal/types"
+)
+
+// Backup writes the dump to the given file, gzipped when its name ends with .gz
+func Backup(args []string, store *storage.PostgresStore) {
+	flags := flag.NewFlagSet("backup", flag.ExitOnError)
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		log.Fatal("usage: backup <file.json[.gz]>")
+	}
+	path := flags.Arg(0)
+
+	dump, err := backup.Create(store)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer file.Close()
+
+	if err := backup.Write(file, dump, strings.HasSuffix(path, ".gz")); err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Printf("Backup written to %s: %s\n", path, sectionCounts(dump))
+}
+
+func Restore(args []string, store *storage.PostgresStore) {
+	flags := flag.NewFlagSet("restore", flag.ExitOnError)
+	forceFlag := flags.Bool("force", false, "replace the existing data")
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		log.Fatal("usage: restore [-force] <file.json[.gz]>")
+	}
+
+	file, err := os.Open(flags.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer file.Close()
+
+	dump, err := backup.Read(file)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := backup.Restore(store, dump, *forceFlag); err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Printf("Restored %s\n", sectionCounts(dump))
+}
+
+func sectionCounts(dump *types.Backup) string {
+	counts := []string{}
+	for _, section := range backup.Sections(dump) {
+		counts = append(counts, fmt.Sprintf("%d %s", section.Count, section.Name))
+	}
+	return strings.Join(counts, ", ")
+}
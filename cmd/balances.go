@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"log"
+
+	apiserver "github.com/mdsavian/budget-tracker-api/internal/api-server"
+	"github.com/mdsavian/budget-tracker-api/internal/storage"
+)
+
+// RepairBalances recomputes the account balances from their opening balance and
+// fulfilled transactions, overwriting the stored balances that drifted
+func RepairBalances(args []string, store *storage.PostgresStore) {
+	flags := flag.NewFlagSet("repair-balances", flag.ExitOnError)
+	dryRunFlag := flags.Bool("dry-run", false, "print the drifted balances without fixing them")
+	flags.Parse(args)
+
+	audits, err := store.GetAccountBalanceAudits()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// the repairs are recorded in the audit log without a user, as they run outside a session
+	auditedStore := apiserver.NewAuditStore(store)
+	drifted := 0
+	for _, audit := range audits {
+		if audit.Drift == 0 {
+			continue
+		}
+		drifted++
+
+		fmt.Printf("  %s: stored %.2f, computed %.2f, drift %.2f\n",
+			audit.Account, audit.StoredBalance, audit.ComputedBalance, audit.Drift)
+
+		if *dryRunFlag {
+			continue
+		}
+		if err := auditedStore.SetAccountBalance(audit.AccountID, audit.ComputedBalance); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if *dryRunFlag {
+		fmt.Printf("Dry run: %d of %d accounts would be repaired\n", drifted, len(audits))
+		return
+	}
+	fmt.Printf("Repaired %d of %d accounts\n", drifted, len(audits))
+}
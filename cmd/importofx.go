@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/mdsavian/budget-tracker-api/internal/importer"
+	"github.com/mdsavian/budget-tracker-api/internal/storage"
+	"github.com/mdsavian/budget-tracker-api/internal/types"
+)
+
+func ImportOFX(args []string, postgresStore *storage.PostgresStore) {
+	flags := flag.NewFlagSet("ofx", flag.ExitOnError)
+	workspaceFlag := flags.String("workspace", "", "id of the workspace receiving the imported transactions")
+	accountFlag := flags.String("account", "", "id of the account receiving the statement transactions")
+	categoryFlag := flags.String("category", "outros", "category description used for the imported transactions")
+	dryRunFlag := flags.Bool("dry-run", false, "print what would be created, matched or skipped without writing anything")
+	flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		log.Fatal("usage: ofx -workspace <id> -account <id> [-category <description>] <file.ofx>...")
+	}
+
+	store := workspaceStore(*workspaceFlag, postgresStore)
+
+	accountID, err := uuid.Parse(*accountFlag)
+	if err != nil {
+		log.Fatal("invalid account id ", *accountFlag)
+	}
+
+	if _, err := store.GetAccountByID(accountID); err != nil {
+		log.Fatal(err)
+	}
+
+	// a dry run must not create the category, a missing one is only reported as new
+	category := &types.Category{ID: uuid.Must(uuid.NewV7())}
+	if *dryRunFlag {
+		if existing, err := store.GetCategoryByDescription(strings.ToLower(*categoryFlag)); err == nil {
+			category = existing
+		}
+	} else {
+		category, err = importer.GetOrCreateCategory(strings.ToLower(*categoryFlag), store)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	for _, path := range flags.Args() {
+		fmt.Println("Importing file name:", path)
+
+		file, err := os.Open(path)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		lines, err := importer.ParseOFX(file)
+		file.Close()
+		if err != nil {
+			log.Fatal("error parsing ofx file ", path, err)
+		}
+
+		report := importer.NewReport(*dryRunFlag)
+		if err := importer.ImportStatement(store, filepath.Base(path), lines, accountID, category.ID, report); err != nil {
+			log.Fatal("error importing ofx file ", path, err)
+		}
+
+		printReport(report)
+	}
+}
@@ -1,269 +1,197 @@
 package cmd
 
 import (
-	"database/sql"
+	"flag"
 	"fmt"
 	"log"
 	"os"
 	"path/filepath"
-	"strconv"
 	"strings"
-	"time"
 
-	"github.com/google/uuid"
+	"github.com/mdsavian/budget-tracker-api/internal/importer"
 	"github.com/mdsavian/budget-tracker-api/internal/storage"
 	"github.com/mdsavian/budget-tracker-api/internal/types"
-	"github.com/thedatashed/xlsxreader"
 )
 
-type Transaction struct {
-	CreditCard      bool
-	TransactionType string
-	Account         string
-	Date            time.Time
-	Description     string
-	Category        string
-	Amount          float32
-	FulFilled       bool
-}
-
-func ImportData(path string, store *storage.PostgresStore) {
-
-	err := filepath.Walk(path, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
+// accountMappingFlag parses repeated -account "<column value>=<name>[:<account type>]" flags
+type accountMappingFlag map[string]importer.XlsxAccount
 
-		if !info.IsDir() && filepath.Ext(path) == ".xlsx" {
-			fmt.Println("Importing file name:", info.Name())
+func (a accountMappingFlag) String() string {
+	return fmt.Sprint(map[string]importer.XlsxAccount(a))
+}
 
-			readXlsx(path, store)
-		}
-		return nil
-	})
+func (a accountMappingFlag) Set(value string) error {
+	columnValue, account, found := strings.Cut(value, "=")
+	if !found || columnValue == "" || account == "" {
+		return fmt.Errorf("expected <column value>=<name>[:<account type>], got %q", value)
+	}
 
-	if err != nil {
-		fmt.Println("Error:", err)
+	name, accountType, found := strings.Cut(account, ":")
+	if !found {
+		accountType = columnValue
 	}
 
+	a[columnValue] = importer.XlsxAccount{Name: name, AccountType: types.AccountType(accountType)}
+	return nil
 }
 
-func readXlsx(path string, store *storage.PostgresStore) {
-	// Create an instance of the reader by opening a target fileP
-	xl, _ := xlsxreader.OpenFile(path)
-
-	// Ensure the file reader is closed once utilised
-	defer xl.Close()
-	var lastValidDate time.Time
-
-	var transactions []*Transaction
-	// Iterate on the rows of data
-	for row := range xl.ReadRows(xl.Sheets[2]) {
-		// ignore headers
-		if row.Index == 1 {
-			continue
-		}
-		log.Println("reading row = ", row.Index)
-
-		cells := row.Cells
-
-		if cells[0].Column != "A" || len(cells) < 7 {
-			log.Println("ignoring row = ", row.Index)
-			continue
-		}
+func ImportXlsx(args []string, store *storage.PostgresStore) {
+	flags := flag.NewFlagSet("xlsx", flag.ExitOnError)
+	workspaceFlag := flags.String("workspace", "", "id of the workspace receiving the imported transactions")
+	configFlag := flags.String("config", "", "json file with the sheet, column, account and card mappings")
+	sheetFlag := flags.String("sheet", "", "name of the sheet to read")
+	sheetIndexFlag := flags.Int("sheet-index", 0, "index of the sheet to read when -sheet is not set")
+	headerRowsFlag := flags.Int("header-rows", 0, "number of header rows to ignore")
+	columnsFlag := flags.String("columns", "", "column letters, e.g. date=D,amount=G,description=E")
+	cardFlag := flags.String("card", "", "name of the credit card receiving the card transactions")
+	cardClosingDayFlag := flags.Int("card-closing-day", 0, "closing day used when the credit card is created")
+	cardDueDayFlag := flags.Int("card-due-day", 0, "due day used when the credit card is created")
+	dryRunFlag := flags.Bool("dry-run", false, "print what would be created, matched or skipped without writing anything")
+	accounts := accountMappingFlag{}
+	flags.Var(accounts, "account", "account mapping <column value>=<name>[:<account type>], can be repeated")
+	flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		log.Fatal("usage: xlsx -workspace <id> [-config file.json] [flags] <path>")
+	}
+	workspace := workspaceStore(*workspaceFlag, store)
 
-		dateString := cells[3].Value
-		notFoundDate := false
-		date, err := time.Parse("2006-01-02", dateString)
+	config := importer.DefaultXlsxConfig()
+	if *configFlag != "" {
+		var err error
+		config, err = importer.LoadXlsxConfig(*configFlag)
 		if err != nil {
-			notFoundDate = true
-			date = time.Date(lastValidDate.Year(), lastValidDate.Month(), 1, 0, 0, 0, 0, time.UTC)
-			log.Print("not found date, new date: ", date)
-		}
-		lastValidDate = date
-
-		index := 6
-		if notFoundDate {
-			index = 5
-		}
-
-		var amount float64 = 0
-		if cells[index].Type == xlsxreader.TypeNumerical {
-			amountString := cells[index].Value
-			amount, err = strconv.ParseFloat(amountString, 32)
-			if err != nil {
-				log.Fatal("error parsing amount ", amountString)
-			}
-		}
-
-		if amount == 0 || amount < 0 {
-			log.Println("amount is <= 0, ignoring row = ", row.Index)
-			continue
+			log.Fatal(err)
 		}
+	}
 
-		index = 4
-		if notFoundDate {
-			index = 3
+	var flagErr error
+	flags.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "sheet":
+			config.Sheet = *sheetFlag
+		case "sheet-index":
+			config.SheetIndex = *sheetIndexFlag
+		case "header-rows":
+			config.HeaderRows = *headerRowsFlag
+		case "columns":
+			flagErr = setXlsxColumns(&config.Columns, *columnsFlag)
+		case "card":
+			config.CreditCard.Name = *cardFlag
+		case "card-closing-day":
+			config.CreditCard.ClosingDay = *cardClosingDayFlag
+		case "card-due-day":
+			config.CreditCard.DueDay = *cardDueDayFlag
+		case "account":
+			config.Accounts = accounts
 		}
+	})
+	if flagErr != nil {
+		log.Fatal(flagErr)
+	}
 
-		if cells[index+3].Value != "Sim" {
-			log.Println("not fulfilled, ignoring row = ", row.Index)
-			continue
-		}
+	for _, path := range flags.Args() {
+		ImportData(path, config, *dryRunFlag, workspace)
+	}
+}
 
-		transaction := &Transaction{
-			CreditCard:      cells[0].Value == "Sim",
-			TransactionType: cells[1].Value,
-			Account:         cells[2].Value,
-			Date:            date,
-			Description:     cells[index].Value,
-			Category:        cells[index+1].Value,
-			Amount:          float32(amount),
-			FulFilled:       cells[index+3].Value == "Sim",
+func setXlsxColumns(columns *importer.XlsxColumns, value string) error {
+	for _, mapping := range strings.Split(value, ",") {
+		field, column, found := strings.Cut(strings.TrimSpace(mapping), "=")
+		if !found {
+			return fmt.Errorf("invalid column mapping %q", mapping)
+		}
+
+		column = strings.ToUpper(column)
+		switch field {
+		case "creditCard":
+			columns.CreditCard = column
+		case "transactionType":
+			columns.TransactionType = column
+		case "account":
+			columns.Account = column
+		case "date":
+			columns.Date = column
+		case "description":
+			columns.Description = column
+		case "category":
+			columns.Category = column
+		case "amount":
+			columns.Amount = column
+		case "fulfilled":
+			columns.Fulfilled = column
+		default:
+			return fmt.Errorf("unknown column field %q", field)
 		}
-		transactions = append(transactions, transaction)
 	}
-
-	persistData(transactions, store)
+	return nil
 }
 
-func persistData(transactions []*Transaction, store *storage.PostgresStore) {
-	accounts, _ := store.GetAccounts()
-	categories, _ := store.GetCategory()
-
-	creditCard := getOrCreateCreditCard("Itaú", store)
-
-	for _, transaction := range transactions {
-		var account *types.Account
-		accountName, accountType := mapTransactionAccount(transaction.Account)
-		// search first on array avoiding calling the db for each transaction
-		if len(accounts) > 0 {
-			for _, acc := range accounts {
-				if acc.Name == accountName && acc.AccountType == accountType {
-					account = acc
-					break
-				}
-			}
-		}
-		if account == nil {
-			account = getOrCreateAccountOnDB(accountName, accountType, store)
-			accounts = append(accounts, account)
-		}
+// ImportDefaultXlsx imports the xlsx files under path with the default layout into the workspace
+func ImportDefaultXlsx(path, workspaceID string, store *storage.PostgresStore) {
+	ImportData(path, importer.DefaultXlsxConfig(), false, workspaceStore(workspaceID, store))
+}
 
-		var category *types.Category
-		if len(categories) > 0 {
-			for _, ctg := range categories {
-				if ctg.Description == transaction.Category {
-					category = ctg
-					break
-				}
-			}
-		}
-		if category == nil {
-			category = getOrCreateCategory(strings.ToLower(transaction.Category), store)
-			categories = append(categories, category)
+// ImportData imports the xlsx files under path through a store kept to one workspace
+func ImportData(path string, config *importer.XlsxConfig, dryRun bool, store importer.XlsxStorage) {
+	err := filepath.Walk(path, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
 		}
 
-		newTransaction := &types.Transaction{
-			ID:              uuid.Must(uuid.NewV7()),
-			AccountID:       account.ID,
-			CategoryID:      category.ID,
-			TransactionType: types.TransactionType(transaction.TransactionType),
-			Date:            transaction.Date,
-			Description:     transaction.Description,
-			Amount:          transaction.Amount,
-			Fulfilled:       transaction.FulFilled,
-			CreatedAt:       time.Now().UTC(),
-			UpdatedAt:       time.Now().UTC(),
-		}
+		if !info.IsDir() && filepath.Ext(path) == ".xlsx" {
+			fmt.Println("Importing file name:", info.Name())
 
-		if transaction.CreditCard {
-			newTransaction.CreditCardID = &creditCard.ID
+			report, err := readXlsx(path, config, dryRun, store)
+			if err != nil {
+				fmt.Println("Error importing file", info.Name(), err)
+				return nil
+			}
+			printReport(report)
 		}
+		return nil
+	})
 
-		store.CreateTransaction(newTransaction)
+	if err != nil {
+		fmt.Println("Error:", err)
 	}
 
 }
 
-func getOrCreateCategory(description string, store *storage.PostgresStore) *types.Category {
-	category, err := store.GetCategoryByDescription(description)
-	if err != nil && err != sql.ErrNoRows {
-		log.Fatal("error searching for category ", description, err)
+func readXlsx(path string, config *importer.XlsxConfig, dryRun bool, store importer.XlsxStorage) (*importer.Report, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
 	}
 
-	if category != nil {
-		return category
+	fileName := filepath.Base(path)
+	report := importer.NewReport(dryRun)
+	rows, err := importer.ParseXlsx(fileName, data, config, report)
+	if err != nil {
+		return nil, err
 	}
 
-	newCategory := &types.Category{
-		ID:          uuid.Must(uuid.NewV7()),
-		Description: description,
-		CreatedAt:   time.Now().UTC(),
-		UpdatedAt:   time.Now().UTC(),
-	}
-	err = store.CreateCategory(newCategory)
-	if err != nil {
-		log.Fatal("error creating category ", description)
+	if err := importer.ImportXlsx(store, fileName, rows, config, report); err != nil {
+		return nil, err
 	}
 
-	return newCategory
+	return report, nil
 }
 
-func getOrCreateCreditCard(name string, store *storage.PostgresStore) *types.CreditCard {
-	creditCard, _ := store.GetCreditCardByName(name)
-	if creditCard == nil {
-		creditCard = &types.CreditCard{
-			ID:         uuid.Must(uuid.NewV7()),
-			Name:       name,
-			ClosingDay: 10,
-			DueDay:     16,
-			CreatedAt:  time.Now().UTC(),
-			UpdatedAt:  time.Now().UTC(),
-		}
-		err := store.CreateCreditCard(creditCard)
-		if err != nil {
-			log.Fatal("error creating credit card ", err)
+func printReport(report *importer.Report) {
+	if report.DryRun {
+		for _, row := range report.Rows {
+			fmt.Printf("  %-6s %s row %d: %s %s %s %.2f\n", row.Action, row.Source, row.Row,
+				row.Date.Format("2006-01-02"), row.TransactionType, row.Description, row.Amount)
 		}
-	}
-	return creditCard
-}
-
-func mapTransactionAccount(transactionAccount string) (string, types.AccountType) {
-	var accountName string
-	accountType := types.AccountType(transactionAccount)
-
-	if accountType == types.AccountTypePersonal {
-		accountName = "Bradesco"
+		fmt.Print("Dry run: would create ")
 	} else {
-		accountName = "Empresa"
+		fmt.Print("Created ")
 	}
 
-	return accountName, accountType
-}
-
-func getOrCreateAccountOnDB(accountName string, accountType types.AccountType, store *storage.PostgresStore) *types.Account {
-	account, err := store.GetUniqueAccount(accountName, accountType)
-	if err != nil && err != sql.ErrNoRows {
-		log.Fatal("error getting unique account", accountName, accountType)
-	}
-
-	if account != nil {
-		return account
-	}
-
-	newAccount := &types.Account{
-		ID:          uuid.Must(uuid.NewV7()),
-		AccountType: accountType,
-		Name:        accountName,
-		CreatedAt:   time.Now().UTC(),
-		UpdatedAt:   time.Now().UTC(),
+	fmt.Printf("%d transactions, matched %d existing, skipped %d already imported, ignored %d rows, %d errors\n",
+		len(report.Created), report.Matched, report.Skipped, report.Ignored, len(report.Errors))
+	for _, rowError := range report.Errors {
+		fmt.Printf("  %s row %d: %s\n", rowError.File, rowError.Row, rowError.Error)
 	}
-	err = store.CreateAccount(newAccount)
-	if err != nil {
-		log.Fatal("error creating account")
-	}
-
-	return newAccount
-
 }
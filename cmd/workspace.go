@@ -0,0 +1,30 @@
+package cmd
+
+import (
+	"log"
+
+	"github.com/google/uuid"
+	apiserver "github.com/mdsavian/budget-tracker-api/internal/api-server"
+	"github.com/mdsavian/budget-tracker-api/internal/storage"
+)
+
+// workspaceStore keeps the command to the records of the workspace given by id
+func workspaceStore(id string, store *storage.PostgresStore) apiserver.Storage {
+	workspaceID, err := uuid.Parse(id)
+	if err != nil {
+		log.Fatal("invalid workspace id ", id)
+	}
+
+	workspaces, err := store.GetWorkspaces()
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, workspace := range workspaces {
+		if workspace.ID == workspaceID {
+			return apiserver.NewWorkspaceStore(store, workspaceID)
+		}
+	}
+
+	log.Fatal("workspace ", id, " not found")
+	return nil
+}
@@ -10,9 +10,7 @@ require (
 	github.com/samber/lo v1.46.0
 	github.com/thedatashed/xlsxreader v1.2.8
 	golang.org/x/crypto v0.21.0
+	golang.org/x/text v0.16.0
 )
 
-require (
-	github.com/stretchr/testify v1.9.0 // indirect
-	golang.org/x/text v0.16.0 // indirect
-)
+require github.com/stretchr/testify v1.9.0 // indirect
@@ -2,13 +2,20 @@ package apiserver
 
 import (
 	"encoding/json"
+	"fmt"
+	"math"
 	"net/http"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/mdsavian/budget-tracker-api/internal/finance"
+	"github.com/mdsavian/budget-tracker-api/internal/importer"
 	"github.com/mdsavian/budget-tracker-api/internal/types"
 )
 
+// adjustmentCategory is the category of the balance adjustments made without one
+const adjustmentCategory = "balance adjustment"
+
 func (s *APIServer) handleGetAccountByID(w http.ResponseWriter, r *http.Request) {
 	uAccountID, err := getAndParseIDFromRequest(r)
 	if err != nil {
@@ -16,7 +23,7 @@ func (s *APIServer) handleGetAccountByID(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	account, err := s.store.GetAccountByID(uAccountID)
+	account, err := s.storeFor(r).GetAccountByID(uAccountID)
 	if err != nil {
 		respondWithError(w, http.StatusBadRequest, err.Error())
 		return
@@ -26,8 +33,15 @@ func (s *APIServer) handleGetAccountByID(w http.ResponseWriter, r *http.Request)
 }
 
 type CreateNewAccountInput struct {
-	Name        string            `json:"name"`
-	AccountType types.AccountType `json:"account_type"`
+	Name string `json:"name"`
+	// AccountType is the legacy way of setting the owner, used when owner is missing
+	AccountType types.AccountType  `json:"account_type"`
+	Owner       types.AccountOwner `json:"owner"`
+	Kind        types.AccountKind  `json:"kind"`
+	YieldRate   float32            `json:"yield_rate"`
+	// OpeningBalance is the real balance of the account on OpeningDate, YYYY-MM-DD
+	OpeningBalance float32 `json:"opening_balance"`
+	OpeningDate    string  `json:"opening_date"`
 }
 
 func (s *APIServer) handleCreateAccount(w http.ResponseWriter, r *http.Request) {
@@ -37,8 +51,31 @@ func (s *APIServer) handleCreateAccount(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	if createNewAccountInput.Name == "" {
+		respondWithError(w, http.StatusBadRequest, "name is required")
+		return
+	}
+
 	account := newAccount(createNewAccountInput)
-	if err := s.store.CreateAccount(account); err != nil {
+	if err := validateAccount(account); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if createNewAccountInput.OpeningDate != "" {
+		openingDate, err := time.Parse("2006-01-02", createNewAccountInput.OpeningDate)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "opening_date is not a valid date")
+			return
+		}
+		if openingDate.After(time.Now().UTC()) {
+			respondWithError(w, http.StatusBadRequest, "opening_date must not be in the future")
+			return
+		}
+		account.OpeningDate = &openingDate
+	}
+
+	if err := s.storeFor(r).CreateAccount(account); err != nil {
 		respondWithError(w, http.StatusBadRequest, err.Error())
 		return
 	}
@@ -53,12 +90,12 @@ func (s *APIServer) handleDeleteAccount(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	if _, err := s.store.GetAccountByID(uAccountID); err != nil {
+	if _, err := s.storeFor(r).GetAccountByID(uAccountID); err != nil {
 		respondWithError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	if err := s.store.DeleteAccount(uAccountID); err != nil {
+	if err := s.storeFor(r).DeleteAccount(uAccountID); err != nil {
 		respondWithError(w, http.StatusBadRequest, err.Error())
 		return
 	}
@@ -66,8 +103,94 @@ func (s *APIServer) handleDeleteAccount(w http.ResponseWriter, r *http.Request)
 	respondWithJSON(w, http.StatusOK, "Account deleted successfully")
 }
 
+// handleGetAccountBalanceAudit compares the stored balance with the one computed from the transactions
+func (s *APIServer) handleGetAccountBalanceAudit(w http.ResponseWriter, r *http.Request) {
+	uAccountID, err := getAndParseIDFromRequest(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	audit, err := s.storeFor(r).GetAccountBalanceAudit(uAccountID)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, audit)
+}
+
+func (s *APIServer) handleGetAccountBalanceHistory(w http.ResponseWriter, r *http.Request) {
+	uAccountID, err := getAndParseIDFromRequest(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if _, err := s.storeFor(r).GetAccountByID(uAccountID); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	s.respondWithBalanceHistory(w, r, &uAccountID)
+}
+
+// handleGetBalanceHistory returns the history of all the accounts together
+func (s *APIServer) handleGetBalanceHistory(w http.ResponseWriter, r *http.Request) {
+	s.respondWithBalanceHistory(w, r, nil)
+}
+
+func (s *APIServer) respondWithBalanceHistory(w http.ResponseWriter, r *http.Request, accountID *uuid.UUID) {
+	queryValues := r.URL.Query()
+	startDate := queryValues.Get("startDate")
+	endDate := queryValues.Get("endDate")
+
+	if startDate == "" || endDate == "" {
+		respondWithError(w, http.StatusBadRequest, "startDate and endDate are required")
+		return
+	}
+
+	startDateParsed, err := time.Parse("2006-01-02", startDate)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "startDate is not a valid date")
+		return
+	}
+	endDateParsed, err := time.Parse("2006-01-02", endDate)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "endDate is not a valid date")
+		return
+	}
+
+	if endDateParsed.Before(startDateParsed) {
+		respondWithError(w, http.StatusBadRequest, "endDate must not be before startDate")
+		return
+	}
+
+	interval := types.BalanceInterval(queryValues.Get("interval"))
+	switch interval {
+	case "":
+		interval = types.BalanceIntervalMonth
+	case types.BalanceIntervalDay, types.BalanceIntervalWeek, types.BalanceIntervalMonth:
+	default:
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("interval %q is not supported, use day, week or month", interval))
+		return
+	}
+
+	points, err := s.storeFor(r).GetBalanceHistory(accountID, s.workspaceIDFor(r), startDateParsed, endDateParsed, interval)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, &types.BalanceHistory{
+		AccountID: accountID,
+		Interval:  interval,
+		Points:    points,
+	})
+}
+
 func (s *APIServer) handleGetAccounts(w http.ResponseWriter, r *http.Request) {
-	accounts, err := s.store.GetAccounts()
+	accounts, err := s.storeFor(r).GetAccounts()
 	if err != nil {
 		respondWithError(w, http.StatusBadRequest, err.Error())
 		return
@@ -77,12 +200,218 @@ func (s *APIServer) handleGetAccounts(w http.ResponseWriter, r *http.Request) {
 }
 
 func newAccount(input CreateNewAccountInput) *types.Account {
+	owner := input.Owner
+	if owner == "" {
+		owner = input.AccountType.Owner()
+	}
+
+	kind := input.Kind
+	if kind == "" {
+		kind = types.AccountKindChecking
+	}
+
 	return &types.Account{
-		ID:          uuid.Must(uuid.NewV7()),
-		Name:        input.Name,
-		Balance:     0,
-		AccountType: input.AccountType,
-		CreatedAt:   time.Now().UTC(),
-		UpdatedAt:   time.Now().UTC(),
+		ID:             uuid.Must(uuid.NewV7()),
+		Name:           input.Name,
+		Balance:        input.OpeningBalance,
+		OpeningBalance: input.OpeningBalance,
+		AccountType:    owner.AccountType(),
+		Owner:          owner,
+		Kind:           kind,
+		YieldRate:      input.YieldRate,
+		CreatedAt:      time.Now().UTC(),
+		UpdatedAt:      time.Now().UTC(),
+	}
+}
+
+func validateAccount(account *types.Account) error {
+	if !account.Owner.Valid() {
+		return fmt.Errorf("owner must be %q or %q", types.AccountOwnerBusiness, types.AccountOwnerPersonal)
+	}
+	if !account.Kind.Valid() {
+		return fmt.Errorf("kind must be one of %v", types.AccountKinds)
+	}
+	if account.YieldRate < 0 {
+		return fmt.Errorf("yield_rate must not be negative")
+	}
+	if account.YieldRate != 0 && !account.Kind.Yields() {
+		return fmt.Errorf("%s accounts have no yield rate", account.Kind)
+	}
+	return nil
+}
+
+type UpdateAccountInput struct {
+	Name        *string             `json:"name"`
+	AccountType *types.AccountType  `json:"account_type"`
+	Owner       *types.AccountOwner `json:"owner"`
+	Kind        *types.AccountKind  `json:"kind"`
+	YieldRate   *float32            `json:"yield_rate"`
+}
+
+// handleUpdateAccount renames the account or changes its owner or kind, missing fields are kept
+func (s *APIServer) handleUpdateAccount(w http.ResponseWriter, r *http.Request) {
+	uAccountID, err := getAndParseIDFromRequest(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	input := UpdateAccountInput{}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	account, err := s.storeFor(r).GetAccountByID(uAccountID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	if input.Name != nil {
+		if *input.Name == "" {
+			respondWithError(w, http.StatusBadRequest, "name is required")
+			return
+		}
+		account.Name = *input.Name
+	}
+
+	if input.AccountType != nil {
+		if *input.AccountType != types.AccountTypeBusiness && *input.AccountType != types.AccountTypePersonal {
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("account_type must be %q or %q", types.AccountTypeBusiness, types.AccountTypePersonal))
+			return
+		}
+		account.Owner = input.AccountType.Owner()
+	}
+	if input.Owner != nil {
+		account.Owner = *input.Owner
 	}
+	if input.Kind != nil {
+		account.Kind = *input.Kind
+		if !account.Kind.Yields() && input.YieldRate == nil {
+			account.YieldRate = 0
+		}
+	}
+	if input.YieldRate != nil {
+		account.YieldRate = *input.YieldRate
+	}
+
+	if err := validateAccount(account); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	account.AccountType = account.Owner.AccountType()
+
+	account.UpdatedAt = time.Now().UTC()
+	if err := s.storeFor(r).UpdateAccount(account); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, account)
+}
+
+type AdjustAccountBalanceInput struct {
+	Balance     float32    `json:"balance"`
+	Date        string     `json:"date"`
+	Description string     `json:"description"`
+	CategoryID  *uuid.UUID `json:"categoryId"`
+}
+
+// handleAdjustAccountBalance sets the account to the target balance by creating a fulfilled
+// adjustment transaction with the difference, dated today unless a date is given
+func (s *APIServer) handleAdjustAccountBalance(w http.ResponseWriter, r *http.Request) {
+	uAccountID, err := getAndParseIDFromRequest(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	input := AdjustAccountBalanceInput{}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	account, err := s.storeFor(r).GetAccountByID(uAccountID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	date := time.Now().UTC()
+	if input.Date != "" {
+		date, err = time.Parse("2006-01-02", input.Date)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "date is not a valid date")
+			return
+		}
+	}
+
+	// the balance doesn't count transactions before the opening date, an adjustment there would be lost
+	if account.OpeningDate != nil && date.Before(*account.OpeningDate) {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("date is before the opening date %s of the account", account.OpeningDate.Format("2006-01-02")))
+		return
+	}
+
+	difference := finance.RoundCents(input.Balance - account.Balance)
+	if difference == 0 {
+		respondWithError(w, http.StatusBadRequest, "account is already at the balance")
+		return
+	}
+
+	store := s.storeFor(r)
+
+	var categoryID uuid.UUID
+	if input.CategoryID != nil {
+		if _, err := store.GetCategoryByID(*input.CategoryID); err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		categoryID = *input.CategoryID
+	} else {
+		category, err := importer.GetOrCreateCategory(adjustmentCategory, store)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		categoryID = category.ID
+	}
+
+	description := input.Description
+	if description == "" {
+		description = "Balance adjustment"
+	}
+
+	transactionType := types.TransactionTypeCredit
+	if difference < 0 {
+		transactionType = types.TransactionTypeDebit
+	}
+
+	transaction := &types.Transaction{
+		ID:              uuid.Must(uuid.NewV7()),
+		AccountID:       account.ID,
+		CategoryID:      categoryID,
+		TransactionType: transactionType,
+		Date:            date,
+		EffectuatedDate: &date,
+		Description:     description,
+		Amount:          float32(math.Abs(float64(difference))),
+		Fulfilled:       true,
+		Adjustment:      true,
+		CreatedAt:       time.Now().UTC(),
+		UpdatedAt:       time.Now().UTC(),
+	}
+
+	if err := store.CreateTransaction(transaction); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := store.UpdateAccountBalance(account.ID, transaction.Amount, transaction.TransactionType, transaction.EffectiveDate()); err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, transaction)
 }
@@ -0,0 +1,483 @@
+package apiserver
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mdsavian/budget-tracker-api/internal/types"
+	"github.com/samber/lo"
+)
+
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+type contextKey string
+
+const auditStoreKey contextKey = "auditStore"
+
+// auditStore records every change made through it in the audit log, on behalf of
+// the session user and request that caused it. Reads go straight to the store.
+type auditStore struct {
+	Storage
+	userID    *uuid.UUID
+	requestID *uuid.UUID
+}
+
+// NewAuditStore records the changes made outside a session, like the command line, without a user
+func NewAuditStore(store Storage) Storage {
+	return newAuditStore(store, nil)
+}
+
+func newAuditStore(store Storage, userID *uuid.UUID) *auditStore {
+	requestID := uuid.Must(uuid.NewV7())
+	return &auditStore{Storage: store, userID: userID, requestID: &requestID}
+}
+
+// withAuditStore stores an audited store for the session user in the request context,
+// kept to the workspace of the request
+func (s *APIServer) withAuditStore(r *http.Request, session *types.Session, workspaceID uuid.UUID) (*http.Request, *auditStore) {
+	store := newAuditStore(newWorkspaceStore(s.store, workspaceID), &session.UserId)
+	return r.WithContext(context.WithValue(r.Context(), auditStoreKey, store)), store
+}
+
+// storeFor returns the audited store of the request, changes must be made through it
+func (s *APIServer) storeFor(r *http.Request) *auditStore {
+	if store, ok := r.Context().Value(auditStoreKey).(*auditStore); ok {
+		return store
+	}
+	return newAuditStore(s.store, nil)
+}
+
+// record appends the change to the audit log. The change is already persisted at this
+// point, a failure is logged rather than reported as a failed request.
+func (a *auditStore) record(entity types.AuditEntity, entityID uuid.UUID, action types.AuditAction, before, after any) {
+	entry := &types.AuditEntry{
+		ID:        uuid.Must(uuid.NewV7()),
+		UserID:    a.userID,
+		RequestID: a.requestID,
+		Entity:    entity,
+		EntityID:  entityID,
+		Action:    action,
+		Before:    auditJSON(before),
+		After:     auditJSON(after),
+		CreatedAt: time.Now().UTC(),
+	}
+
+	if err := a.Storage.CreateAuditEntry(entry); err != nil {
+		log.Printf("error recording audit of %s %v: %v", entity, entityID, err)
+	}
+}
+
+func auditJSON(value any) json.RawMessage {
+	data, err := json.Marshal(value)
+	if err != nil || string(data) == "null" {
+		return nil
+	}
+	return data
+}
+
+// Transaction
+
+func (a *auditStore) CreateTransaction(transaction *types.Transaction) error {
+	if err := a.Storage.CreateTransaction(transaction); err != nil {
+		return err
+	}
+	a.record(types.AuditEntityTransaction, transaction.ID, types.AuditActionCreate, nil, transaction)
+	return nil
+}
+
+func (a *auditStore) UpdateTransaction(id uuid.UUID, update *types.Transaction) error {
+	return a.recordTransaction(id, types.AuditActionUpdate, func() error { return a.Storage.UpdateTransaction(id, update) })
+}
+
+func (a *auditStore) UpdateTransactionDate(id uuid.UUID, date time.Time) error {
+	return a.recordTransaction(id, types.AuditActionUpdate, func() error { return a.Storage.UpdateTransactionDate(id, date) })
+}
+
+func (a *auditStore) FulfillTransaction(id uuid.UUID) error {
+	return a.recordTransaction(id, types.AuditActionUpdate, func() error { return a.Storage.FulfillTransaction(id) })
+}
+
+func (a *auditStore) DeleteTransaction(id uuid.UUID) error {
+	return a.recordTransaction(id, types.AuditActionArchive, func() error { return a.Storage.DeleteTransaction(id) })
+}
+
+func (a *auditStore) UnarchiveTransaction(id uuid.UUID) error {
+	return a.recordTransaction(id, types.AuditActionUnarchive, func() error { return a.Storage.UnarchiveTransaction(id) })
+}
+
+func (a *auditStore) recordTransaction(id uuid.UUID, action types.AuditAction, change func() error) error {
+	before, _ := a.Storage.GetTransactionByID(id)
+	if err := change(); err != nil {
+		return err
+	}
+	after, _ := a.Storage.GetTransactionByID(id)
+	a.record(types.AuditEntityTransaction, id, action, before, after)
+	return nil
+}
+
+// Recurring transaction
+
+func (a *auditStore) CreateRecurringTransaction(recurringTransaction *types.RecurringTransaction) error {
+	if err := a.Storage.CreateRecurringTransaction(recurringTransaction); err != nil {
+		return err
+	}
+	a.record(types.AuditEntityRecurringTransaction, recurringTransaction.ID, types.AuditActionCreate, nil, recurringTransaction)
+	return nil
+}
+
+func (a *auditStore) UpdateRecurringTransaction(id uuid.UUID, update *types.RecurringTransaction) error {
+	return a.recordRecurringTransaction(id, types.AuditActionUpdate, func() error { return a.Storage.UpdateRecurringTransaction(id, update) })
+}
+
+func (a *auditStore) ArchiveRecurringTransaction(id uuid.UUID) error {
+	return a.recordRecurringTransaction(id, types.AuditActionArchive, func() error { return a.Storage.ArchiveRecurringTransaction(id) })
+}
+
+func (a *auditStore) UnarchiveRecurringTransaction(id uuid.UUID) error {
+	return a.recordRecurringTransaction(id, types.AuditActionUnarchive, func() error { return a.Storage.UnarchiveRecurringTransaction(id) })
+}
+
+func (a *auditStore) recordRecurringTransaction(id uuid.UUID, action types.AuditAction, change func() error) error {
+	before, _ := a.Storage.GetRecurringTransactionByID(id)
+	if err := change(); err != nil {
+		return err
+	}
+	after, _ := a.Storage.GetRecurringTransactionByID(id)
+	a.record(types.AuditEntityRecurringTransaction, id, action, before, after)
+	return nil
+}
+
+// Account
+
+func (a *auditStore) CreateAccount(account *types.Account) error {
+	if err := a.Storage.CreateAccount(account); err != nil {
+		return err
+	}
+	a.record(types.AuditEntityAccount, account.ID, types.AuditActionCreate, nil, account)
+	return nil
+}
+
+func (a *auditStore) UpdateAccount(account *types.Account) error {
+	before, _ := a.Storage.GetAccountByID(account.ID)
+	if err := a.Storage.UpdateAccount(account); err != nil {
+		return err
+	}
+	after, _ := a.Storage.GetAccountByID(account.ID)
+	a.record(types.AuditEntityAccount, account.ID, types.AuditActionUpdate, before, after)
+	return nil
+}
+
+func (a *auditStore) DeleteAccount(id uuid.UUID) error {
+	before, _ := a.Storage.GetAccountByID(id)
+	if err := a.Storage.DeleteAccount(id); err != nil {
+		return err
+	}
+	a.record(types.AuditEntityAccount, id, types.AuditActionDelete, before, nil)
+	return nil
+}
+
+// UpdateAccountBalance records the balance before and after, so every correction can be
+// traced through its request id to the transaction change that caused it
+func (a *auditStore) UpdateAccountBalance(id uuid.UUID, amount float32, transactionType types.TransactionType, date time.Time) error {
+	before, _ := a.Storage.GetAccountByID(id)
+	if err := a.Storage.UpdateAccountBalance(id, amount, transactionType, date); err != nil {
+		return err
+	}
+	after, _ := a.Storage.GetAccountByID(id)
+	a.record(types.AuditEntityAccount, id, types.AuditActionBalance, before, after)
+	return nil
+}
+
+// SetAccountBalance records the repair of a drifted balance as a balance change
+func (a *auditStore) SetAccountBalance(id uuid.UUID, balance float32) error {
+	before, _ := a.Storage.GetAccountByID(id)
+	if err := a.Storage.SetAccountBalance(id, balance); err != nil {
+		return err
+	}
+	after, _ := a.Storage.GetAccountByID(id)
+	a.record(types.AuditEntityAccount, id, types.AuditActionBalance, before, after)
+	return nil
+}
+
+// Reconciliation
+
+func (a *auditStore) CreateReconciliation(reconciliation *types.Reconciliation) error {
+	if err := a.Storage.CreateReconciliation(reconciliation); err != nil {
+		return err
+	}
+	a.record(types.AuditEntityReconciliation, reconciliation.ID, types.AuditActionCreate, nil, reconciliation)
+	return nil
+}
+
+func (a *auditStore) ClearTransactions(reconciliationID, accountID uuid.UUID, transactionIDs []uuid.UUID) (int64, error) {
+	var updated int64
+	err := a.recordReconciliationTransactions(transactionIDs, func() (err error) {
+		updated, err = a.Storage.ClearTransactions(reconciliationID, accountID, transactionIDs)
+		return err
+	})
+	return updated, err
+}
+
+func (a *auditStore) UnclearTransactions(reconciliationID uuid.UUID, transactionIDs []uuid.UUID) (int64, error) {
+	var updated int64
+	err := a.recordReconciliationTransactions(transactionIDs, func() (err error) {
+		updated, err = a.Storage.UnclearTransactions(reconciliationID, transactionIDs)
+		return err
+	})
+	return updated, err
+}
+
+func (a *auditStore) CompleteReconciliation(id uuid.UUID) error {
+	return a.recordReconciliation(id, types.AuditActionUpdate, func() error { return a.Storage.CompleteReconciliation(id) })
+}
+
+func (a *auditStore) UndoReconciliation(id uuid.UUID) error {
+	return a.recordReconciliation(id, types.AuditActionUpdate, func() error { return a.Storage.UndoReconciliation(id) })
+}
+
+func (a *auditStore) DeleteReconciliation(id uuid.UUID) error {
+	before, _ := a.Storage.GetReconciliationByID(id)
+	if err := a.Storage.DeleteReconciliation(id); err != nil {
+		return err
+	}
+	a.record(types.AuditEntityReconciliation, id, types.AuditActionDelete, before, nil)
+	return nil
+}
+
+func (a *auditStore) recordReconciliation(id uuid.UUID, action types.AuditAction, change func() error) error {
+	before, _ := a.Storage.GetReconciliationByID(id)
+	if err := change(); err != nil {
+		return err
+	}
+	after, _ := a.Storage.GetReconciliationByID(id)
+	a.record(types.AuditEntityReconciliation, id, action, before, after)
+	return nil
+}
+
+// recordReconciliationTransactions records the transactions that were cleared or uncleared by the change
+func (a *auditStore) recordReconciliationTransactions(ids []uuid.UUID, change func() error) error {
+	before, _ := a.Storage.GetTransactionsByIDs(ids)
+	if err := change(); err != nil {
+		return err
+	}
+	after, _ := a.Storage.GetTransactionsByIDs(ids)
+
+	beforeByID := map[uuid.UUID]*types.Transaction{}
+	for _, transaction := range before {
+		beforeByID[transaction.ID] = transaction
+	}
+	for _, transaction := range after {
+		old, ok := beforeByID[transaction.ID]
+		if ok && lo.FromPtr(old.ReconciliationID) == lo.FromPtr(transaction.ReconciliationID) {
+			continue
+		}
+		a.record(types.AuditEntityTransaction, transaction.ID, types.AuditActionUpdate, old, transaction)
+	}
+	return nil
+}
+
+// Category
+
+func (a *auditStore) CreateCategory(category *types.Category) error {
+	if err := a.Storage.CreateCategory(category); err != nil {
+		return err
+	}
+	a.record(types.AuditEntityCategory, category.ID, types.AuditActionCreate, nil, category)
+	return nil
+}
+
+func (a *auditStore) UpdateCategory(category *types.Category) error {
+	return a.recordCategory(category.ID, types.AuditActionUpdate, func() error { return a.Storage.UpdateCategory(category) })
+}
+
+func (a *auditStore) ArchiveCategory(id uuid.UUID) error {
+	return a.recordCategory(id, types.AuditActionArchive, func() error { return a.Storage.ArchiveCategory(id) })
+}
+
+func (a *auditStore) UnarchiveCategory(id uuid.UUID) error {
+	return a.recordCategory(id, types.AuditActionUnarchive, func() error { return a.Storage.UnarchiveCategory(id) })
+}
+
+func (a *auditStore) recordCategory(id uuid.UUID, action types.AuditAction, change func() error) error {
+	before, _ := a.Storage.GetCategoryByID(id)
+	if err := change(); err != nil {
+		return err
+	}
+	after, _ := a.Storage.GetCategoryByID(id)
+	a.record(types.AuditEntityCategory, id, action, before, after)
+	return nil
+}
+
+// Credit card
+
+func (a *auditStore) CreateCreditCard(creditCard *types.CreditCard) error {
+	if err := a.Storage.CreateCreditCard(creditCard); err != nil {
+		return err
+	}
+	a.record(types.AuditEntityCreditCard, creditCard.ID, types.AuditActionCreate, nil, creditCard)
+	return nil
+}
+
+func (a *auditStore) UpdateCreditCard(creditCard *types.CreditCard) error {
+	return a.recordCreditCard(creditCard.ID, types.AuditActionUpdate, func() error { return a.Storage.UpdateCreditCard(creditCard) })
+}
+
+func (a *auditStore) ArchiveCreditCard(id uuid.UUID) error {
+	return a.recordCreditCard(id, types.AuditActionArchive, func() error { return a.Storage.ArchiveCreditCard(id) })
+}
+
+func (a *auditStore) UnarchiveCreditCard(id uuid.UUID) error {
+	return a.recordCreditCard(id, types.AuditActionUnarchive, func() error { return a.Storage.UnarchiveCreditCard(id) })
+}
+
+func (a *auditStore) recordCreditCard(id uuid.UUID, action types.AuditAction, change func() error) error {
+	before, _ := a.Storage.GetCreditCardByID(id)
+	if err := change(); err != nil {
+		return err
+	}
+	after, _ := a.Storage.GetCreditCardByID(id)
+	a.record(types.AuditEntityCreditCard, id, action, before, after)
+	return nil
+}
+
+// Holding
+
+func (a *auditStore) CreateHolding(holding *types.Holding) error {
+	if err := a.Storage.CreateHolding(holding); err != nil {
+		return err
+	}
+	a.record(types.AuditEntityHolding, holding.ID, types.AuditActionCreate, nil, holding)
+	return nil
+}
+
+// SaveHoldingMovement records the transactions, balances and holding the movement changed
+func (a *auditStore) SaveHoldingMovement(movement *types.HoldingMovement, holding *types.Holding, transactions []*types.Transaction) error {
+	holdingBefore, _ := a.Storage.GetHoldingByID(holding.ID)
+	accountsBefore := map[uuid.UUID]*types.Account{}
+	for _, transaction := range transactions {
+		accountsBefore[transaction.AccountID], _ = a.Storage.GetAccountByID(transaction.AccountID)
+	}
+
+	if err := a.Storage.SaveHoldingMovement(movement, holding, transactions); err != nil {
+		return err
+	}
+
+	for _, transaction := range transactions {
+		a.record(types.AuditEntityTransaction, transaction.ID, types.AuditActionCreate, nil, transaction)
+		after, _ := a.Storage.GetAccountByID(transaction.AccountID)
+		a.record(types.AuditEntityAccount, transaction.AccountID, types.AuditActionBalance, accountsBefore[transaction.AccountID], after)
+	}
+	holdingAfter, _ := a.Storage.GetHoldingByID(holding.ID)
+	a.record(types.AuditEntityHolding, holding.ID, types.AuditActionUpdate, holdingBefore, holdingAfter)
+	return nil
+}
+
+func (a *auditStore) UpdateHolding(holding *types.Holding) error {
+	before, _ := a.Storage.GetHoldingByID(holding.ID)
+	if err := a.Storage.UpdateHolding(holding); err != nil {
+		return err
+	}
+	after, _ := a.Storage.GetHoldingByID(holding.ID)
+	a.record(types.AuditEntityHolding, holding.ID, types.AuditActionUpdate, before, after)
+	return nil
+}
+
+// Loan
+
+// SaveLoanInstallments records the transactions created and archived and the balances they changed
+func (a *auditStore) SaveLoanInstallments(transactions []*types.Transaction, installments, replaced []*types.LoanInstallment) error {
+	archivedBefore := map[uuid.UUID]*types.Transaction{}
+	for _, installment := range replaced {
+		archivedBefore[installment.TransactionID], _ = a.Storage.GetTransactionByID(installment.TransactionID)
+	}
+	accountsBefore := map[uuid.UUID]*types.Account{}
+	for _, transaction := range transactions {
+		if _, ok := accountsBefore[transaction.AccountID]; transaction.Fulfilled && !ok {
+			accountsBefore[transaction.AccountID], _ = a.Storage.GetAccountByID(transaction.AccountID)
+		}
+	}
+
+	if err := a.Storage.SaveLoanInstallments(transactions, installments, replaced); err != nil {
+		return err
+	}
+
+	for _, installment := range replaced {
+		before := archivedBefore[installment.TransactionID]
+		if before == nil || before.Archived {
+			continue
+		}
+		after, _ := a.Storage.GetTransactionByID(before.ID)
+		a.record(types.AuditEntityTransaction, before.ID, types.AuditActionArchive, before, after)
+	}
+	for _, transaction := range transactions {
+		a.record(types.AuditEntityTransaction, transaction.ID, types.AuditActionCreate, nil, transaction)
+	}
+	for _, transaction := range transactions {
+		before, ok := accountsBefore[transaction.AccountID]
+		if !ok {
+			continue
+		}
+		delete(accountsBefore, transaction.AccountID)
+		after, _ := a.Storage.GetAccountByID(transaction.AccountID)
+		a.record(types.AuditEntityAccount, transaction.AccountID, types.AuditActionBalance, before, after)
+	}
+	return nil
+}
+
+func (a *auditStore) CreateLoan(loan *types.Loan) error {
+	if err := a.Storage.CreateLoan(loan); err != nil {
+		return err
+	}
+	a.record(types.AuditEntityLoan, loan.ID, types.AuditActionCreate, nil, loan)
+	return nil
+}
+
+func (s *APIServer) handleGetAudit(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	entity := types.AuditEntity(query.Get("entity"))
+	switch entity {
+	case "", types.AuditEntityTransaction, types.AuditEntityAccount, types.AuditEntityCategory,
+		types.AuditEntityCreditCard, types.AuditEntityRecurringTransaction, types.AuditEntityHolding, types.AuditEntityLoan,
+		types.AuditEntityReconciliation:
+	default:
+		respondWithError(w, http.StatusBadRequest, "entity must be transaction, account, category, creditCard, recurringTransaction, holding, loan or reconciliation")
+		return
+	}
+
+	var entityID *uuid.UUID
+	if value := query.Get("id"); value != "" {
+		parsedID, err := uuid.Parse(value)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "id is not a valid id")
+			return
+		}
+		entityID = &parsedID
+	}
+
+	limit := defaultAuditLimit
+	if value := query.Get("limit"); value != "" {
+		parsedLimit, err := strconv.Atoi(value)
+		if err != nil || parsedLimit < 1 || parsedLimit > maxAuditLimit {
+			respondWithError(w, http.StatusBadRequest, "limit must be between 1 and 1000")
+			return
+		}
+		limit = parsedLimit
+	}
+
+	entries, err := s.store.GetAuditEntries(entity, entityID, limit)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, entries)
+}
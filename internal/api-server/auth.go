@@ -47,6 +47,14 @@ func (s *APIServer) validateSession(f apiFunc) http.HandlerFunc {
 			respondWithError(w, http.StatusUnauthorized, "session expired")
 			return
 		}
+
+		workspace, ok := s.authorizeWorkspace(w, r, session)
+		if !ok {
+			return
+		}
+
+		r, store := s.withAuditStore(withWorkspace(r, workspace), session, workspace.ID)
+		w.Header().Set("X-Request-ID", store.requestID.String())
 		f(w, r)
 
 	})
@@ -72,6 +80,12 @@ func (s *APIServer) handleLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// the user may have been invited with the email written in another case
+	if err := s.store.AcceptWorkspaceInvites(user); err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
 	newSession := &types.Session{
 		ID:        uuid.New(),
 		UserId:    user.ID,
@@ -0,0 +1,79 @@
+package apiserver
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/mdsavian/budget-tracker-api/internal/backup"
+)
+
+func (s *APIServer) handleGetBackup(w http.ResponseWriter, r *http.Request) {
+	compress := false
+	if value := r.URL.Query().Get("gzip"); value != "" {
+		var err error
+		compress, err = strconv.ParseBool(value)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "gzip is not a valid boolean")
+			return
+		}
+	}
+
+	dump, err := backup.Create(s.store)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	fileName := fmt.Sprintf("budget_backup_%s.json", time.Now().UTC().Format("20060102150405"))
+	contentType := "application/json"
+	if compress {
+		fileName += ".gz"
+		contentType = "application/gzip"
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, fileName))
+
+	if err := backup.Write(w, dump, compress); err != nil {
+		log.Println("error writing backup:", err)
+	}
+}
+
+// handleRestoreBackup reads a backup, plain or gzipped json, from the request body
+func (s *APIServer) handleRestoreBackup(w http.ResponseWriter, r *http.Request) {
+	force := false
+	if value := r.URL.Query().Get("force"); value != "" {
+		var err error
+		force, err = strconv.ParseBool(value)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "force is not a valid boolean")
+			return
+		}
+	}
+
+	dump, err := backup.Read(r.Body)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	err = backup.Restore(s.store, dump, force)
+	if errors.Is(err, backup.ErrNotEmpty) {
+		respondWithError(w, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	counts := map[string]int{}
+	for _, section := range backup.Sections(dump) {
+		counts[section.Name] = section.Count
+	}
+	respondWithJSON(w, http.StatusOK, counts)
+}
@@ -28,7 +28,7 @@ func (s *APIServer) handleCreateCategory(w http.ResponseWriter, r *http.Request)
 		UpdatedAt:   time.Now().UTC(),
 	}
 
-	if err := s.store.CreateCategory(category); err != nil {
+	if err := s.storeFor(r).CreateCategory(category); err != nil {
 		respondWithError(w, http.StatusBadRequest, err.Error())
 	}
 
@@ -38,7 +38,7 @@ func (s *APIServer) handleCreateCategory(w http.ResponseWriter, r *http.Request)
 func (s *APIServer) handleGetCategory(w http.ResponseWriter, r *http.Request) {
 	descriptionInputFilter := r.URL.Query().Get("description")
 	if descriptionInputFilter != "" {
-		category, err := s.store.GetCategoryByDescription(descriptionInputFilter)
+		category, err := s.storeFor(r).GetCategoryByDescription(descriptionInputFilter)
 		if err != nil {
 			respondWithError(w, http.StatusBadRequest, err.Error())
 			return
@@ -47,7 +47,7 @@ func (s *APIServer) handleGetCategory(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	categories, err := s.store.GetCategory()
+	categories, err := s.storeFor(r).GetCategory()
 	if err != nil {
 		respondWithError(w, http.StatusBadRequest, err.Error())
 		return
@@ -62,12 +62,12 @@ func (s *APIServer) handleArchiveCategory(w http.ResponseWriter, r *http.Request
 		respondWithError(w, http.StatusBadRequest, err.Error())
 	}
 
-	if _, err := s.store.GetCategoryByID(id); err != nil {
+	if _, err := s.storeFor(r).GetCategoryByID(id); err != nil {
 		respondWithError(w, http.StatusNotFound, err.Error())
 		return
 	}
 
-	err = s.store.ArchiveCategory(id)
+	err = s.storeFor(r).ArchiveCategory(id)
 	if err != nil {
 		respondWithError(w, http.StatusBadRequest, err.Error())
 		return
@@ -75,3 +75,63 @@ func (s *APIServer) handleArchiveCategory(w http.ResponseWriter, r *http.Request
 
 	respondWithJSON(w, http.StatusOK, "Category archived successfully")
 }
+
+func (s *APIServer) handleUnarchiveCategory(w http.ResponseWriter, r *http.Request) {
+	id, err := getAndParseIDFromRequest(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	category, err := s.storeFor(r).GetCategoryByID(id)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	if !category.Archived {
+		respondWithError(w, http.StatusBadRequest, "category is not archived")
+		return
+	}
+
+	if err := s.storeFor(r).UnarchiveCategory(id); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, "Category restored successfully")
+}
+
+func (s *APIServer) handleUpdateCategory(w http.ResponseWriter, r *http.Request) {
+	id, err := getAndParseIDFromRequest(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	categoryInput := CreateNewCategoryInput{}
+	if err := json.NewDecoder(r.Body).Decode(&categoryInput); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if categoryInput.Description == "" {
+		respondWithError(w, http.StatusBadRequest, "description is required")
+		return
+	}
+
+	category, err := s.storeFor(r).GetCategoryByID(id)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	category.Description = categoryInput.Description
+	category.UpdatedAt = time.Now().UTC()
+	if err := s.storeFor(r).UpdateCategory(category); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, category)
+}
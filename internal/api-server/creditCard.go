@@ -32,7 +32,7 @@ func (s *APIServer) handleCreateCreditCard(w http.ResponseWriter, r *http.Reques
 		UpdatedAt:  time.Now().UTC(),
 	}
 
-	if err := s.store.CreateCreditCard(creditCard); err != nil {
+	if err := s.storeFor(r).CreateCreditCard(creditCard); err != nil {
 		respondWithError(w, http.StatusBadRequest, err.Error())
 	}
 
@@ -42,7 +42,7 @@ func (s *APIServer) handleCreateCreditCard(w http.ResponseWriter, r *http.Reques
 func (s *APIServer) handleGetCreditCard(w http.ResponseWriter, r *http.Request) {
 	nameInputFilter := r.URL.Query().Get("name")
 	if nameInputFilter != "" {
-		creditCard, err := s.store.GetCreditCardByName(nameInputFilter)
+		creditCard, err := s.storeFor(r).GetCreditCardByName(nameInputFilter)
 		if err != nil {
 			respondWithError(w, http.StatusBadRequest, err.Error())
 			return
@@ -51,7 +51,7 @@ func (s *APIServer) handleGetCreditCard(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	cards, err := s.store.GetCreditCard()
+	cards, err := s.storeFor(r).GetCreditCard()
 	if err != nil {
 		respondWithError(w, http.StatusBadRequest, err.Error())
 		return
@@ -66,7 +66,7 @@ func (s *APIServer) handleGetCreditCardById(w http.ResponseWriter, r *http.Reque
 		respondWithError(w, http.StatusBadRequest, err.Error())
 	}
 
-	creditCard, err := s.store.GetCreditCardByID(id)
+	creditCard, err := s.storeFor(r).GetCreditCardByID(id)
 	if err != nil {
 		respondWithError(w, http.StatusBadRequest, err.Error())
 		return
@@ -81,12 +81,12 @@ func (s *APIServer) handleArchiveCreditCard(w http.ResponseWriter, r *http.Reque
 		respondWithError(w, http.StatusBadRequest, err.Error())
 	}
 
-	if _, err := s.store.GetCreditCardByID(id); err != nil {
+	if _, err := s.storeFor(r).GetCreditCardByID(id); err != nil {
 		respondWithError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	err = s.store.ArchiveCreditCard(id)
+	err = s.storeFor(r).ArchiveCreditCard(id)
 	if err != nil {
 		respondWithError(w, http.StatusBadRequest, err.Error())
 		return
@@ -94,3 +94,174 @@ func (s *APIServer) handleArchiveCreditCard(w http.ResponseWriter, r *http.Reque
 
 	respondWithJSON(w, http.StatusOK, "CreditCard archived successfully")
 }
+
+func (s *APIServer) handleUnarchiveCreditCard(w http.ResponseWriter, r *http.Request) {
+	id, err := getAndParseIDFromRequest(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	creditCard, err := s.storeFor(r).GetCreditCardByID(id)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	if !creditCard.Archived {
+		respondWithError(w, http.StatusBadRequest, "credit card is not archived")
+		return
+	}
+
+	if err := s.storeFor(r).UnarchiveCreditCard(id); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, "CreditCard restored successfully")
+}
+
+type UpdateCreditCardInput struct {
+	Name       *string `json:"name"`
+	ClosingDay *int    `json:"closingDay"`
+	DueDay     *int    `json:"dueDay"`
+}
+
+// handleUpdateCreditCard changes the card, missing fields are kept. With ?redate=true a due day
+// change also moves the future unpaid card transactions and the card recurring series to the new
+// due day, each transaction staying in the statement it was due. Card transactions keep only their
+// due date, so a closing day change can't move them to another statement, the response says so.
+func (s *APIServer) handleUpdateCreditCard(w http.ResponseWriter, r *http.Request) {
+	type UpdateCreditCardOutput struct {
+		CreditCard                   *types.CreditCard `json:"creditCard"`
+		RedatedTransactions          int               `json:"redatedTransactions"`
+		RedatedRecurringTransactions int               `json:"redatedRecurringTransactions"`
+		// RedateWarning tells what the re-dating could not do
+		RedateWarning string `json:"redateWarning,omitempty"`
+	}
+
+	id, err := getAndParseIDFromRequest(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	input := UpdateCreditCardInput{}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	redate := r.URL.Query().Get("redate") == "true"
+
+	creditCard, err := s.storeFor(r).GetCreditCardByID(id)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	previousDueDay := creditCard.DueDay
+	previousClosingDay := creditCard.ClosingDay
+
+	if input.Name != nil {
+		if *input.Name == "" {
+			respondWithError(w, http.StatusBadRequest, "name is required")
+			return
+		}
+		creditCard.Name = *input.Name
+	}
+	if input.ClosingDay != nil {
+		if *input.ClosingDay < 1 || *input.ClosingDay > 31 {
+			respondWithError(w, http.StatusBadRequest, "closingDay must be between 1 and 31")
+			return
+		}
+		creditCard.ClosingDay = *input.ClosingDay
+	}
+	if input.DueDay != nil {
+		if *input.DueDay < 1 || *input.DueDay > 31 {
+			respondWithError(w, http.StatusBadRequest, "dueDay must be between 1 and 31")
+			return
+		}
+		creditCard.DueDay = *input.DueDay
+	}
+
+	store := s.storeFor(r)
+	creditCard.UpdatedAt = time.Now().UTC()
+	if err := store.UpdateCreditCard(creditCard); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	output := UpdateCreditCardOutput{CreditCard: creditCard}
+	if redate && creditCard.DueDay != previousDueDay {
+		output.RedatedTransactions, output.RedatedRecurringTransactions, err = s.redateCreditCardTransactions(store, creditCard, previousDueDay)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+	if redate && creditCard.ClosingDay != previousClosingDay {
+		output.RedateWarning = "the purchase date of card transactions is not stored, they were kept in their statement " +
+			"and only new purchases use the new closing day"
+	}
+
+	respondWithJSON(w, http.StatusOK, output)
+}
+
+// redateCreditCardTransactions moves the unpaid card transactions due from today, and the recurring
+// series on the previous due day, to the new due day of the card. Transactions the new due day
+// would move before today are kept, they would be overdue as soon as they are moved.
+func (s *APIServer) redateCreditCardTransactions(store Storage, creditCard *types.CreditCard, previousDueDay int) (int, int, error) {
+	now := time.Now().UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	transactions, err := store.GetUnpaidCreditCardTransactions(creditCard.ID, today)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	redatedTransactions := 0
+	for _, transaction := range transactions {
+		date := redateDueDate(transaction.Date, previousDueDay, creditCard.DueDay)
+		if date.Equal(transaction.Date) || date.Before(today) {
+			continue
+		}
+		if err := store.UpdateTransactionDate(transaction.ID, date); err != nil {
+			return 0, 0, err
+		}
+		redatedTransactions++
+	}
+
+	recurringTransactions, err := store.GetRecurringTransactions()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	redatedRecurringTransactions := 0
+	for _, recurringTransaction := range recurringTransactions {
+		if recurringTransaction.Archived || recurringTransaction.CreditCardID == nil ||
+			*recurringTransaction.CreditCardID != creditCard.ID || recurringTransaction.Day != previousDueDay {
+			continue
+		}
+
+		recurringTransaction.Day = creditCard.DueDay
+		if err := store.UpdateRecurringTransaction(recurringTransaction.ID, recurringTransaction); err != nil {
+			return 0, 0, err
+		}
+		redatedRecurringTransactions++
+	}
+
+	return redatedTransactions, redatedRecurringTransactions, nil
+}
+
+// redateDueDate moves a due date of the previous due day to the due day in the same statement month,
+// or to the last day of shorter months. DueDateFor overflows a due day past the end of a shorter
+// month into the next one, so a Mar 3 due on the 31st is moved within the February statement.
+func redateDueDate(dueDate time.Time, previousDueDay, dueDay int) time.Time {
+	month := time.Date(dueDate.Year(), dueDate.Month(), 1, 0, 0, 0, 0, dueDate.Location())
+	if lastDay := month.AddDate(0, 0, -1).Day(); previousDueDay > lastDay && dueDate.Day() == previousDueDay-lastDay {
+		month = month.AddDate(0, -1, 0)
+	}
+
+	lastDay := month.AddDate(0, 1, -1).Day()
+	return month.AddDate(0, 0, min(dueDay, lastDay)-1)
+}
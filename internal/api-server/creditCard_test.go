@@ -0,0 +1,32 @@
+package apiserver
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRedateDueDate(t *testing.T) {
+	tests := []struct {
+		name           string
+		dueDate        time.Time
+		previousDueDay int
+		dueDay         int
+		want           time.Time
+	}{
+		{"later day in the month", time.Date(2024, time.January, 10, 0, 0, 0, 0, time.UTC), 10, 20, time.Date(2024, time.January, 20, 0, 0, 0, 0, time.UTC)},
+		{"earlier day in the month", time.Date(2024, time.March, 31, 0, 0, 0, 0, time.UTC), 31, 5, time.Date(2024, time.March, 5, 0, 0, 0, 0, time.UTC)},
+		{"clamped to the end of a shorter month", time.Date(2024, time.February, 10, 0, 0, 0, 0, time.UTC), 10, 31, time.Date(2024, time.February, 29, 0, 0, 0, 0, time.UTC)},
+		{"overflowed into a leap march", time.Date(2024, time.March, 2, 0, 0, 0, 0, time.UTC), 31, 15, time.Date(2024, time.February, 15, 0, 0, 0, 0, time.UTC)},
+		{"overflowed and clamped", time.Date(2025, time.March, 3, 0, 0, 0, 0, time.UTC), 31, 30, time.Date(2025, time.February, 28, 0, 0, 0, 0, time.UTC)},
+		{"early day that is not an overflow", time.Date(2025, time.March, 3, 0, 0, 0, 0, time.UTC), 3, 10, time.Date(2025, time.March, 10, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := redateDueDate(tt.dueDate, tt.previousDueDay, tt.dueDay)
+			if !got.Equal(tt.want) {
+				t.Errorf("got %s, want %s", got.Format("2006-01-02"), tt.want.Format("2006-01-02"))
+			}
+		})
+	}
+}
@@ -1,10 +1,17 @@
 package apiserver
 
 import (
+	"fmt"
+	"math"
 	"net/http"
+	"net/url"
+	"sort"
 	"time"
 
+	"github.com/google/uuid"
+	"github.com/mdsavian/budget-tracker-api/internal/finance"
 	"github.com/mdsavian/budget-tracker-api/internal/types"
+	"github.com/samber/lo"
 )
 
 func (s *APIServer) handleGetDashboardInfo(w http.ResponseWriter, r *http.Request) {
@@ -28,31 +35,85 @@ func (s *APIServer) handleGetDashboardInfo(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	type CategoryTotal struct {
-		Name  string  `json:"name"`
-		Total float64 `json:"total"`
+	filter, err := parseTransactionFilter(queryValues)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
 	}
 
 	type DashboardInfo struct {
-		Transactions            []*types.TransactionView `json:"transactions"`
-		TotalCredit             float64                  `json:"totalCredit"`
-		TotalDebit              float64                  `json:"totalDebit"`
-		TotalDebitUnpaid        float64                  `json:"totalDebitUnpaid"`
-		TotalCreditUpcoming     float64                  `json:"totalCreditUpcoming"`
-		TotalCreditCard         float64                  `json:"totalCreditCard"`
-		TotalCreditCardUpcoming float64                  `json:"totalCreditCardUpcoming"`
-		CategoryTotals          []CategoryTotal          `json:"categoryTotals"`
-		Balance                 float64                  `json:"balance"`
-		Accounts                []*types.Account         `json:"accounts"`
+		Transactions []*types.TransactionView `json:"transactions"`
+		DashboardTotals
+		Accounts []*types.Account `json:"accounts"`
+		// PreviousPeriod compares with the period of the same length right before
+		PreviousPeriod *DashboardComparison `json:"previousPeriod"`
+		// LastYear compares with the same period one year before
+		LastYear *DashboardComparison `json:"lastYear"`
+	}
+
+	store := s.storeFor(r)
+	transactions, err := store.GetTransactionsWithRecurringByDate(startDateParsed, endDateParsed, filter)
+
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
 	}
 
-	transactions, err := s.store.GetTransactionsWithRecurringByDate(startDateParsed, endDateParsed)
+	totals := computeDashboardTotals(transactions)
+
+	accounts, err := store.GetAccounts()
+	if err != nil {
+		respondWithJSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if filter.AccountID != nil {
+		accounts = lo.Filter(accounts, func(account *types.Account, _ int) bool {
+			return account.ID == *filter.AccountID
+		})
+	}
+
+	previousStart, previousEnd := previousPeriod(startDateParsed, endDateParsed)
+	previous, err := compareDashboardTotals(store, totals, previousStart, previousEnd, filter)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 
+	lastYear, err := compareDashboardTotals(store, totals, startDateParsed.AddDate(-1, 0, 0), endDateParsed.AddDate(-1, 0, 0), filter)
 	if err != nil {
 		respondWithError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
+	dashboardInfo := DashboardInfo{
+		Transactions:    transactions,
+		DashboardTotals: totals,
+		Accounts:        accounts,
+		PreviousPeriod:  previous,
+		LastYear:        lastYear,
+	}
+
+	respondWithJSON(w, http.StatusOK, dashboardInfo)
+}
+
+type CategoryTotal struct {
+	Name  string  `json:"name"`
+	Total float64 `json:"total"`
+}
+
+type DashboardTotals struct {
+	TotalCredit             float64         `json:"totalCredit"`
+	TotalDebit              float64         `json:"totalDebit"`
+	TotalDebitUnpaid        float64         `json:"totalDebitUnpaid"`
+	TotalCreditUpcoming     float64         `json:"totalCreditUpcoming"`
+	TotalCreditCard         float64         `json:"totalCreditCard"`
+	TotalCreditCardUpcoming float64         `json:"totalCreditCardUpcoming"`
+	CategoryTotals          []CategoryTotal `json:"categoryTotals"`
+	Balance                 float64         `json:"balance"`
+}
+
+func computeDashboardTotals(transactions []*types.TransactionView) DashboardTotals {
 	var totalCredit float64 = 0
 	var totalDebit float64 = 0
 	var totalDebitUnpaid float64 = 0
@@ -62,6 +123,12 @@ func (s *APIServer) handleGetDashboardInfo(w http.ResponseWriter, r *http.Reques
 	var categoryMap = map[string]float64{}
 
 	for _, transaction := range transactions {
+		// adjustments correct the balance and transfers move it between accounts,
+		// they are not income or expenses
+		if transaction.Adjustment || transaction.TransferID != nil {
+			continue
+		}
+
 		if transaction.TransactionType == types.TransactionTypeCredit {
 			if !transaction.Fulfilled {
 				totalCreditUpcoming += transaction.Amount
@@ -92,12 +159,6 @@ func (s *APIServer) handleGetDashboardInfo(w http.ResponseWriter, r *http.Reques
 		}
 	}
 
-	accounts, err := s.store.GetAccounts()
-	if err != nil {
-		respondWithJSON(w, http.StatusBadRequest, err.Error())
-		return
-	}
-
 	var categoryTotals []CategoryTotal
 	for category, total := range categoryMap {
 		categoryTotals = append(categoryTotals, CategoryTotal{Name: category, Total: total})
@@ -105,8 +166,7 @@ func (s *APIServer) handleGetDashboardInfo(w http.ResponseWriter, r *http.Reques
 
 	balance := totalCredit + totalCreditUpcoming - (totalDebit + totalDebitUnpaid)
 
-	dashboardInfo := DashboardInfo{
-		Transactions:            transactions,
+	return DashboardTotals{
 		TotalCredit:             totalCredit,
 		TotalDebit:              totalDebit,
 		TotalCreditCard:         totalCreditCard,
@@ -115,10 +175,98 @@ func (s *APIServer) handleGetDashboardInfo(w http.ResponseWriter, r *http.Reques
 		TotalCreditCardUpcoming: totalCreditCardUpcoming,
 		CategoryTotals:          categoryTotals,
 		Balance:                 balance,
-		Accounts:                accounts,
 	}
+}
 
-	respondWithJSON(w, http.StatusOK, dashboardInfo)
+// Delta compares a figure of the current period with another period
+type Delta struct {
+	Current  float64 `json:"current"`
+	Compared float64 `json:"compared"`
+	Delta    float64 `json:"delta"`
+	// Percent is nil when the compared figure is zero
+	Percent *float64 `json:"percent"`
+}
+
+func newDelta(current, compared float64) Delta {
+	delta := Delta{Current: current, Compared: compared, Delta: finance.RoundMoney(current - compared)}
+	if compared != 0 {
+		delta.Percent = lo.ToPtr(math.Round((current-compared)/math.Abs(compared)*10000) / 100)
+	}
+	return delta
+}
+
+type CategoryDelta struct {
+	Name string `json:"name"`
+	Delta
+}
+
+type DashboardComparison struct {
+	StartDate               time.Time       `json:"startDate"`
+	EndDate                 time.Time       `json:"endDate"`
+	Totals                  DashboardTotals `json:"totals"`
+	TotalCredit             Delta           `json:"totalCredit"`
+	TotalDebit              Delta           `json:"totalDebit"`
+	TotalDebitUnpaid        Delta           `json:"totalDebitUnpaid"`
+	TotalCreditUpcoming     Delta           `json:"totalCreditUpcoming"`
+	TotalCreditCard         Delta           `json:"totalCreditCard"`
+	TotalCreditCardUpcoming Delta           `json:"totalCreditCardUpcoming"`
+	Balance                 Delta           `json:"balance"`
+	Categories              []CategoryDelta `json:"categories"`
+}
+
+func compareDashboardTotals(store Storage, current DashboardTotals, startDate, endDate time.Time, filter *types.TransactionFilter) (*DashboardComparison, error) {
+	transactions, err := store.GetTransactionsWithRecurringByDate(startDate, endDate, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	compared := computeDashboardTotals(transactions)
+	comparison := &DashboardComparison{
+		StartDate:               startDate,
+		EndDate:                 endDate,
+		Totals:                  compared,
+		TotalCredit:             newDelta(current.TotalCredit, compared.TotalCredit),
+		TotalDebit:              newDelta(current.TotalDebit, compared.TotalDebit),
+		TotalDebitUnpaid:        newDelta(current.TotalDebitUnpaid, compared.TotalDebitUnpaid),
+		TotalCreditUpcoming:     newDelta(current.TotalCreditUpcoming, compared.TotalCreditUpcoming),
+		TotalCreditCard:         newDelta(current.TotalCreditCard, compared.TotalCreditCard),
+		TotalCreditCardUpcoming: newDelta(current.TotalCreditCardUpcoming, compared.TotalCreditCardUpcoming),
+		Balance:                 newDelta(current.Balance, compared.Balance),
+		Categories:              []CategoryDelta{},
+	}
+
+	categories := map[string][2]float64{}
+	for _, category := range current.CategoryTotals {
+		totals := categories[category.Name]
+		totals[0] = category.Total
+		categories[category.Name] = totals
+	}
+	for _, category := range compared.CategoryTotals {
+		totals := categories[category.Name]
+		totals[1] = category.Total
+		categories[category.Name] = totals
+	}
+
+	for name, totals := range categories {
+		comparison.Categories = append(comparison.Categories, CategoryDelta{Name: name, Delta: newDelta(totals[0], totals[1])})
+	}
+	sort.Slice(comparison.Categories, func(i, j int) bool {
+		return comparison.Categories[i].Name < comparison.Categories[j].Name
+	})
+
+	return comparison, nil
+}
+
+// previousPeriod returns the period of the same length right before the given one. Whole
+// months are compared with the same number of whole months before.
+func previousPeriod(startDate, endDate time.Time) (time.Time, time.Time) {
+	if startDate.Day() == 1 && endDate.AddDate(0, 0, 1).Day() == 1 {
+		months := (endDate.Year()-startDate.Year())*12 + int(endDate.Month()-startDate.Month()) + 1
+		return startDate.AddDate(0, -months, 0), startDate.AddDate(0, 0, -1)
+	}
+
+	days := int(endDate.Sub(startDate).Hours()/24) + 1
+	return startDate.AddDate(0, 0, -days), startDate.AddDate(0, 0, -1)
 }
 
 func (s *APIServer) handleGetTransactionsByDate(w http.ResponseWriter, r *http.Request) {
@@ -142,11 +290,17 @@ func (s *APIServer) handleGetTransactionsByDate(w http.ResponseWriter, r *http.R
 		return
 	}
 
+	filter, err := parseTransactionFilter(queryValues)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
 	type TransactionInfo struct {
 		Transactions []*types.TransactionView `json:"transactions"`
 	}
 
-	transactions, err := s.store.GetTransactionsWithRecurringByDate(startDateParsed, endDateParsed)
+	transactions, err := s.storeFor(r).GetTransactionsWithRecurringByDate(startDateParsed, endDateParsed, filter)
 
 	if err != nil {
 		respondWithError(w, http.StatusBadRequest, err.Error())
@@ -159,3 +313,28 @@ func (s *APIServer) handleGetTransactionsByDate(w http.ResponseWriter, r *http.R
 
 	respondWithJSON(w, http.StatusOK, transactionInfo)
 }
+
+// parseTransactionFilter reads the accountId, creditCardId and categoryId query params
+func parseTransactionFilter(queryValues url.Values) (*types.TransactionFilter, error) {
+	filter := &types.TransactionFilter{}
+
+	params := map[string]**uuid.UUID{
+		"accountId":    &filter.AccountID,
+		"creditCardId": &filter.CreditCardID,
+		"categoryId":   &filter.CategoryID,
+	}
+	for param, field := range params {
+		value := queryValues.Get(param)
+		if value == "" {
+			continue
+		}
+
+		id, err := uuid.Parse(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s is not a valid id", param)
+		}
+		*field = &id
+	}
+
+	return filter, nil
+}
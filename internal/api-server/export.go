@@ -0,0 +1,81 @@
+package apiserver
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/mdsavian/budget-tracker-api/internal/export"
+	"github.com/mdsavian/budget-tracker-api/internal/types"
+)
+
+func (s *APIServer) handleExportTransactions(w http.ResponseWriter, r *http.Request) {
+	queryValues := r.URL.Query()
+	startDate := queryValues.Get("startDate")
+	endDate := queryValues.Get("endDate")
+
+	if startDate == "" || endDate == "" {
+		respondWithError(w, http.StatusBadRequest, "startDate and endDate are required")
+		return
+	}
+
+	startDateParsed, err := time.Parse("2006-01-02", startDate)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "startDate is not a valid date")
+		return
+	}
+	endDateParsed, err := time.Parse("2006-01-02", endDate)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "endDate is not a valid date")
+		return
+	}
+
+	format := export.Format(queryValues.Get("format"))
+	if format == "" {
+		format = export.FormatCSV
+	}
+
+	includeRecurring := true
+	if value := queryValues.Get("includeRecurring"); value != "" {
+		includeRecurring, err = strconv.ParseBool(value)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "includeRecurring is not a valid boolean")
+			return
+		}
+	}
+
+	filter, err := parseTransactionFilter(queryValues)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if format != export.FormatCSV && format != export.FormatXlsx {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("format %q is not supported, use csv or xlsx", format))
+		return
+	}
+
+	w.Header().Set("Content-Type", format.ContentType())
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="transactions_%s_%s.%s"`, startDate, endDate, format))
+
+	// from here on the response is streamed, errors can only be logged
+	writer, err := export.NewTransactionWriter(format, w)
+	if err != nil {
+		log.Println("error exporting transactions:", err)
+		return
+	}
+
+	err = s.storeFor(r).StreamTransactionsByDate(startDateParsed, endDateParsed, includeRecurring, filter, func(transaction *types.TransactionView) error {
+		return writer.Write(transaction)
+	})
+	if err != nil {
+		log.Println("error exporting transactions:", err)
+		return
+	}
+
+	if err := writer.Close(); err != nil {
+		log.Println("error exporting transactions:", err)
+	}
+}
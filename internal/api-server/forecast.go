@@ -0,0 +1,102 @@
+package apiserver
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mdsavian/budget-tracker-api/internal/forecast"
+	"github.com/mdsavian/budget-tracker-api/internal/types"
+)
+
+// maxForecastDays limits the size of the daily series returned
+const maxForecastDays = 731
+
+func (s *APIServer) handleGetForecast(w http.ResponseWriter, r *http.Request) {
+	start, until, ok := parseForecastPeriod(w, r)
+	if !ok {
+		return
+	}
+
+	accounts, items, err := s.getForecastItems(s.storeFor(r), start, until)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	result, err := forecast.Build(accounts, items, start, until)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, result)
+}
+
+// parseForecastPeriod reads the until query param, the forecast always starts today
+func parseForecastPeriod(w http.ResponseWriter, r *http.Request) (time.Time, time.Time, bool) {
+	now := time.Now().UTC()
+	start := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	untilValue := r.URL.Query().Get("until")
+	if untilValue == "" {
+		respondWithError(w, http.StatusBadRequest, "until is required")
+		return start, start, false
+	}
+
+	until, err := time.Parse("2006-01-02", untilValue)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "until is not a valid date")
+		return start, start, false
+	}
+
+	if until.Before(start) {
+		respondWithError(w, http.StatusBadRequest, "until must not be in the past")
+		return start, start, false
+	}
+
+	if until.After(start.AddDate(0, 0, maxForecastDays)) {
+		respondWithError(w, http.StatusBadRequest, "until must be within two years")
+		return start, start, false
+	}
+
+	return start, until, true
+}
+
+// getForecastItems returns the accounts with their unfulfilled transactions and upcoming recurring
+// occurrences. Recurring occurrences before start are not projected.
+func (s *APIServer) getForecastItems(store Storage, start, until time.Time) ([]*types.Account, []*forecast.Item, error) {
+	accounts, err := store.GetAccounts()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	overdue, err := store.GetUnfulfilledTransactionsBefore(start)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	items := []*forecast.Item{}
+	seen := map[uuid.UUID]bool{}
+	for _, transaction := range overdue {
+		seen[transaction.ID] = true
+		items = append(items, forecast.FromTransactionView(transaction))
+	}
+
+	err = store.StreamTransactionsByDate(start, until, true, nil, func(transaction *types.TransactionView) error {
+		if transaction.Fulfilled || seen[transaction.ID] {
+			return nil
+		}
+		if transaction.ID == uuid.Nil && transaction.Date.Before(start) {
+			return nil
+		}
+
+		items = append(items, forecast.FromTransactionView(transaction))
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return accounts, items, nil
+}
@@ -0,0 +1,233 @@
+package apiserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mdsavian/budget-tracker-api/internal/importer"
+	"github.com/mdsavian/budget-tracker-api/internal/types"
+)
+
+const maxImportFileSize = 32 << 20
+
+func (s *APIServer) handleImportOFX(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseMultipartForm(maxImportFileSize); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	accountID, err := uuid.Parse(r.FormValue("accountId"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "accountId is required")
+		return
+	}
+
+	categoryID, err := uuid.Parse(r.FormValue("categoryId"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "categoryId is required")
+		return
+	}
+
+	if _, err := s.storeFor(r).GetAccountByID(accountID); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if _, err := s.storeFor(r).GetCategoryByID(categoryID); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "file is required")
+		return
+	}
+	defer file.Close()
+
+	lines, err := importer.ParseOFX(file)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	dryRun, _ := strconv.ParseBool(r.FormValue("dryRun"))
+	report := importer.NewReport(dryRun)
+	if err := importer.ImportStatement(s.storeFor(r), header.Filename, lines, accountID, categoryID, report); err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, report)
+}
+
+type CreateCSVProfileInput struct {
+	Name              string                  `json:"name"`
+	Delimiter         string                  `json:"delimiter"`
+	Encoding          types.CSVEncoding       `json:"encoding"`
+	SkipRows          int                     `json:"skipRows"`
+	DateColumn        int                     `json:"dateColumn"`
+	DateFormat        string                  `json:"dateFormat"`
+	DescriptionColumn int                     `json:"descriptionColumn"`
+	AmountColumn      int                     `json:"amountColumn"`
+	CategoryColumn    *int                    `json:"categoryColumn"`
+	DecimalComma      bool                    `json:"decimalComma"`
+	SignConvention    types.CSVSignConvention `json:"signConvention"`
+	AccountID         uuid.UUID               `json:"accountId"`
+	CreditCardID      *uuid.UUID              `json:"creditCardId"`
+	CategoryID        uuid.UUID               `json:"categoryId"`
+}
+
+func (s *APIServer) handleCreateCSVProfile(w http.ResponseWriter, r *http.Request) {
+	profileInput := CreateCSVProfileInput{
+		Delimiter:      ",",
+		Encoding:       types.CSVEncodingUTF8,
+		DateFormat:     "2006-01-02",
+		SignConvention: types.CSVSignNegativeDebit,
+	}
+	if err := json.NewDecoder(r.Body).Decode(&profileInput); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	profile := &types.CSVProfile{
+		ID:                uuid.Must(uuid.NewV7()),
+		Name:              profileInput.Name,
+		Delimiter:         profileInput.Delimiter,
+		Encoding:          profileInput.Encoding,
+		SkipRows:          profileInput.SkipRows,
+		DateColumn:        profileInput.DateColumn,
+		DateFormat:        profileInput.DateFormat,
+		DescriptionColumn: profileInput.DescriptionColumn,
+		AmountColumn:      profileInput.AmountColumn,
+		CategoryColumn:    profileInput.CategoryColumn,
+		DecimalComma:      profileInput.DecimalComma,
+		SignConvention:    profileInput.SignConvention,
+		AccountID:         profileInput.AccountID,
+		CreditCardID:      profileInput.CreditCardID,
+		CategoryID:        profileInput.CategoryID,
+		CreatedAt:         time.Now().UTC(),
+		UpdatedAt:         time.Now().UTC(),
+	}
+
+	if err := importer.ValidateCSVProfile(profile); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := s.storeFor(r).CreateCSVProfile(profile); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, profile)
+}
+
+func (s *APIServer) handleGetCSVProfiles(w http.ResponseWriter, r *http.Request) {
+	profiles, err := s.store.GetCSVProfiles(s.workspaceIDFor(r))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, profiles)
+}
+
+func (s *APIServer) handleDeleteCSVProfile(w http.ResponseWriter, r *http.Request) {
+	id, err := getAndParseIDFromRequest(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if _, err := s.storeFor(r).GetCSVProfileByID(id); err != nil {
+		respondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	if err := s.storeFor(r).DeleteCSVProfile(id); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, "CSV profile deleted successfully")
+}
+
+// handlePreviewCSV returns the parsed rows and what importing them would do, without writing anything
+func (s *APIServer) handlePreviewCSV(w http.ResponseWriter, r *http.Request) {
+	s.importCSV(w, r, true)
+}
+
+func (s *APIServer) handleImportCSV(w http.ResponseWriter, r *http.Request) {
+	dryRun, _ := strconv.ParseBool(r.FormValue("dryRun"))
+	s.importCSV(w, r, dryRun)
+}
+
+func (s *APIServer) importCSV(w http.ResponseWriter, r *http.Request, dryRun bool) {
+	type CSVImportInfo struct {
+		Rows   []*importer.CSVRow `json:"rows"`
+		Report *importer.Report   `json:"report"`
+	}
+
+	source, profile, rows, err := s.parseCSVUpload(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var creditCard *types.CreditCard
+	if profile.CreditCardID != nil {
+		creditCard, err = s.storeFor(r).GetCreditCardByID(*profile.CreditCardID)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	report := importer.NewReport(dryRun)
+	if err := importer.ImportCSV(s.storeFor(r), source, rows, profile, creditCard, report); err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, CSVImportInfo{Rows: rows, Report: report})
+}
+
+// parseCSVUpload reads the uploaded file using the profile sent in the profileId form value
+func (s *APIServer) parseCSVUpload(r *http.Request) (string, *types.CSVProfile, []*importer.CSVRow, error) {
+	if err := r.ParseMultipartForm(maxImportFileSize); err != nil {
+		return "", nil, nil, err
+	}
+
+	profileID, err := uuid.Parse(r.FormValue("profileId"))
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("profileId is required")
+	}
+
+	profile, err := s.storeFor(r).GetCSVProfileByID(profileID)
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("file is required")
+	}
+	defer file.Close()
+
+	categories, err := s.storeFor(r).GetCategory()
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	rows, err := importer.ParseCSV(file, profile, categories)
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	return header.Filename, profile, rows, nil
+}
@@ -0,0 +1,310 @@
+package apiserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mdsavian/budget-tracker-api/internal/importer"
+	"github.com/mdsavian/budget-tracker-api/internal/types"
+	"github.com/samber/lo"
+)
+
+const (
+	importWorkers     = 2
+	importQueueSize   = 100
+	importProgressRow = 50
+)
+
+// importTask is a queued import job with the uploaded file and the options of its format
+type importTask struct {
+	job        *types.ImportJob
+	data       []byte
+	accountID  uuid.UUID
+	categoryID uuid.UUID
+	profile    *types.CSVProfile
+	creditCard *types.CreditCard
+	xlsxConfig *importer.XlsxConfig
+	// store audits the imported changes on behalf of the request that queued the job
+	store Storage
+}
+
+func (s *APIServer) startImportWorkers() {
+	if err := s.store.FailUnfinishedImportJobs(); err != nil {
+		log.Println("error failing unfinished import jobs:", err)
+	}
+
+	for i := 0; i < importWorkers; i++ {
+		go func() {
+			for task := range s.importQueue {
+				s.runImportJob(task)
+			}
+		}()
+	}
+}
+
+func (s *APIServer) handleCreateImportJob(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseMultipartForm(maxImportFileSize); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "file is required")
+		return
+	}
+	defer file.Close()
+
+	format := types.ImportFormat(r.FormValue("format"))
+	if format == "" {
+		format = types.ImportFormat(strings.TrimPrefix(strings.ToLower(filepath.Ext(header.Filename)), "."))
+	}
+
+	dryRun, _ := strconv.ParseBool(r.FormValue("dryRun"))
+	task := &importTask{store: s.storeFor(r)}
+
+	switch format {
+	case types.ImportFormatOFX:
+		task.accountID, err = uuid.Parse(r.FormValue("accountId"))
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "accountId is required")
+			return
+		}
+		if _, err := task.store.GetAccountByID(task.accountID); err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		task.categoryID, err = uuid.Parse(r.FormValue("categoryId"))
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "categoryId is required")
+			return
+		}
+		if _, err := task.store.GetCategoryByID(task.categoryID); err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	case types.ImportFormatCSV:
+		profileID, err := uuid.Parse(r.FormValue("profileId"))
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "profileId is required")
+			return
+		}
+
+		task.profile, err = task.store.GetCSVProfileByID(profileID)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		if task.profile.CreditCardID != nil {
+			task.creditCard, err = task.store.GetCreditCardByID(*task.profile.CreditCardID)
+			if err != nil {
+				respondWithError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+		}
+	case types.ImportFormatXlsx:
+		task.xlsxConfig = importer.DefaultXlsxConfig()
+		if config := r.FormValue("config"); config != "" {
+			if err := json.Unmarshal([]byte(config), task.xlsxConfig); err != nil {
+				respondWithError(w, http.StatusBadRequest, fmt.Sprintf("invalid config: %s", err.Error()))
+				return
+			}
+		}
+	default:
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("format %q is not supported, use xlsx, csv or ofx", format))
+		return
+	}
+
+	task.data, err = io.ReadAll(file)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	task.job = &types.ImportJob{
+		ID:             uuid.Must(uuid.NewV7()),
+		Format:         format,
+		FileName:       header.Filename,
+		Status:         types.ImportJobStatusPending,
+		DryRun:         dryRun,
+		Errors:         []*types.ImportRowError{},
+		TransactionIDs: []uuid.UUID{},
+		CreatedAt:      time.Now().UTC(),
+		UpdatedAt:      time.Now().UTC(),
+	}
+
+	if err := task.store.CreateImportJob(task.job); err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	select {
+	case s.importQueue <- task:
+	default:
+		task.job.Status = types.ImportJobStatusFailed
+		task.job.Error = "import queue is full"
+		s.finishImportJob(task.job)
+		respondWithError(w, http.StatusServiceUnavailable, task.job.Error)
+		return
+	}
+
+	respondWithJSON(w, http.StatusAccepted, task.job)
+}
+
+func (s *APIServer) runImportJob(task *importTask) {
+	job := task.job
+	job.Status = types.ImportJobStatusRunning
+	if err := s.store.UpdateImportJob(job); err != nil {
+		log.Println("error updating import job", job.ID, err)
+	}
+
+	report := importer.NewReport(job.DryRun)
+	report.OnProgress = func(processed int) {
+		job.TotalRows = report.Total
+		job.ProcessedRows = processed
+		if processed%importProgressRow == 0 {
+			if err := s.store.UpdateImportJob(job); err != nil {
+				log.Println("error updating import job", job.ID, err)
+			}
+		}
+	}
+
+	var err error
+	switch job.Format {
+	case types.ImportFormatOFX:
+		var lines []*importer.StatementLine
+		lines, err = importer.ParseOFX(bytes.NewReader(task.data))
+		if err == nil {
+			err = importer.ImportStatement(task.store, job.FileName, lines, task.accountID, task.categoryID, report)
+		}
+	case types.ImportFormatCSV:
+		var categories []*types.Category
+		var rows []*importer.CSVRow
+		categories, err = task.store.GetCategory()
+		if err == nil {
+			rows, err = importer.ParseCSV(bytes.NewReader(task.data), task.profile, categories)
+		}
+		if err == nil {
+			err = importer.ImportCSV(task.store, job.FileName, rows, task.profile, task.creditCard, report)
+		}
+	case types.ImportFormatXlsx:
+		var rows []*importer.XlsxRow
+		rows, err = importer.ParseXlsx(job.FileName, task.data, task.xlsxConfig, report)
+		if err == nil {
+			err = importer.ImportXlsx(task.store, job.FileName, rows, task.xlsxConfig, report)
+		}
+	}
+
+	job.TotalRows = report.Total
+	job.Created = len(report.Created)
+	job.Matched = report.Matched
+	job.Skipped = report.Skipped
+	job.Ignored = report.Ignored
+	job.Errors = report.Errors
+	if !job.DryRun {
+		for _, transaction := range report.Created {
+			job.TransactionIDs = append(job.TransactionIDs, transaction.ID)
+		}
+	}
+
+	job.Status = types.ImportJobStatusCompleted
+	if err != nil {
+		job.Status = types.ImportJobStatusFailed
+		job.Error = err.Error()
+	}
+
+	s.finishImportJob(job)
+}
+
+func (s *APIServer) finishImportJob(job *types.ImportJob) {
+	job.FinishedAt = lo.ToPtr(time.Now().UTC())
+	if err := s.store.UpdateImportJob(job); err != nil {
+		log.Println("error updating import job", job.ID, err)
+	}
+}
+
+func (s *APIServer) handleGetImportJob(w http.ResponseWriter, r *http.Request) {
+	id, err := getAndParseIDFromRequest(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	job, err := s.storeFor(r).GetImportJobByID(id)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, job)
+}
+
+// handleRevertImportJob archives every transaction created by a completed or failed import,
+// so the same file can be imported again
+func (s *APIServer) handleRevertImportJob(w http.ResponseWriter, r *http.Request) {
+	id, err := getAndParseIDFromRequest(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	store := s.storeFor(r)
+	job, err := store.GetImportJobByID(id)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	if job.DryRun || (job.Status != types.ImportJobStatusCompleted && job.Status != types.ImportJobStatusFailed) {
+		respondWithError(w, http.StatusBadRequest, "only completed or failed imports can be reverted")
+		return
+	}
+
+	// a failed import keeps the ids of the transactions created before it stopped
+	transactions, err := store.GetTransactionsByIDs(job.TransactionIDs)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	for _, transaction := range transactions {
+		if transaction.Reconciled {
+			respondWithError(w, http.StatusBadRequest,
+				fmt.Sprintf("transaction %v is reconciled, undo the reconciliation before reverting the import", transaction.ID))
+			return
+		}
+	}
+
+	for _, transaction := range transactions {
+		if !transaction.Archived {
+			if err := s.archiveTransaction(store, transaction); err != nil {
+				respondWithError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+		}
+
+		if err := s.store.DeleteImportFingerprintsByTransaction(transaction.ID); err != nil {
+			respondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+
+	job.Status = types.ImportJobStatusReverted
+	if err := s.store.UpdateImportJob(job); err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, job)
+}
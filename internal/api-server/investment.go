@@ -0,0 +1,550 @@
+package apiserver
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mdsavian/budget-tracker-api/internal/finance"
+	"github.com/mdsavian/budget-tracker-api/internal/importer"
+	"github.com/mdsavian/budget-tracker-api/internal/types"
+)
+
+// investmentCategory is the category of the transfers made by contributions and redemptions
+const investmentCategory = "investment"
+
+type CreateHoldingInput struct {
+	AccountID uuid.UUID       `json:"accountId"`
+	Asset     string          `json:"asset"`
+	AssetType types.AssetType `json:"assetType"`
+	// Quantity and CostBasis open the holding with a position bought before it was tracked
+	Quantity  float64 `json:"quantity"`
+	CostBasis float64 `json:"costBasis"`
+	Date      string  `json:"date"`
+}
+
+type CreateHoldingPriceInput struct {
+	Date  string  `json:"date"`
+	Price float64 `json:"price"`
+}
+
+type HoldingMovementInput struct {
+	// AccountID is the checking account the money comes from or goes to
+	AccountID  uuid.UUID  `json:"accountId"`
+	Amount     float64    `json:"amount"`
+	Quantity   float64    `json:"quantity"`
+	Date       string     `json:"date"`
+	CategoryID *uuid.UUID `json:"categoryId"`
+}
+
+type HoldingMovementResult struct {
+	Movement     *types.HoldingMovement `json:"movement"`
+	Holding      *types.Holding         `json:"holding"`
+	Transactions []*types.Transaction   `json:"transactions"`
+}
+
+type ImportHoldingPricesResult struct {
+	Imported int      `json:"imported"`
+	Errors   []string `json:"errors"`
+}
+
+func (s *APIServer) handleCreateHolding(w http.ResponseWriter, r *http.Request) {
+	input := CreateHoldingInput{}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	input.Asset = strings.TrimSpace(input.Asset)
+	if input.Asset == "" {
+		respondWithError(w, http.StatusBadRequest, "asset is required")
+		return
+	}
+	if !input.AssetType.Valid() {
+		respondWithError(w, http.StatusBadRequest, "assetType must be cdb, treasury, fund, stock or other")
+		return
+	}
+	if input.Quantity < 0 || input.CostBasis < 0 {
+		respondWithError(w, http.StatusBadRequest, "quantity and costBasis can't be negative")
+		return
+	}
+	if input.Quantity == 0 && input.CostBasis > 0 {
+		respondWithError(w, http.StatusBadRequest, "a costBasis needs a quantity")
+		return
+	}
+
+	if _, err := s.getInvestmentAccount(s.storeFor(r), input.AccountID); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	date := time.Now().UTC()
+	if input.Date != "" {
+		parsedDate, err := time.Parse("2006-01-02", input.Date)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "date is not a valid date")
+			return
+		}
+		date = parsedDate
+	}
+
+	store := s.storeFor(r)
+	holding := &types.Holding{
+		ID:        uuid.Must(uuid.NewV7()),
+		AccountID: input.AccountID,
+		Asset:     input.Asset,
+		AssetType: input.AssetType,
+		Quantity:  input.Quantity,
+		CostBasis: finance.RoundMoney(input.CostBasis),
+		CreatedAt: time.Now().UTC(),
+		UpdatedAt: time.Now().UTC(),
+	}
+
+	if err := store.CreateHolding(holding); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// the opening position is kept as a movement so the position can be rebuilt at any date
+	if holding.Quantity > 0 {
+		movement := &types.HoldingMovement{
+			ID:        uuid.Must(uuid.NewV7()),
+			HoldingID: holding.ID,
+			Kind:      types.HoldingMovementContribution,
+			Date:      date,
+			Amount:    holding.CostBasis,
+			Quantity:  holding.Quantity,
+			CostBasis: holding.CostBasis,
+			CreatedAt: time.Now().UTC(),
+		}
+		if err := store.CreateHoldingMovement(movement); err != nil {
+			respondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+
+	respondWithJSON(w, http.StatusOK, holding)
+}
+
+func (s *APIServer) handleGetHoldings(w http.ResponseWriter, r *http.Request) {
+	accountID, err := getAccountIDFromQuery(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	holdings, err := s.storeFor(r).GetHoldings(accountID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, holdings)
+}
+
+func (s *APIServer) handleGetHoldingByID(w http.ResponseWriter, r *http.Request) {
+	uID, err := getAndParseIDFromRequest(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	holding, err := s.storeFor(r).GetHoldingByID(uID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, holding)
+}
+
+func (s *APIServer) handleCreateHoldingPrice(w http.ResponseWriter, r *http.Request) {
+	uID, err := getAndParseIDFromRequest(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	input := CreateHoldingPriceInput{}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if input.Price <= 0 {
+		respondWithError(w, http.StatusBadRequest, "price must be greater than zero")
+		return
+	}
+
+	date, err := time.Parse("2006-01-02", input.Date)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "date is not a valid date")
+		return
+	}
+
+	if _, err := s.storeFor(r).GetHoldingByID(uID); err != nil {
+		respondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	price := &types.HoldingPrice{
+		ID:        uuid.Must(uuid.NewV7()),
+		HoldingID: uID,
+		Date:      date,
+		Price:     input.Price,
+		Source:    types.HoldingPriceSourceManual,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	if err := s.storeFor(r).SaveHoldingPrice(price); err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, price)
+}
+
+func (s *APIServer) handleGetHoldingPrices(w http.ResponseWriter, r *http.Request) {
+	uID, err := getAndParseIDFromRequest(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	prices, err := s.storeFor(r).GetHoldingPrices(&uID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, prices)
+}
+
+func (s *APIServer) handleGetHoldingMovements(w http.ResponseWriter, r *http.Request) {
+	uID, err := getAndParseIDFromRequest(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	movements, err := s.storeFor(r).GetHoldingMovements(&uID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, movements)
+}
+
+// handleImportHoldingPrices reads a csv with a header line followed by asset, date and price
+// columns. Each price is saved for the holdings of that asset, in the accountId form field
+// account when given. Lines that can't be imported are reported and skipped.
+func (s *APIServer) handleImportHoldingPrices(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseMultipartForm(maxImportFileSize); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var accountID *uuid.UUID
+	if value := r.FormValue("accountId"); value != "" {
+		parsedAccountID, err := uuid.Parse(value)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "accountId is not a valid id")
+			return
+		}
+		accountID = &parsedAccountID
+	}
+
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "file is required")
+		return
+	}
+	defer file.Close()
+
+	holdings, err := s.storeFor(r).GetHoldings(accountID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	holdingsByAsset := map[string][]*types.Holding{}
+	for _, holding := range holdings {
+		asset := strings.ToLower(holding.Asset)
+		holdingsByAsset[asset] = append(holdingsByAsset[asset], holding)
+	}
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = 3
+	reader.TrimLeadingSpace = true
+
+	result := &ImportHoldingPricesResult{Errors: []string{}}
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if line == 1 {
+			continue
+		}
+
+		matched, ok := holdingsByAsset[strings.ToLower(strings.TrimSpace(record[0]))]
+		if !ok {
+			result.Errors = append(result.Errors, fmt.Sprintf("line %d: no holding of asset %s", line, record[0]))
+			continue
+		}
+
+		date, err := time.Parse("2006-01-02", strings.TrimSpace(record[1]))
+		if err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("line %d: date is not a valid date", line))
+			continue
+		}
+
+		value, err := strconv.ParseFloat(strings.ReplaceAll(strings.TrimSpace(record[2]), ",", "."), 64)
+		if err != nil || value <= 0 {
+			result.Errors = append(result.Errors, fmt.Sprintf("line %d: price must be a number greater than zero", line))
+			continue
+		}
+
+		for _, holding := range matched {
+			price := &types.HoldingPrice{
+				ID:        uuid.Must(uuid.NewV7()),
+				HoldingID: holding.ID,
+				Date:      date,
+				Price:     value,
+				Source:    types.HoldingPriceSourceImport,
+				CreatedAt: time.Now().UTC(),
+			}
+			if err := s.storeFor(r).SaveHoldingPrice(price); err != nil {
+				respondWithError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+			result.Imported++
+		}
+	}
+
+	respondWithJSON(w, http.StatusOK, result)
+}
+
+func (s *APIServer) handleCreateContribution(w http.ResponseWriter, r *http.Request) {
+	s.handleHoldingMovement(w, r, types.HoldingMovementContribution)
+}
+
+func (s *APIServer) handleCreateRedemption(w http.ResponseWriter, r *http.Request) {
+	s.handleHoldingMovement(w, r, types.HoldingMovementRedemption)
+}
+
+// handleHoldingMovement moves money between a checking account and the holding's investment
+// account with two fulfilled transactions linked by a transfer id, then updates the holding,
+// all saved together. A redemption takes its cost basis out at the average cost of the holding.
+func (s *APIServer) handleHoldingMovement(w http.ResponseWriter, r *http.Request, kind types.HoldingMovementKind) {
+	uID, err := getAndParseIDFromRequest(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	input := HoldingMovementInput{}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	input.Amount = finance.RoundMoney(input.Amount)
+	if input.Amount <= 0 || input.Quantity <= 0 {
+		respondWithError(w, http.StatusBadRequest, "amount and quantity must be greater than zero")
+		return
+	}
+
+	date := time.Now().UTC()
+	if input.Date != "" {
+		date, err = time.Parse("2006-01-02", input.Date)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "date is not a valid date")
+			return
+		}
+	}
+
+	holding, err := s.storeFor(r).GetHoldingByID(uID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	investmentAccount, err := s.getInvestmentAccount(s.storeFor(r), holding.AccountID)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	account, err := s.storeFor(r).GetAccountByID(input.AccountID)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if account.Kind != types.AccountKindChecking {
+		respondWithError(w, http.StatusBadRequest, "contributions and redemptions must be made from a checking account")
+		return
+	}
+
+	costBasis := input.Amount
+	if kind == types.HoldingMovementRedemption {
+		if input.Quantity > holding.Quantity {
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("the holding has only %v to redeem", holding.Quantity))
+			return
+		}
+		costBasis = holding.CostBasis
+		if input.Quantity < holding.Quantity {
+			costBasis = finance.RoundMoney(holding.CostBasis * input.Quantity / holding.Quantity)
+		}
+	}
+
+	store := s.storeFor(r)
+
+	var categoryID uuid.UUID
+	if input.CategoryID != nil {
+		if _, err := store.GetCategoryByID(*input.CategoryID); err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		categoryID = *input.CategoryID
+	} else {
+		category, err := importer.GetOrCreateCategory(investmentCategory, store)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		categoryID = category.ID
+	}
+
+	from, to := account, investmentAccount
+	description := "Contribution to " + holding.Asset
+	if kind == types.HoldingMovementRedemption {
+		from, to = investmentAccount, account
+		description = "Redemption of " + holding.Asset
+	}
+
+	transferID := uuid.Must(uuid.NewV7())
+	transactions := []*types.Transaction{}
+	for _, side := range []struct {
+		account         *types.Account
+		transactionType types.TransactionType
+	}{
+		{from, types.TransactionTypeDebit},
+		{to, types.TransactionTypeCredit},
+	} {
+		transaction := &types.Transaction{
+			ID:              uuid.Must(uuid.NewV7()),
+			AccountID:       side.account.ID,
+			CategoryID:      categoryID,
+			TransactionType: side.transactionType,
+			Date:            date,
+			EffectuatedDate: &date,
+			Description:     description,
+			Amount:          float32(input.Amount),
+			Fulfilled:       true,
+			TransferID:      &transferID,
+			CreatedAt:       time.Now().UTC(),
+			UpdatedAt:       time.Now().UTC(),
+		}
+		transactions = append(transactions, transaction)
+	}
+
+	if kind == types.HoldingMovementContribution {
+		holding.Quantity += input.Quantity
+		holding.CostBasis = finance.RoundMoney(holding.CostBasis + costBasis)
+	} else {
+		holding.Quantity -= input.Quantity
+		holding.CostBasis = finance.RoundMoney(holding.CostBasis - costBasis)
+	}
+	holding.UpdatedAt = time.Now().UTC()
+
+	movement := &types.HoldingMovement{
+		ID:         uuid.Must(uuid.NewV7()),
+		HoldingID:  holding.ID,
+		TransferID: &transferID,
+		Kind:       kind,
+		Date:       date,
+		Amount:     input.Amount,
+		Quantity:   input.Quantity,
+		CostBasis:  costBasis,
+		CreatedAt:  time.Now().UTC(),
+	}
+
+	if err := store.SaveHoldingMovement(movement, holding, transactions); err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, &HoldingMovementResult{Movement: movement, Holding: holding, Transactions: transactions})
+}
+
+func (s *APIServer) handleGetPositions(w http.ResponseWriter, r *http.Request) {
+	accountID, err := getAccountIDFromQuery(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	positions, err := s.storeFor(r).GetPositions(accountID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	for _, position := range positions {
+		valuePosition(position)
+	}
+
+	respondWithJSON(w, http.StatusOK, positions)
+}
+
+// valuePosition sets the value and the unrealized return of the position
+func valuePosition(position *types.Position) {
+	position.Value = position.CostBasis
+	if position.Price != nil {
+		position.Value = finance.RoundMoney(position.Quantity * *position.Price)
+	}
+	position.Return = finance.RoundMoney(position.Value - position.CostBasis)
+	position.Realized = finance.RoundMoney(position.Realized)
+
+	if position.CostBasis > 0 {
+		percent := finance.RoundMoney(position.Return / position.CostBasis * 100)
+		position.ReturnPercent = &percent
+	}
+}
+
+func (s *APIServer) getInvestmentAccount(store Storage, accountID uuid.UUID) (*types.Account, error) {
+	account, err := store.GetAccountByID(accountID)
+	if err != nil {
+		return nil, err
+	}
+	if account.Kind != types.AccountKindInvestment {
+		return nil, fmt.Errorf("account %v is not an investment account", accountID)
+	}
+	return account, nil
+}
+
+func getAccountIDFromQuery(r *http.Request) (*uuid.UUID, error) {
+	value := r.URL.Query().Get("accountId")
+	if value == "" {
+		return nil, nil
+	}
+
+	accountID, err := uuid.Parse(value)
+	if err != nil {
+		return nil, errors.New("accountId is not a valid id")
+	}
+	return &accountID, nil
+}
@@ -0,0 +1,344 @@
+package apiserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mdsavian/budget-tracker-api/internal/finance"
+	"github.com/mdsavian/budget-tracker-api/internal/importer"
+	"github.com/mdsavian/budget-tracker-api/internal/types"
+)
+
+const (
+	// loanCategory is the category of the loan installments created without one
+	loanCategory = "loan"
+	maxLoanTerm  = 600
+)
+
+type CreateLoanInput struct {
+	Description string                   `json:"description"`
+	AccountID   uuid.UUID                `json:"accountId"`
+	CategoryID  *uuid.UUID               `json:"categoryId"`
+	Principal   float64                  `json:"principal"`
+	Rate        float64                  `json:"rate"`
+	Term        int                      `json:"term"`
+	System      types.AmortizationSystem `json:"system"`
+	StartDate   string                   `json:"startDate"`
+}
+
+type LoanExtraPaymentInput struct {
+	Amount float64 `json:"amount"`
+	Date   string  `json:"date"`
+	// AccountID pays the extra payment, the loan account when not given
+	AccountID *uuid.UUID `json:"accountId"`
+	// Reduce is term to keep the installment and pay off sooner, or payment to keep the term
+	Reduce string `json:"reduce"`
+}
+
+// handleCreateLoan creates the loan and its schedule, one planned debit per installment
+func (s *APIServer) handleCreateLoan(w http.ResponseWriter, r *http.Request) {
+	input := CreateLoanInput{}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	input.Description = strings.TrimSpace(input.Description)
+	if input.Description == "" {
+		respondWithError(w, http.StatusBadRequest, "description is required")
+		return
+	}
+	if input.Principal <= 0 {
+		respondWithError(w, http.StatusBadRequest, "principal must be greater than zero")
+		return
+	}
+	if input.Rate < 0 {
+		respondWithError(w, http.StatusBadRequest, "rate can't be negative")
+		return
+	}
+	if input.Term < 1 || input.Term > maxLoanTerm {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("term must be between 1 and %d months", maxLoanTerm))
+		return
+	}
+	if !input.System.Valid() {
+		respondWithError(w, http.StatusBadRequest, "system must be sac or price")
+		return
+	}
+
+	startDate, err := time.Parse("2006-01-02", input.StartDate)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "startDate is not a valid date")
+		return
+	}
+
+	if _, err := s.storeFor(r).GetAccountByID(input.AccountID); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	store := s.storeFor(r)
+
+	var categoryID uuid.UUID
+	if input.CategoryID != nil {
+		if _, err := store.GetCategoryByID(*input.CategoryID); err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		categoryID = *input.CategoryID
+	} else {
+		category, err := importer.GetOrCreateCategory(loanCategory, store)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		categoryID = category.ID
+	}
+
+	loan := &types.Loan{
+		ID:          uuid.Must(uuid.NewV7()),
+		Description: input.Description,
+		AccountID:   input.AccountID,
+		CategoryID:  categoryID,
+		Principal:   finance.RoundMoney(input.Principal),
+		Rate:        input.Rate,
+		Term:        input.Term,
+		System:      input.System,
+		StartDate:   startDate,
+		CreatedAt:   time.Now().UTC(),
+		UpdatedAt:   time.Now().UTC(),
+		Outstanding: finance.RoundMoney(input.Principal),
+	}
+
+	if err := store.CreateLoan(loan); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	schedule := finance.Schedule(loan.System, loan.Principal, finance.MonthlyRate(loan.Rate), loan.Term, loan.StartDate)
+	transactions, installments := newLoanInstallments(loan, schedule, 0)
+	if err := store.SaveLoanInstallments(transactions, installments, nil); err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, &types.LoanSchedule{Loan: loan, Installments: installments})
+}
+
+func (s *APIServer) handleGetLoans(w http.ResponseWriter, r *http.Request) {
+	loans, err := s.storeFor(r).GetLoans()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, loans)
+}
+
+func (s *APIServer) handleGetLoanByID(w http.ResponseWriter, r *http.Request) {
+	uID, err := getAndParseIDFromRequest(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	loan, err := s.storeFor(r).GetLoanByID(uID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	installments, err := s.storeFor(r).GetLoanInstallments(&loan.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, &types.LoanSchedule{Loan: loan, Installments: installments})
+}
+
+// handleLoanExtraPayment pays principal ahead of the schedule with a fulfilled debit, then
+// replaces the unpaid installments by a schedule recalculated from the outstanding principal.
+// The payment and the new schedule are saved together.
+func (s *APIServer) handleLoanExtraPayment(w http.ResponseWriter, r *http.Request) {
+	uID, err := getAndParseIDFromRequest(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	input := LoanExtraPaymentInput{}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if input.Reduce == "" {
+		input.Reduce = "term"
+	}
+	if input.Reduce != "term" && input.Reduce != "payment" {
+		respondWithError(w, http.StatusBadRequest, "reduce must be term or payment")
+		return
+	}
+
+	input.Amount = finance.RoundMoney(input.Amount)
+	if input.Amount <= 0 {
+		respondWithError(w, http.StatusBadRequest, "amount must be greater than zero")
+		return
+	}
+
+	date := time.Now().UTC()
+	if input.Date != "" {
+		date, err = time.Parse("2006-01-02", input.Date)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "date is not a valid date")
+			return
+		}
+	}
+
+	loan, err := s.storeFor(r).GetLoanByID(uID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	if input.Amount > finance.RoundMoney(loan.Outstanding) {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("amount is more than the outstanding principal of %.2f", loan.Outstanding))
+		return
+	}
+
+	accountID := loan.AccountID
+	if input.AccountID != nil {
+		if _, err := s.storeFor(r).GetAccountByID(*input.AccountID); err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		accountID = *input.AccountID
+	}
+
+	installments, err := s.storeFor(r).GetLoanInstallments(&loan.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	store := s.storeFor(r)
+
+	transaction := &types.Transaction{
+		ID:              uuid.Must(uuid.NewV7()),
+		AccountID:       accountID,
+		CategoryID:      loan.CategoryID,
+		TransactionType: types.TransactionTypeDebit,
+		Date:            date,
+		EffectuatedDate: &date,
+		Description:     loan.Description + " extra payment",
+		Amount:          float32(input.Amount),
+		Fulfilled:       true,
+		CreatedAt:       time.Now().UTC(),
+		UpdatedAt:       time.Now().UTC(),
+	}
+
+	outstanding := finance.RoundMoney(loan.Outstanding - input.Amount)
+	extra := &types.LoanInstallment{
+		ID:            uuid.Must(uuid.NewV7()),
+		LoanID:        loan.ID,
+		TransactionID: transaction.ID,
+		DueDate:       date,
+		Payment:       input.Amount,
+		Amortization:  input.Amount,
+		Balance:       outstanding,
+		Extra:         true,
+		CreatedAt:     time.Now().UTC(),
+		Paid:          true,
+	}
+
+	transactions, rescheduled, replaced := rescheduleLoan(loan, installments, outstanding, input.Reduce == "term")
+	transactions = append([]*types.Transaction{transaction}, transactions...)
+	rescheduled = append([]*types.LoanInstallment{extra}, rescheduled...)
+	if err := store.SaveLoanInstallments(transactions, rescheduled, replaced); err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	loan.Outstanding = outstanding
+	installments, err = store.GetLoanInstallments(&loan.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, &types.LoanSchedule{Loan: loan, Installments: installments})
+}
+
+// rescheduleLoan replaces the unpaid installments by a schedule of the outstanding principal
+// from the first of them, returning the new installments with their planned debits and the
+// installments they replace. keepInstallment shortens the term keeping the installment,
+// otherwise the remaining term is kept with smaller installments.
+func rescheduleLoan(loan *types.Loan, installments []*types.LoanInstallment, outstanding float64, keepInstallment bool) ([]*types.Transaction, []*types.LoanInstallment, []*types.LoanInstallment) {
+	unpaid := []*types.LoanInstallment{}
+	for _, installment := range installments {
+		if !installment.Paid && !installment.Extra {
+			unpaid = append(unpaid, installment)
+		}
+	}
+	if len(unpaid) == 0 {
+		return nil, nil, nil
+	}
+
+	next := unpaid[0]
+	monthlyRate := finance.MonthlyRate(loan.Rate)
+	term := len(unpaid)
+	if keepInstallment {
+		term = finance.Term(loan.System, outstanding, monthlyRate, finance.Installment{
+			Payment:      next.Payment,
+			Amortization: next.Amortization,
+		})
+	}
+
+	schedule := finance.Schedule(loan.System, outstanding, monthlyRate, term, next.DueDate)
+	transactions, rescheduled := newLoanInstallments(loan, schedule, next.Number-1)
+	return transactions, rescheduled, unpaid
+}
+
+// newLoanInstallments builds the installments of the schedule with a planned debit for each,
+// numbered after the installments already paid
+func newLoanInstallments(loan *types.Loan, schedule []finance.Installment, paid int) ([]*types.Transaction, []*types.LoanInstallment) {
+	total := strconv.Itoa(paid + len(schedule))
+
+	transactions := []*types.Transaction{}
+	installments := []*types.LoanInstallment{}
+	for _, row := range schedule {
+		number := paid + row.Number
+		transaction := &types.Transaction{
+			ID:              uuid.Must(uuid.NewV7()),
+			AccountID:       loan.AccountID,
+			CategoryID:      loan.CategoryID,
+			TransactionType: types.TransactionTypeDebit,
+			Date:            row.DueDate,
+			Description:     loan.Description + " (" + strconv.Itoa(number) + "/" + total + ")",
+			Amount:          float32(row.Payment),
+			CreatedAt:       time.Now().UTC(),
+			UpdatedAt:       time.Now().UTC(),
+		}
+		transactions = append(transactions, transaction)
+
+		installment := &types.LoanInstallment{
+			ID:            uuid.Must(uuid.NewV7()),
+			LoanID:        loan.ID,
+			TransactionID: transaction.ID,
+			Number:        number,
+			DueDate:       row.DueDate,
+			Payment:       row.Payment,
+			Interest:      row.Interest,
+			Amortization:  row.Amortization,
+			Balance:       row.Balance,
+			CreatedAt:     time.Now().UTC(),
+		}
+		installments = append(installments, installment)
+	}
+	return transactions, installments
+}
@@ -0,0 +1,322 @@
+package apiserver
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mdsavian/budget-tracker-api/internal/finance"
+	"github.com/mdsavian/budget-tracker-api/internal/importer"
+	"github.com/mdsavian/budget-tracker-api/internal/types"
+)
+
+// payoffCategory is the category of the saved plan debits made without one
+const payoffCategory = "debt payoff"
+
+// PayoffCardInput is a card balance being carried, cards carry no rate of their own
+type PayoffCardInput struct {
+	CreditCardID   uuid.UUID `json:"creditCardId"`
+	Rate           float64   `json:"rate"`
+	MinimumPayment float64   `json:"minimumPayment"`
+}
+
+type PayoffPlanInput struct {
+	Extra     float64           `json:"extra"`
+	StartDate string            `json:"startDate"`
+	Cards     []PayoffCardInput `json:"cards"`
+}
+
+type SavePayoffPlanInput struct {
+	PayoffPlanInput
+	Strategy   types.PayoffStrategy `json:"strategy"`
+	AccountID  uuid.UUID            `json:"accountId"`
+	CategoryID *uuid.UUID           `json:"categoryId"`
+}
+
+type PayoffComparison struct {
+	Snowball  *types.PayoffPlan `json:"snowball"`
+	Avalanche *types.PayoffPlan `json:"avalanche"`
+}
+
+// handleGetPayoffPlan plans paying off the loans and the given card balances with an extra
+// monthly budget, for both the snowball and the avalanche strategies
+func (s *APIServer) handleGetPayoffPlan(w http.ResponseWriter, r *http.Request) {
+	input := PayoffPlanInput{}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	debts, start, ok := s.getPayoffDebts(w, s.storeFor(r), input)
+	if !ok {
+		return
+	}
+
+	snowball, err := finance.Payoff(debts, input.Extra, start, types.PayoffSnowball)
+	if err != nil {
+		respondWithError(w, payoffErrorStatus(err), err.Error())
+		return
+	}
+
+	avalanche, err := finance.Payoff(debts, input.Extra, start, types.PayoffAvalanche)
+	if err != nil {
+		respondWithError(w, payoffErrorStatus(err), err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, &PayoffComparison{Snowball: snowball, Avalanche: avalanche})
+}
+
+// handleSavePayoffPlan saves the extra payments of the plan as planned debits, one per month and
+// debt receiving them up to its payoff date. The extra payments of a loan are also kept as extra
+// installments of the loan, so fulfilling their debit amortizes the outstanding principal, and
+// the unpaid installments are rescheduled over what the extras leave of the principal. The plan
+// is saved in a single database transaction.
+func (s *APIServer) handleSavePayoffPlan(w http.ResponseWriter, r *http.Request) {
+	input := SavePayoffPlanInput{}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if !input.Strategy.Valid() {
+		respondWithError(w, http.StatusBadRequest, "strategy must be snowball or avalanche")
+		return
+	}
+	if input.Extra <= 0 {
+		respondWithError(w, http.StatusBadRequest, "extra must be greater than zero to save a plan")
+		return
+	}
+
+	store := s.storeFor(r)
+
+	if _, err := store.GetAccountByID(input.AccountID); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	debts, start, ok := s.getPayoffDebts(w, store, input.PayoffPlanInput)
+	if !ok {
+		return
+	}
+
+	plan, err := finance.Payoff(debts, input.Extra, start, input.Strategy)
+	if err != nil {
+		respondWithError(w, payoffErrorStatus(err), err.Error())
+		return
+	}
+	if len(plan.Months) == 0 {
+		respondWithError(w, http.StatusBadRequest, "there are no debts to pay off")
+		return
+	}
+
+	var categoryID uuid.UUID
+	if input.CategoryID != nil {
+		if _, err := store.GetCategoryByID(*input.CategoryID); err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		categoryID = *input.CategoryID
+	} else {
+		category, err := importer.GetOrCreateCategory(payoffCategory, store)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		categoryID = category.ID
+	}
+
+	debtsByID := map[uuid.UUID]*types.PayoffDebt{}
+	for _, debt := range debts {
+		debtsByID[debt.ID] = debt
+	}
+	payoffDates := map[uuid.UUID]time.Time{}
+	for _, result := range plan.Debts {
+		payoffDates[result.ID] = result.PayoffDate
+	}
+
+	loanExtras := map[uuid.UUID]float64{}
+	transactions := []*types.Transaction{}
+	installments := []*types.LoanInstallment{}
+	for _, month := range plan.Months {
+		for _, payment := range month.Payments {
+			payoffDate := payoffDates[payment.DebtID]
+			if payment.Extra <= 0 || (!payoffDate.IsZero() && month.Date.After(payoffDate)) {
+				continue
+			}
+
+			debt := debtsByID[payment.DebtID]
+			transaction := &types.Transaction{
+				ID:              uuid.Must(uuid.NewV7()),
+				AccountID:       input.AccountID,
+				CategoryID:      categoryID,
+				TransactionType: types.TransactionTypeDebit,
+				Date:            month.Date,
+				Description:     fmt.Sprintf("Debt payoff (%s): %s", plan.Strategy, debt.Description),
+				Amount:          float32(finance.RoundMoney(payment.Extra)),
+				CreatedAt:       time.Now().UTC(),
+				UpdatedAt:       time.Now().UTC(),
+			}
+			transactions = append(transactions, transaction)
+
+			if debt.Kind != types.PayoffDebtLoan {
+				continue
+			}
+
+			extra := &types.LoanInstallment{
+				ID:            uuid.Must(uuid.NewV7()),
+				LoanID:        debt.ID,
+				TransactionID: transaction.ID,
+				DueDate:       month.Date,
+				Payment:       finance.RoundMoney(payment.Extra),
+				Amortization:  finance.RoundMoney(payment.Extra),
+				Balance:       finance.RoundMoney(payment.Balance),
+				Extra:         true,
+				CreatedAt:     time.Now().UTC(),
+			}
+			installments = append(installments, extra)
+			loanExtras[debt.ID] += extra.Amortization
+		}
+	}
+
+	// the installments keep their payment and the term shortens, so the loan ends near its
+	// payoff date and the installments after it are archived instead of over-amortizing
+	scheduled := []*types.Transaction{}
+	replaced := []*types.LoanInstallment{}
+	for _, debt := range debts {
+		extras, ok := loanExtras[debt.ID]
+		if !ok {
+			continue
+		}
+
+		loan, err := store.GetLoanByID(debt.ID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		loanInstallments, err := store.GetLoanInstallments(&loan.ID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		loanTransactions, rescheduled, loanReplaced := rescheduleLoan(loan, loanInstallments, finance.RoundMoney(debt.Balance-extras), true)
+		scheduled = append(scheduled, loanTransactions...)
+		installments = append(installments, rescheduled...)
+		replaced = append(replaced, loanReplaced...)
+	}
+
+	if err := store.SaveLoanInstallments(append(transactions, scheduled...), installments, replaced); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, transactions)
+}
+
+// getPayoffDebts gathers the outstanding loans, paid at their next installment, and the unpaid
+// balance of the given cards. The plan starts on the start date or today.
+func (s *APIServer) getPayoffDebts(w http.ResponseWriter, store Storage, input PayoffPlanInput) ([]*types.PayoffDebt, time.Time, bool) {
+	if input.Extra < 0 {
+		respondWithError(w, http.StatusBadRequest, "extra can't be negative")
+		return nil, time.Time{}, false
+	}
+
+	start := time.Now().UTC()
+	if input.StartDate != "" {
+		parsedStart, err := time.Parse("2006-01-02", input.StartDate)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "startDate is not a valid date")
+			return nil, time.Time{}, false
+		}
+		start = parsedStart
+	}
+
+	loans, err := store.GetLoans()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return nil, time.Time{}, false
+	}
+
+	installments, err := store.GetLoanInstallments(nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return nil, time.Time{}, false
+	}
+
+	nextPayment := map[uuid.UUID]float64{}
+	for _, installment := range installments {
+		if _, ok := nextPayment[installment.LoanID]; !ok && !installment.Paid && !installment.Extra {
+			nextPayment[installment.LoanID] = installment.Payment
+		}
+	}
+
+	debts := []*types.PayoffDebt{}
+	for _, loan := range loans {
+		if finance.RoundMoney(loan.Outstanding) <= 0 {
+			continue
+		}
+
+		debts = append(debts, &types.PayoffDebt{
+			ID:             loan.ID,
+			Kind:           types.PayoffDebtLoan,
+			Description:    loan.Description,
+			Balance:        finance.RoundMoney(loan.Outstanding),
+			Rate:           loan.Rate,
+			MinimumPayment: nextPayment[loan.ID],
+		})
+	}
+
+	for _, card := range input.Cards {
+		if card.Rate < 0 || card.MinimumPayment < 0 {
+			respondWithError(w, http.StatusBadRequest, "card rate and minimumPayment can't be negative")
+			return nil, time.Time{}, false
+		}
+
+		creditCard, err := store.GetCreditCardByID(card.CreditCardID)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return nil, time.Time{}, false
+		}
+
+		transactions, err := store.GetUnpaidCreditCardTransactions(creditCard.ID, time.Time{})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, err.Error())
+			return nil, time.Time{}, false
+		}
+
+		var balance float64
+		for _, transaction := range transactions {
+			if transaction.TransactionType == types.TransactionTypeCredit {
+				balance -= float64(transaction.Amount)
+			} else {
+				balance += float64(transaction.Amount)
+			}
+		}
+		if finance.RoundMoney(balance) <= 0 {
+			continue
+		}
+
+		debts = append(debts, &types.PayoffDebt{
+			ID:             creditCard.ID,
+			Kind:           types.PayoffDebtCreditCard,
+			Description:    creditCard.Name,
+			Balance:        finance.RoundMoney(balance),
+			Rate:           card.Rate,
+			MinimumPayment: card.MinimumPayment,
+		})
+	}
+
+	return debts, start, true
+}
+
+func payoffErrorStatus(err error) int {
+	if errors.Is(err, finance.ErrNoPayoff) {
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
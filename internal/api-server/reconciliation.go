@@ -0,0 +1,305 @@
+package apiserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mdsavian/budget-tracker-api/internal/types"
+)
+
+func (s *APIServer) handleCreateReconciliation(w http.ResponseWriter, r *http.Request) {
+	type CreateReconciliationInput struct {
+		AccountID        uuid.UUID `json:"accountId"`
+		StatementDate    string    `json:"statementDate"`
+		StatementBalance float32   `json:"statementBalance"`
+	}
+
+	input := CreateReconciliationInput{}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	statementDate, err := time.Parse("2006-01-02", input.StatementDate)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "statementDate is not a valid date")
+		return
+	}
+
+	store := s.storeFor(r)
+
+	account, err := store.GetAccountByID(input.AccountID)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if !account.Kind.Reconcilable() {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("%s accounts have no statements to reconcile", account.Kind))
+		return
+	}
+
+	open, err := store.GetOpenReconciliation(input.AccountID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if open != nil {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("account already has the open reconciliation %v", open.ID))
+		return
+	}
+
+	reconciliation := &types.Reconciliation{
+		ID:               uuid.Must(uuid.NewV7()),
+		AccountID:        input.AccountID,
+		StatementDate:    statementDate,
+		StatementBalance: input.StatementBalance,
+		Status:           types.ReconciliationStatusOpen,
+		CreatedAt:        time.Now().UTC(),
+		UpdatedAt:        time.Now().UTC(),
+	}
+
+	if err := store.CreateReconciliation(reconciliation); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	s.respondWithReconciliation(w, store, reconciliation)
+}
+
+func (s *APIServer) handleGetReconciliations(w http.ResponseWriter, r *http.Request) {
+	var accountID *uuid.UUID
+	if value := r.URL.Query().Get("accountId"); value != "" {
+		parsedAccountID, err := uuid.Parse(value)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "accountId is not a valid id")
+			return
+		}
+		accountID = &parsedAccountID
+	}
+
+	reconciliations, err := s.storeFor(r).GetReconciliations(accountID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, reconciliations)
+}
+
+func (s *APIServer) handleGetReconciliation(w http.ResponseWriter, r *http.Request) {
+	reconciliation, ok := s.getReconciliationFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	s.respondWithReconciliation(w, s.storeFor(r), reconciliation)
+}
+
+func (s *APIServer) handleClearReconciliationTransactions(w http.ResponseWriter, r *http.Request) {
+	s.updateReconciliationTransactions(w, r, true)
+}
+
+func (s *APIServer) handleUnclearReconciliationTransactions(w http.ResponseWriter, r *http.Request) {
+	s.updateReconciliationTransactions(w, r, false)
+}
+
+func (s *APIServer) updateReconciliationTransactions(w http.ResponseWriter, r *http.Request, clear bool) {
+	type ReconciliationTransactionsInput struct {
+		TransactionIDs []uuid.UUID `json:"transactionIds"`
+	}
+
+	reconciliation, ok := s.getReconciliationFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	input := ReconciliationTransactionsInput{}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if len(input.TransactionIDs) == 0 {
+		respondWithError(w, http.StatusBadRequest, "transactionIds is required")
+		return
+	}
+
+	if reconciliation.Status != types.ReconciliationStatusOpen {
+		respondWithError(w, http.StatusBadRequest, "reconciliation is completed, undo it to change its transactions")
+		return
+	}
+
+	var updated int64
+	var err error
+	if clear {
+		updated, err = s.storeFor(r).ClearTransactions(reconciliation.ID, reconciliation.AccountID, input.TransactionIDs)
+	} else {
+		updated, err = s.storeFor(r).UnclearTransactions(reconciliation.ID, input.TransactionIDs)
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if updated != int64(len(input.TransactionIDs)) {
+		respondWithError(w, http.StatusBadRequest,
+			fmt.Sprintf("%d of %d transactions updated, the others are not fulfilled transactions of the account available in this reconciliation",
+				updated, len(input.TransactionIDs)))
+		return
+	}
+
+	s.respondWithReconciliation(w, s.storeFor(r), reconciliation)
+}
+
+func (s *APIServer) handleCompleteReconciliation(w http.ResponseWriter, r *http.Request) {
+	reconciliation, ok := s.getReconciliationFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if reconciliation.Status != types.ReconciliationStatusOpen {
+		respondWithError(w, http.StatusBadRequest, "reconciliation is already completed")
+		return
+	}
+
+	view, err := s.getReconciliationView(s.storeFor(r), reconciliation)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if view.Difference != 0 {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("cleared balance differs from the statement balance by %.2f", view.Difference))
+		return
+	}
+
+	if err := s.storeFor(r).CompleteReconciliation(reconciliation.ID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	reconciliation, err = s.storeFor(r).GetReconciliationByID(reconciliation.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	s.respondWithReconciliation(w, s.storeFor(r), reconciliation)
+}
+
+// handleUndoReconciliation reopens a completed reconciliation unlocking its transactions
+func (s *APIServer) handleUndoReconciliation(w http.ResponseWriter, r *http.Request) {
+	reconciliation, ok := s.getReconciliationFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if reconciliation.Status != types.ReconciliationStatusCompleted {
+		respondWithError(w, http.StatusBadRequest, "only completed reconciliations can be undone")
+		return
+	}
+
+	open, err := s.storeFor(r).GetOpenReconciliation(reconciliation.AccountID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if open != nil {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("account has the open reconciliation %v, finish or delete it first", open.ID))
+		return
+	}
+
+	if err := s.storeFor(r).UndoReconciliation(reconciliation.ID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	reconciliation, err = s.storeFor(r).GetReconciliationByID(reconciliation.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	s.respondWithReconciliation(w, s.storeFor(r), reconciliation)
+}
+
+func (s *APIServer) handleDeleteReconciliation(w http.ResponseWriter, r *http.Request) {
+	reconciliation, ok := s.getReconciliationFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if reconciliation.Status != types.ReconciliationStatusOpen {
+		respondWithError(w, http.StatusBadRequest, "reconciliation is completed, undo it before deleting")
+		return
+	}
+
+	if err := s.storeFor(r).DeleteReconciliation(reconciliation.ID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, "Reconciliation deleted")
+}
+
+func (s *APIServer) getReconciliationFromRequest(w http.ResponseWriter, r *http.Request) (*types.Reconciliation, bool) {
+	id, err := getAndParseIDFromRequest(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return nil, false
+	}
+
+	reconciliation, err := s.storeFor(r).GetReconciliationByID(id)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, err.Error())
+		return nil, false
+	}
+
+	return reconciliation, true
+}
+
+func (s *APIServer) respondWithReconciliation(w http.ResponseWriter, store Storage, reconciliation *types.Reconciliation) {
+	view, err := s.getReconciliationView(store, reconciliation)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, view)
+}
+
+func (s *APIServer) getReconciliationView(store Storage, reconciliation *types.Reconciliation) (*types.ReconciliationView, error) {
+	clearedBalance, err := store.GetClearedBalance(reconciliation.AccountID, reconciliation.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	cleared, err := store.GetReconciliationTransactions(reconciliation.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	uncleared := []*types.Transaction{}
+	if reconciliation.Status == types.ReconciliationStatusOpen {
+		uncleared, err = store.GetUnclearedTransactions(reconciliation.AccountID, reconciliation.StatementDate)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// amounts are stored as float32, compare them in cents
+	difference := float32(math.Round(float64(reconciliation.StatementBalance-clearedBalance)*100) / 100)
+
+	return &types.ReconciliationView{
+		Reconciliation: reconciliation,
+		ClearedBalance: clearedBalance,
+		Difference:     difference,
+		Cleared:        cleared,
+		Uncleared:      uncleared,
+	}, nil
+}
@@ -0,0 +1,119 @@
+package apiserver
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/mdsavian/budget-tracker-api/internal/types"
+)
+
+// maxReportMonths limits the window of the monthly reports
+const maxReportMonths = 120
+
+func (s *APIServer) handleGetMonthlyReport(w http.ResponseWriter, r *http.Request) {
+	queryValues := r.URL.Query()
+
+	from, err := parseReportMonth(queryValues.Get("from"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("from %s", err.Error()))
+		return
+	}
+	to, err := parseReportMonth(queryValues.Get("to"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("to %s", err.Error()))
+		return
+	}
+
+	if to.Before(from) {
+		respondWithError(w, http.StatusBadRequest, "to must not be before from")
+		return
+	}
+	if to.After(from.AddDate(0, maxReportMonths-1, 0)) {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("the report is limited to %d months", maxReportMonths))
+		return
+	}
+
+	report, err := s.store.GetMonthlyReport(from, to, s.workspaceIDFor(r))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, report)
+}
+
+// parseReportMonth accepts a month (2006-01) or a date, returning the first day of its month
+func parseReportMonth(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, fmt.Errorf("is required")
+	}
+
+	month, err := time.Parse("2006-01", value)
+	if err != nil {
+		month, err = time.Parse("2006-01-02", value)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("is not a valid month, use YYYY-MM")
+		}
+	}
+
+	return time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, time.UTC), nil
+}
+
+func (s *APIServer) handleGetNetWorth(w http.ResponseWriter, r *http.Request) {
+	queryValues := r.URL.Query()
+	now := time.Now().UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	currentMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	to := currentMonth
+	from := currentMonth.AddDate(0, -11, 0)
+
+	var err error
+	if value := queryValues.Get("to"); value != "" {
+		if to, err = parseReportMonth(value); err != nil {
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("to %s", err.Error()))
+			return
+		}
+	}
+	if value := queryValues.Get("from"); value != "" {
+		if from, err = parseReportMonth(value); err != nil {
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("from %s", err.Error()))
+			return
+		}
+	}
+
+	if to.After(currentMonth) {
+		to = currentMonth
+	}
+	if to.Before(from) {
+		respondWithError(w, http.StatusBadRequest, "to must not be before from")
+		return
+	}
+	if to.After(from.AddDate(0, maxReportMonths-1, 0)) {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("the report is limited to %d months", maxReportMonths))
+		return
+	}
+
+	series, err := s.store.GetNetWorth(from, to, today, s.workspaceIDFor(r))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	report := &types.NetWorthReport{Series: series}
+	if len(series) > 0 && series[len(series)-1].Date.Equal(today) {
+		report.Current = series[len(series)-1]
+	} else {
+		current, err := s.store.GetNetWorth(currentMonth, currentMonth, today, s.workspaceIDFor(r))
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if len(current) > 0 {
+			report.Current = current[0]
+		}
+	}
+
+	respondWithJSON(w, http.StatusOK, report)
+}
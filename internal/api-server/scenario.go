@@ -0,0 +1,203 @@
+package apiserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mdsavian/budget-tracker-api/internal/forecast"
+	"github.com/mdsavian/budget-tracker-api/internal/types"
+)
+
+type ScenarioInput struct {
+	Name        string                `json:"name"`
+	Description string                `json:"description"`
+	Items       []*types.ScenarioItem `json:"items"`
+}
+
+func (s *APIServer) handleCreateScenario(w http.ResponseWriter, r *http.Request) {
+	input := ScenarioInput{}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	scenario := &types.Scenario{
+		ID:          uuid.Must(uuid.NewV7()),
+		Name:        input.Name,
+		Description: input.Description,
+		Items:       input.Items,
+		CreatedAt:   time.Now().UTC(),
+		UpdatedAt:   time.Now().UTC(),
+	}
+	if scenario.Items == nil {
+		scenario.Items = []*types.ScenarioItem{}
+	}
+
+	if err := forecast.ValidateScenario(scenario); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := s.storeFor(r).CreateScenario(scenario); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, scenario)
+}
+
+func (s *APIServer) handleUpdateScenario(w http.ResponseWriter, r *http.Request) {
+	id, err := getAndParseIDFromRequest(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	scenario, err := s.storeFor(r).GetScenarioByID(id)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	input := ScenarioInput{}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	scenario.Name = input.Name
+	scenario.Description = input.Description
+	scenario.Items = input.Items
+	scenario.UpdatedAt = time.Now().UTC()
+	if scenario.Items == nil {
+		scenario.Items = []*types.ScenarioItem{}
+	}
+
+	if err := forecast.ValidateScenario(scenario); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := s.storeFor(r).UpdateScenario(scenario); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, scenario)
+}
+
+func (s *APIServer) handleGetScenarios(w http.ResponseWriter, r *http.Request) {
+	scenarios, err := s.store.GetScenarios(s.workspaceIDFor(r))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, scenarios)
+}
+
+func (s *APIServer) handleGetScenario(w http.ResponseWriter, r *http.Request) {
+	id, err := getAndParseIDFromRequest(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	scenario, err := s.storeFor(r).GetScenarioByID(id)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, scenario)
+}
+
+func (s *APIServer) handleDeleteScenario(w http.ResponseWriter, r *http.Request) {
+	id, err := getAndParseIDFromRequest(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if _, err := s.storeFor(r).GetScenarioByID(id); err != nil {
+		respondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	if err := s.storeFor(r).DeleteScenario(id); err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, "Scenario deleted")
+}
+
+// handleGetScenarioForecast returns the baseline forecast and the one with the scenario applied side by side
+func (s *APIServer) handleGetScenarioForecast(w http.ResponseWriter, r *http.Request) {
+	type ScenarioForecast struct {
+		Scenario    *types.Scenario               `json:"scenario"`
+		Baseline    *forecast.Forecast            `json:"baseline"`
+		Simulated   *forecast.Forecast            `json:"simulated"`
+		Comparisons []*forecast.AccountComparison `json:"comparisons"`
+	}
+
+	id, err := getAndParseIDFromRequest(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	scenario, err := s.storeFor(r).GetScenarioByID(id)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	start, until, ok := parseForecastPeriod(w, r)
+	if !ok {
+		return
+	}
+
+	accounts, items, err := s.getForecastItems(s.storeFor(r), start, until)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	creditCards, err := s.storeFor(r).GetCreditCard()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	cards := map[uuid.UUID]*types.CreditCard{}
+	for _, card := range creditCards {
+		cards[card.ID] = card
+	}
+
+	scenarioItems, err := forecast.ApplyScenario(items, scenario, cards, until)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	baseline, err := forecast.Build(accounts, items, start, until)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	simulated, err := forecast.Build(accounts, scenarioItems, start, until)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, ScenarioForecast{
+		Scenario:    scenario,
+		Baseline:    baseline,
+		Simulated:   simulated,
+		Comparisons: forecast.Compare(baseline, simulated),
+	})
+}
@@ -14,16 +14,84 @@ type Storage interface {
 	// Recurring Transaction
 	CreateRecurringTransaction(*types.RecurringTransaction) error
 	ArchiveRecurringTransaction(uuid.UUID) error
+	UnarchiveRecurringTransaction(uuid.UUID) error
 	UpdateRecurringTransaction(uuid.UUID, *types.RecurringTransaction) error
 	GetRecurringTransactionByID(uuid.UUID) (*types.RecurringTransaction, error)
 
 	// Transaction
 	DeleteTransaction(uuid.UUID) error
+	UnarchiveTransaction(uuid.UUID) error
 	CreateTransaction(*types.Transaction) error
 	GetTransactionByID(uuid.UUID) (*types.Transaction, error)
-	GetTransactionsWithRecurringByDate(startDate, endate time.Time) ([]*types.TransactionView, error)
+	GetTransactionsByIDs([]uuid.UUID) ([]*types.Transaction, error)
+	GetTransactionsWithRecurringByDate(startDate, endate time.Time, filter *types.TransactionFilter) ([]*types.TransactionView, error)
+	StreamTransactionsByDate(startDate, endDate time.Time, includeRecurring bool, filter *types.TransactionFilter, fn func(*types.TransactionView) error) error
+	GetUnfulfilledTransactionsBefore(time.Time) ([]*types.TransactionView, error)
 	UpdateTransaction(uuid.UUID, *types.Transaction) error
+	UpdateTransactionDate(uuid.UUID, time.Time) error
+	GetUnpaidCreditCardTransactions(creditCardID uuid.UUID, from time.Time) ([]*types.Transaction, error)
 	FulfillTransaction(uuid.UUID) error
+	ExistsTransactionByExternalID(accountID uuid.UUID, externalID string) (bool, error)
+	FindMatchingTransaction(*types.Transaction) (*types.Transaction, error)
+
+	// Import
+	ExistsImportFingerprint(string) (bool, error)
+	CreateImportFingerprint(*types.ImportFingerprint) error
+	DeleteImportFingerprintsByTransaction(uuid.UUID) error
+	CreateImportJob(*types.ImportJob) error
+	UpdateImportJob(*types.ImportJob) error
+	FailUnfinishedImportJobs() error
+	GetImportJobByID(uuid.UUID) (*types.ImportJob, error)
+
+	// Reconciliation
+	CreateReconciliation(*types.Reconciliation) error
+	GetReconciliationByID(uuid.UUID) (*types.Reconciliation, error)
+	GetOpenReconciliation(uuid.UUID) (*types.Reconciliation, error)
+	GetReconciliations(*uuid.UUID) ([]*types.Reconciliation, error)
+	GetReconciliationTransactions(uuid.UUID) ([]*types.Transaction, error)
+	GetUnclearedTransactions(uuid.UUID, time.Time) ([]*types.Transaction, error)
+	GetClearedBalance(accountID, reconciliationID uuid.UUID) (float32, error)
+	ClearTransactions(reconciliationID, accountID uuid.UUID, transactionIDs []uuid.UUID) (int64, error)
+	UnclearTransactions(reconciliationID uuid.UUID, transactionIDs []uuid.UUID) (int64, error)
+	CompleteReconciliation(uuid.UUID) error
+	UndoReconciliation(uuid.UUID) error
+	DeleteReconciliation(uuid.UUID) error
+
+	// Investment
+	CreateHolding(*types.Holding) error
+	UpdateHolding(*types.Holding) error
+	GetHoldingByID(uuid.UUID) (*types.Holding, error)
+	GetHoldings(accountID *uuid.UUID) ([]*types.Holding, error)
+	SaveHoldingPrice(*types.HoldingPrice) error
+	GetHoldingPrices(holdingID *uuid.UUID) ([]*types.HoldingPrice, error)
+	CreateHoldingMovement(*types.HoldingMovement) error
+	SaveHoldingMovement(movement *types.HoldingMovement, holding *types.Holding, transactions []*types.Transaction) error
+	GetHoldingMovements(holdingID *uuid.UUID) ([]*types.HoldingMovement, error)
+	GetPositions(accountID *uuid.UUID) ([]*types.Position, error)
+
+	// Loan
+	CreateLoan(*types.Loan) error
+	GetLoanByID(uuid.UUID) (*types.Loan, error)
+	GetLoans() ([]*types.Loan, error)
+	SaveLoanInstallments(transactions []*types.Transaction, installments, replaced []*types.LoanInstallment) error
+	GetLoanInstallments(loanID *uuid.UUID) ([]*types.LoanInstallment, error)
+
+	// Report
+	GetMonthlyReport(from, to time.Time, workspaceID *uuid.UUID) (*types.MonthlyReport, error)
+	GetNetWorth(from, to, today time.Time, workspaceID *uuid.UUID) ([]*types.NetWorthPoint, error)
+
+	// Scenario
+	CreateScenario(*types.Scenario) error
+	UpdateScenario(*types.Scenario) error
+	DeleteScenario(uuid.UUID) error
+	GetScenarioByID(uuid.UUID) (*types.Scenario, error)
+	GetScenarios(workspaceID *uuid.UUID) ([]*types.Scenario, error)
+
+	// CSV profile
+	CreateCSVProfile(*types.CSVProfile) error
+	DeleteCSVProfile(uuid.UUID) error
+	GetCSVProfileByID(uuid.UUID) (*types.CSVProfile, error)
+	GetCSVProfiles(workspaceID *uuid.UUID) ([]*types.CSVProfile, error)
 
 	// CreditCard
 	CreateCreditCard(*types.CreditCard) error
@@ -31,6 +99,8 @@ type Storage interface {
 	GetCreditCardByName(string) (*types.CreditCard, error)
 	GetCreditCardByID(uuid.UUID) (*types.CreditCard, error)
 	ArchiveCreditCard(uuid.UUID) error
+	UnarchiveCreditCard(uuid.UUID) error
+	UpdateCreditCard(*types.CreditCard) error
 
 	// Category
 	CreateCategory(*types.Category) error
@@ -38,13 +108,45 @@ type Storage interface {
 	GetCategoryByDescription(string) (*types.Category, error)
 	GetCategoryByID(uuid.UUID) (*types.Category, error)
 	ArchiveCategory(uuid.UUID) error
+	UnarchiveCategory(uuid.UUID) error
+	UpdateCategory(*types.Category) error
 
 	// Account
 	CreateAccount(*types.Account) error
-	UpdateAccountBalance(uuid.UUID, float32, types.TransactionType) error
+	UpdateAccount(*types.Account) error
+	UpdateAccountBalance(uuid.UUID, float32, types.TransactionType, time.Time) error
 	DeleteAccount(uuid.UUID) error
 	GetAccountByID(uuid.UUID) (*types.Account, error)
 	GetAccounts() ([]*types.Account, error)
+	GetUniqueAccount(string, types.AccountType) (*types.Account, error)
+	GetAccountBalanceAudit(uuid.UUID) (*types.AccountBalanceAudit, error)
+	SetAccountBalance(accountID uuid.UUID, balance float32) error
+	GetBalanceHistory(accountID, workspaceID *uuid.UUID, startDate, endDate time.Time, interval types.BalanceInterval) ([]*types.BalancePoint, error)
+
+	// Audit
+	CreateAuditEntry(*types.AuditEntry) error
+	GetAuditEntries(entity types.AuditEntity, entityID *uuid.UUID, limit int) ([]*types.AuditEntry, error)
+
+	// Trash
+	GetTrash(since time.Time, workspaceID *uuid.UUID) ([]*types.TrashItem, error)
+
+	// Backup
+	GetRecurringTransactions() ([]*types.RecurringTransaction, error)
+	GetTransactions() ([]*types.Transaction, error)
+	HasBudgetData() (bool, error)
+	RestoreBackup(*types.Backup, bool) error
+
+	// Workspace
+	CreateWorkspace(*types.Workspace) error
+	GetWorkspaces() ([]*types.Workspace, error)
+	GetUserWorkspaces(userID uuid.UUID) ([]*types.UserWorkspace, error)
+	GetResourceWorkspaceID(table string, id uuid.UUID) (uuid.UUID, error)
+	CreateWorkspaceMember(*types.WorkspaceMember) error
+	UpdateWorkspaceMember(*types.WorkspaceMember) error
+	DeleteWorkspaceMember(uuid.UUID) error
+	GetWorkspaceMemberByID(uuid.UUID) (*types.WorkspaceMember, error)
+	GetWorkspaceMembers(workspaceID *uuid.UUID) ([]*types.WorkspaceMember, error)
+	AcceptWorkspaceInvites(*types.User) error
 
 	// User
 	CreateUser(*types.User) error
@@ -60,14 +162,16 @@ type Storage interface {
 }
 
 type APIServer struct {
-	listenAddr string
-	store      Storage
+	listenAddr  string
+	store       Storage
+	importQueue chan *importTask
 }
 
 func NewServer(listenAddr string, store Storage) *APIServer {
 	return &APIServer{
-		listenAddr: listenAddr,
-		store:      store,
+		listenAddr:  listenAddr,
+		store:       store,
+		importQueue: make(chan *importTask, importQueueSize),
 	}
 }
 
@@ -77,6 +181,18 @@ func (s *APIServer) Start() {
 	mux.HandleFunc("GET /dashboard", s.validateSession(s.handleGetDashboardInfo))
 	mux.HandleFunc("GET /dashboard/transaction", s.validateSession(s.handleGetTransactionsByDate))
 
+	mux.HandleFunc("GET /forecast", s.validateSession(s.handleGetForecast))
+
+	mux.HandleFunc("GET /reports/monthly", s.validateSession(s.handleGetMonthlyReport))
+	mux.HandleFunc("GET /reports/net-worth", s.validateSession(s.handleGetNetWorth))
+
+	mux.HandleFunc("POST /scenario", s.validateSession(s.handleCreateScenario))
+	mux.HandleFunc("GET /scenario", s.validateSession(s.handleGetScenarios))
+	mux.HandleFunc("GET /scenario/{id}", s.validateSession(s.handleGetScenario))
+	mux.HandleFunc("PUT /scenario/{id}", s.validateSession(s.handleUpdateScenario))
+	mux.HandleFunc("DELETE /scenario/{id}", s.validateSession(s.handleDeleteScenario))
+	mux.HandleFunc("GET /scenario/{id}/forecast", s.validateSession(s.handleGetScenarioForecast))
+
 	mux.HandleFunc("POST /transaction", s.validateSession(s.handleDeleteTransaction))
 	mux.HandleFunc("GET /transaction", s.validateSession(s.handleGetTransactionByID))
 	mux.HandleFunc("POST /transaction/credit", s.validateSession(s.handleCreateCredit))
@@ -84,21 +200,86 @@ func (s *APIServer) Start() {
 	mux.HandleFunc("POST /transaction/debit/creditcard", s.validateSession(s.handleCreateCreditCardDebit))
 	mux.HandleFunc("PUT /transaction/update", s.validateSession(s.handleUpdateTransaction))
 	mux.HandleFunc("POST /transaction/effectuate", s.validateSession(s.handleEffectuateTransaction))
+	mux.HandleFunc("PUT /transaction/unarchive/{id}", s.validateSession(s.handleUnarchiveTransaction))
+
+	mux.HandleFunc("PUT /recurring/unarchive/{id}", s.validateSession(s.handleUnarchiveRecurringTransaction))
+
+	mux.HandleFunc("POST /import", s.validateSession(s.handleCreateImportJob))
+	mux.HandleFunc("GET /import/{id}", s.validateSession(s.handleGetImportJob))
+	mux.HandleFunc("POST /import/{id}/revert", s.validateSession(s.handleRevertImportJob))
+	mux.HandleFunc("POST /import/ofx", s.validateSession(s.handleImportOFX))
+	mux.HandleFunc("POST /import/csv", s.validateSession(s.handleImportCSV))
+	mux.HandleFunc("POST /import/csv/preview", s.validateSession(s.handlePreviewCSV))
+	mux.HandleFunc("POST /import/csv/profile", s.validateSession(s.handleCreateCSVProfile))
+	mux.HandleFunc("GET /import/csv/profile", s.validateSession(s.handleGetCSVProfiles))
+	mux.HandleFunc("DELETE /import/csv/profile/{id}", s.validateSession(s.handleDeleteCSVProfile))
+
+	mux.HandleFunc("POST /reconciliation", s.validateSession(s.handleCreateReconciliation))
+	mux.HandleFunc("GET /reconciliation", s.validateSession(s.handleGetReconciliations))
+	mux.HandleFunc("GET /reconciliation/{id}", s.validateSession(s.handleGetReconciliation))
+	mux.HandleFunc("DELETE /reconciliation/{id}", s.validateSession(s.handleDeleteReconciliation))
+	mux.HandleFunc("POST /reconciliation/{id}/clear", s.validateSession(s.handleClearReconciliationTransactions))
+	mux.HandleFunc("POST /reconciliation/{id}/unclear", s.validateSession(s.handleUnclearReconciliationTransactions))
+	mux.HandleFunc("POST /reconciliation/{id}/complete", s.validateSession(s.handleCompleteReconciliation))
+	mux.HandleFunc("POST /reconciliation/{id}/undo", s.validateSession(s.handleUndoReconciliation))
+
+	mux.HandleFunc("GET /export/transactions", s.validateSession(s.handleExportTransactions))
+
+	mux.HandleFunc("POST /investment/holding", s.validateSession(s.handleCreateHolding))
+	mux.HandleFunc("GET /investment/holding", s.validateSession(s.handleGetHoldings))
+	mux.HandleFunc("GET /investment/holding/{id}", s.validateSession(s.handleGetHoldingByID))
+	mux.HandleFunc("POST /investment/holding/{id}/price", s.validateSession(s.handleCreateHoldingPrice))
+	mux.HandleFunc("GET /investment/holding/{id}/price", s.validateSession(s.handleGetHoldingPrices))
+	mux.HandleFunc("GET /investment/holding/{id}/movement", s.validateSession(s.handleGetHoldingMovements))
+	mux.HandleFunc("POST /investment/holding/{id}/contribution", s.validateSession(s.handleCreateContribution))
+	mux.HandleFunc("POST /investment/holding/{id}/redemption", s.validateSession(s.handleCreateRedemption))
+	mux.HandleFunc("POST /investment/price/import", s.validateSession(s.handleImportHoldingPrices))
+	mux.HandleFunc("GET /investment/position", s.validateSession(s.handleGetPositions))
+
+	mux.HandleFunc("POST /loan", s.validateSession(s.handleCreateLoan))
+	mux.HandleFunc("GET /loan", s.validateSession(s.handleGetLoans))
+	mux.HandleFunc("GET /loan/{id}", s.validateSession(s.handleGetLoanByID))
+	mux.HandleFunc("POST /loan/{id}/extra-payment", s.validateSession(s.handleLoanExtraPayment))
+
+	mux.HandleFunc("POST /payoff/plan", s.validateSession(s.handleGetPayoffPlan))
+	mux.HandleFunc("POST /payoff/plan/save", s.validateSession(s.handleSavePayoffPlan))
+
+	mux.HandleFunc("GET /audit", s.validateSession(s.handleGetAudit))
+	mux.HandleFunc("GET /trash", s.validateSession(s.handleGetTrash))
+
+	mux.HandleFunc("GET /backup", s.validateSession(s.handleGetBackup))
+	mux.HandleFunc("POST /restore", s.validateSession(s.handleRestoreBackup))
 
 	mux.HandleFunc("POST /creditcard", s.validateSession(s.handleCreateCreditCard))
 	mux.HandleFunc("GET /creditcard", s.validateSession(s.handleGetCreditCard))
 	mux.HandleFunc("GET /creditcard/{id}", s.validateSession(s.handleGetCreditCardById))
+	mux.HandleFunc("PUT /creditcard/{id}", s.validateSession(s.handleUpdateCreditCard))
 	mux.HandleFunc("PUT /creditcard/archive/{id}", s.validateSession(s.handleArchiveCreditCard))
+	mux.HandleFunc("PUT /creditcard/unarchive/{id}", s.validateSession(s.handleUnarchiveCreditCard))
 
 	mux.HandleFunc("POST /category", s.validateSession(s.handleCreateCategory))
 	mux.HandleFunc("GET /category", s.validateSession(s.handleGetCategory))
+	mux.HandleFunc("PUT /category/{id}", s.validateSession(s.handleUpdateCategory))
 	mux.HandleFunc("PUT /category/archive/{id}", s.validateSession(s.handleArchiveCategory))
+	mux.HandleFunc("PUT /category/unarchive/{id}", s.validateSession(s.handleUnarchiveCategory))
+
+	mux.HandleFunc("POST /workspace", s.validateSession(s.handleCreateWorkspace))
+	mux.HandleFunc("GET /workspace", s.validateSession(s.handleGetWorkspaces))
+	mux.HandleFunc("GET /workspace/{id}/member", s.validateSession(s.handleGetWorkspaceMembers))
+	mux.HandleFunc("POST /workspace/{id}/member", s.validateSession(s.handleCreateWorkspaceMember))
+	mux.HandleFunc("PUT /workspace/{id}/member/{memberId}", s.validateSession(s.handleUpdateWorkspaceMember))
+	mux.HandleFunc("DELETE /workspace/{id}/member/{memberId}", s.validateSession(s.handleDeleteWorkspaceMember))
 
 	mux.HandleFunc("DELETE /user/{id}", s.validateSession(s.handleDeleteUser))
 
 	mux.HandleFunc("POST /account", s.validateSession(s.handleCreateAccount))
 	mux.HandleFunc("GET /account", s.validateSession(s.handleGetAccounts))
 	mux.HandleFunc("GET /account/{id}", s.validateSession(s.handleGetAccountByID))
+	mux.HandleFunc("POST /account/{id}/adjust", s.validateSession(s.handleAdjustAccountBalance))
+	mux.HandleFunc("GET /account/{id}/audit", s.validateSession(s.handleGetAccountBalanceAudit))
+	mux.HandleFunc("GET /account/{id}/history", s.validateSession(s.handleGetAccountBalanceHistory))
+	mux.HandleFunc("GET /account/history", s.validateSession(s.handleGetBalanceHistory))
+	mux.HandleFunc("PUT /account/{id}", s.validateSession(s.handleUpdateAccount))
 	mux.HandleFunc("DELETE /account/{id}", s.validateSession(s.handleDeleteAccount))
 
 	mux.HandleFunc("POST /user", s.validateSession(s.handleCreateUser))
@@ -112,6 +293,8 @@ func (s *APIServer) Start() {
 		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE"},
 	}).Handler(mux)
 
+	s.startImportWorkers()
+
 	log.Println("Server running on port: ", s.listenAddr)
 	http.ListenAndServe(s.listenAddr, handler)
 }
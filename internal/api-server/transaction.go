@@ -40,27 +40,33 @@ func (s *APIServer) handleCreateCreditCardDebit(w http.ResponseWriter, r *http.R
 		return
 	}
 
-	creditCard, err := s.store.GetCreditCardByID(debitInput.CreditCardID)
+	creditCard, err := s.storeFor(r).GetCreditCardByID(debitInput.CreditCardID)
 	if err != nil {
 		respondWithError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	creditCardDebitDate, err := time.Parse("2006-01-02", debitInput.Date)
+	account, err := s.storeFor(r).GetAccountByID(debitInput.AccountID)
 	if err != nil {
 		respondWithError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	debitDayToAdd := creditCard.DueDay - creditCardDebitDate.Day()
-	if creditCardDebitDate.Day() < creditCard.ClosingDay {
-		creditCardDebitDate = creditCardDebitDate.AddDate(0, 0, debitDayToAdd)
-	} else {
-		creditCardDebitDate = creditCardDebitDate.AddDate(0, 1, debitDayToAdd)
+	if !account.Kind.PaysCreditCards() {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("card statements can't be paid from %s accounts", account.Kind))
+		return
+	}
+
+	creditCardDebitDate, err := time.Parse("2006-01-02", debitInput.Date)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
 	}
 
+	creditCardDebitDate = creditCard.DueDateFor(creditCardDebitDate)
+
 	if debitInput.Fixed {
-		creditCardRecurringTransaction, err := s.createRecurringCreditCardDebit(debitInput, creditCardDebitDate)
+		creditCardRecurringTransaction, err := s.createRecurringCreditCardDebit(s.storeFor(r), debitInput, creditCardDebitDate)
 		if err != nil {
 			respondWithError(w, http.StatusBadRequest, err.Error())
 			return
@@ -70,7 +76,7 @@ func (s *APIServer) handleCreateCreditCardDebit(w http.ResponseWriter, r *http.R
 	}
 
 	if debitInput.Installments > 1 {
-		firstInstallmentTransaction, err := s.createCreditCardDebitInstallments(debitInput, creditCardDebitDate)
+		firstInstallmentTransaction, err := s.createCreditCardDebitInstallments(s.storeFor(r), debitInput, creditCardDebitDate)
 		if err != nil {
 			respondWithError(w, http.StatusBadRequest, err.Error())
 			return
@@ -93,7 +99,7 @@ func (s *APIServer) handleCreateCreditCardDebit(w http.ResponseWriter, r *http.R
 		UpdatedAt:       time.Now().UTC(),
 	}
 
-	if err := s.store.CreateTransaction(transaction); err != nil {
+	if err := s.storeFor(r).CreateTransaction(transaction); err != nil {
 		respondWithError(w, http.StatusBadRequest, err.Error())
 		return
 	}
@@ -101,7 +107,7 @@ func (s *APIServer) handleCreateCreditCardDebit(w http.ResponseWriter, r *http.R
 	respondWithJSON(w, http.StatusOK, transaction)
 }
 
-func (s *APIServer) createCreditCardDebitInstallments(debitInput CreateCreditCardDebitInput, creditCardDebitDate time.Time) (*types.Transaction, error) {
+func (s *APIServer) createCreditCardDebitInstallments(store Storage, debitInput CreateCreditCardDebitInput, creditCardDebitDate time.Time) (*types.Transaction, error) {
 	amountPerInstallment := debitInput.Amount / float32(debitInput.Installments)
 	var firstInstallmentTransaction *types.Transaction
 
@@ -122,7 +128,7 @@ func (s *APIServer) createCreditCardDebitInstallments(debitInput CreateCreditCar
 			UpdatedAt:       time.Now().UTC(),
 		}
 
-		if err := s.store.CreateTransaction(installmentTransaction); err != nil {
+		if err := store.CreateTransaction(installmentTransaction); err != nil {
 			return nil, err
 		}
 
@@ -133,7 +139,7 @@ func (s *APIServer) createCreditCardDebitInstallments(debitInput CreateCreditCar
 	return firstInstallmentTransaction, nil
 }
 
-func (s *APIServer) createRecurringCreditCardDebit(creditCardDebitInput CreateCreditCardDebitInput, creditCardDebitDate time.Time) (*types.Transaction, error) {
+func (s *APIServer) createRecurringCreditCardDebit(store Storage, creditCardDebitInput CreateCreditCardDebitInput, creditCardDebitDate time.Time) (*types.Transaction, error) {
 	recurringTransactionID := uuid.Must(uuid.NewV7())
 
 	creditCardRecurringTransaction := &types.Transaction{
@@ -151,7 +157,7 @@ func (s *APIServer) createRecurringCreditCardDebit(creditCardDebitInput CreateCr
 		UpdatedAt:              time.Now().UTC(),
 	}
 
-	err := s.store.CreateRecurringTransaction(&types.RecurringTransaction{
+	err := store.CreateRecurringTransaction(&types.RecurringTransaction{
 		ID:              recurringTransactionID,
 		AccountID:       creditCardDebitInput.AccountID,
 		CategoryID:      creditCardDebitInput.CategoryId,
@@ -168,7 +174,7 @@ func (s *APIServer) createRecurringCreditCardDebit(creditCardDebitInput CreateCr
 		return nil, err
 	}
 
-	if err := s.store.CreateTransaction(creditCardRecurringTransaction); err != nil {
+	if err := store.CreateTransaction(creditCardRecurringTransaction); err != nil {
 		return nil, err
 	}
 
@@ -214,7 +220,7 @@ func (s *APIServer) handleCreateDebit(w http.ResponseWriter, r *http.Request) {
 	if debitInput.Fixed {
 		recurringTransactionID := uuid.Must(uuid.NewV7())
 
-		err := s.store.CreateRecurringTransaction(&types.RecurringTransaction{
+		err := s.storeFor(r).CreateRecurringTransaction(&types.RecurringTransaction{
 			ID:              recurringTransactionID,
 			AccountID:       debitInput.AccountID,
 			CategoryID:      debitInput.CategoryId,
@@ -234,12 +240,12 @@ func (s *APIServer) handleCreateDebit(w http.ResponseWriter, r *http.Request) {
 		debitTransaction.RecurringTransactionID = &recurringTransactionID
 	}
 
-	if err := s.store.CreateTransaction(debitTransaction); err != nil {
+	if err := s.storeFor(r).CreateTransaction(debitTransaction); err != nil {
 		respondWithError(w, http.StatusBadRequest, err.Error())
 	}
 
 	if debitInput.Fulfilled {
-		err = s.store.UpdateAccountBalance(debitInput.AccountID, debitInput.Amount, types.TransactionTypeDebit)
+		err = s.storeFor(r).UpdateAccountBalance(debitInput.AccountID, debitInput.Amount, types.TransactionTypeDebit, debitDate)
 		if err != nil {
 			respondWithError(w, http.StatusInternalServerError, err.Error())
 		}
@@ -283,13 +289,13 @@ func (s *APIServer) handleCreateCredit(w http.ResponseWriter, r *http.Request) {
 		UpdatedAt:       time.Now().UTC(),
 	}
 
-	if err := s.store.CreateTransaction(creditTransaction); err != nil {
+	if err := s.storeFor(r).CreateTransaction(creditTransaction); err != nil {
 		respondWithError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
 	if creditInput.Fulfilled {
-		err = s.store.UpdateAccountBalance(creditInput.AccountID, creditInput.Amount, types.TransactionTypeCredit)
+		err = s.storeFor(r).UpdateAccountBalance(creditInput.AccountID, creditInput.Amount, types.TransactionTypeCredit, creditDate)
 		if err != nil {
 			respondWithError(w, http.StatusInternalServerError, err.Error())
 			return
@@ -318,12 +324,14 @@ func (s *APIServer) handleEffectuateTransaction(w http.ResponseWriter, r *http.R
 		return
 	}
 
+	store := s.storeFor(r)
+
 	transaction := &types.Transaction{}
 	var err error
 	if effectuateTransactionInout.TransactionID != uuid.Nil {
-		transaction, err = s.store.GetTransactionByID(effectuateTransactionInout.TransactionID)
+		transaction, err = store.GetTransactionByID(effectuateTransactionInout.TransactionID)
 		if err != nil {
-			respondWithError(w, http.StatusBadRequest, err.Error())
+			respondWithError(w, http.StatusNotFound, err.Error())
 			return
 		}
 
@@ -332,15 +340,15 @@ func (s *APIServer) handleEffectuateTransaction(w http.ResponseWriter, r *http.R
 			return
 		}
 
-		err = s.store.FulfillTransaction(effectuateTransactionInout.TransactionID)
+		err = store.FulfillTransaction(effectuateTransactionInout.TransactionID)
 		if err != nil {
 			respondWithError(w, http.StatusInternalServerError, err.Error())
 			return
 		}
 	} else if effectuateTransactionInout.RecurringTransactionID != uuid.Nil {
-		recurringTransaction, err := s.store.GetRecurringTransactionByID(effectuateTransactionInout.RecurringTransactionID)
+		recurringTransaction, err := store.GetRecurringTransactionByID(effectuateTransactionInout.RecurringTransactionID)
 		if err != nil {
-			respondWithError(w, http.StatusBadRequest, err.Error())
+			respondWithError(w, http.StatusNotFound, err.Error())
 			return
 		}
 
@@ -366,14 +374,15 @@ func (s *APIServer) handleEffectuateTransaction(w http.ResponseWriter, r *http.R
 			UpdatedAt:              time.Now().UTC(),
 		}
 
-		err = s.store.CreateTransaction(transaction)
+		err = store.CreateTransaction(transaction)
 		if err != nil {
 			respondWithError(w, http.StatusInternalServerError, err.Error())
 			return
 		}
 	}
 
-	err = s.store.UpdateAccountBalance(transaction.AccountID, transaction.Amount, transaction.TransactionType)
+	// both branches effectuate the transaction today
+	err = store.UpdateAccountBalance(transaction.AccountID, transaction.Amount, transaction.TransactionType, time.Now().UTC())
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, err.Error())
 		return
@@ -447,10 +456,12 @@ func (s *APIServer) handleUpdateTransaction(w http.ResponseWriter, r *http.Reque
 		Fulfilled:              updateInput.Fulfilled,
 	}
 
+	store := s.storeFor(r)
+
 	if updateInput.TransactionID != nil && *updateInput.TransactionID != uuid.Nil {
-		transactionFromDb, err := s.store.GetTransactionByID(*updateInput.TransactionID)
+		transactionFromDb, err := store.GetTransactionByID(*updateInput.TransactionID)
 		if err != nil {
-			respondWithError(w, http.StatusBadRequest, err.Error())
+			respondWithError(w, http.StatusNotFound, err.Error())
 			return
 		}
 		if transactionFromDb == nil {
@@ -458,22 +469,43 @@ func (s *APIServer) handleUpdateTransaction(w http.ResponseWriter, r *http.Reque
 			return
 		}
 
-		err = s.store.UpdateTransaction(*updateInput.TransactionID, transaction)
+		if transactionFromDb.Reconciled {
+			respondWithError(w, http.StatusBadRequest, "transaction is reconciled, undo the reconciliation to change it")
+			return
+		}
+
+		if transactionFromDb.TransferID != nil {
+			respondWithError(w, http.StatusBadRequest, "transaction is part of a transfer, it changes only through its holding movement")
+			return
+		}
+
+		err = store.UpdateTransaction(*updateInput.TransactionID, transaction)
 		if err != nil {
 			respondWithError(w, http.StatusBadRequest, err.Error())
 			return
 		}
 
-		// revert transaction payment from old transaction
+		// a cleared transaction moved to another account or no longer fulfilled leaves the reconciliation
+		if transactionFromDb.ReconciliationID != nil && (updateInput.AccountID != transactionFromDb.AccountID || !updateInput.Fulfilled) {
+			_, err = store.UnclearTransactions(*transactionFromDb.ReconciliationID, []uuid.UUID{transactionFromDb.ID})
+			if err != nil {
+				respondWithError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+		}
+
+		// revert the payment of the old transaction, only a fulfilled one was applied to the balance.
+		// A new date is reverted and applied again too, it may fall on the other side of the opening date.
 		transactionPaymentReverted := false
-		if updateInput.AccountID != transactionFromDb.AccountID || (transactionFromDb.Fulfilled && updateInput.Amount != transactionFromDb.Amount) ||
-			(!updateInput.Fulfilled && transactionFromDb.Fulfilled) {
+		if transactionFromDb.Fulfilled && (updateInput.AccountID != transactionFromDb.AccountID ||
+			updateInput.Amount != transactionFromDb.Amount || !updateInput.Fulfilled ||
+			!transactionDate.Equal(transactionFromDb.EffectiveDate())) {
 			transactionType := types.TransactionTypeDebit
 			if transactionFromDb.TransactionType == types.TransactionTypeDebit {
 				transactionType = types.TransactionTypeCredit
 			}
 
-			err = s.store.UpdateAccountBalance(transactionFromDb.AccountID, transactionFromDb.Amount, transactionType)
+			err = store.UpdateAccountBalance(transactionFromDb.AccountID, transactionFromDb.Amount, transactionType, transactionFromDb.EffectiveDate())
 			if err != nil {
 				respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error updating account balance err: %s", err.Error()))
 				return
@@ -481,8 +513,9 @@ func (s *APIServer) handleUpdateTransaction(w http.ResponseWriter, r *http.Reque
 			transactionPaymentReverted = true
 		}
 
-		if updateInput.Fulfilled && transactionPaymentReverted || (updateInput.Fulfilled && !transactionFromDb.Fulfilled) {
-			err = s.store.UpdateAccountBalance(updateInput.AccountID, updateInput.Amount, transactionFromDb.TransactionType)
+		if updateInput.Fulfilled && (transactionPaymentReverted || !transactionFromDb.Fulfilled) {
+			// the update effectuates the transaction on the given date
+			err = store.UpdateAccountBalance(updateInput.AccountID, updateInput.Amount, transactionFromDb.TransactionType, transactionDate)
 			if err != nil {
 				respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error updating account balance err: %s", err.Error()))
 				return
@@ -491,9 +524,9 @@ func (s *APIServer) handleUpdateTransaction(w http.ResponseWriter, r *http.Reque
 	}
 
 	if uRecurringTransactionID != nil && *uRecurringTransactionID != uuid.Nil {
-		recurringTransaction, err := s.store.GetRecurringTransactionByID(*uRecurringTransactionID)
+		recurringTransaction, err := store.GetRecurringTransactionByID(*uRecurringTransactionID)
 		if err != nil {
-			respondWithError(w, http.StatusBadRequest, err.Error())
+			respondWithError(w, http.StatusNotFound, err.Error())
 			return
 		}
 		if recurringTransaction == nil {
@@ -505,14 +538,14 @@ func (s *APIServer) handleUpdateTransaction(w http.ResponseWriter, r *http.Reque
 			// if dont update recurring and dont have transaction ID means the transaction has just the recurring info and we need to create a new transaction
 			transaction.ID = uuid.Must(uuid.NewV7())
 			transaction.TransactionType = recurringTransaction.TransactionType
-			err := s.store.CreateTransaction(transaction)
+			err := store.CreateTransaction(transaction)
 			if err != nil {
 				respondWithError(w, http.StatusBadRequest, err.Error())
 				return
 			}
 
 			if updateInput.Fulfilled {
-				err = s.store.UpdateAccountBalance(updateInput.AccountID, updateInput.Amount, transaction.TransactionType)
+				err = store.UpdateAccountBalance(updateInput.AccountID, updateInput.Amount, transaction.TransactionType, transaction.Date)
 				if err != nil {
 					respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("error updating account balance err: %s", err.Error()))
 					return
@@ -531,7 +564,7 @@ func (s *APIServer) handleUpdateTransaction(w http.ResponseWriter, r *http.Reque
 				Day:          transactionDate.Day(),
 			}
 
-			if err := s.store.UpdateRecurringTransaction(*uRecurringTransactionID, recurringTransactionToUpdate); err != nil {
+			if err := store.UpdateRecurringTransaction(*uRecurringTransactionID, recurringTransactionToUpdate); err != nil {
 				respondWithError(w, http.StatusBadRequest, err.Error())
 				return
 			}
@@ -575,10 +608,12 @@ func (s *APIServer) handleGetTransactionByID(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
+	store := s.storeFor(r)
+
 	if isRecurring {
-		recurringTransaction, err := s.store.GetRecurringTransactionByID(uTransactionID)
+		recurringTransaction, err := store.GetRecurringTransactionByID(uTransactionID)
 		if err != nil {
-			respondWithError(w, http.StatusBadRequest, err.Error())
+			respondWithError(w, http.StatusNotFound, err.Error())
 			return
 		}
 
@@ -598,9 +633,9 @@ func (s *APIServer) handleGetTransactionByID(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	transaction, err := s.store.GetTransactionByID(uTransactionID)
+	transaction, err := store.GetTransactionByID(uTransactionID)
 	if err != nil {
-		respondWithError(w, http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusNotFound, err.Error())
 		return
 	}
 
@@ -662,10 +697,11 @@ func (s *APIServer) handleDeleteTransaction(w http.ResponseWriter, r *http.Reque
 	// 	return
 	// }
 
-	transaction, err := s.store.GetTransactionByID(deleteInput.TransactionID)
+	store := s.storeFor(r)
+	transaction, err := store.GetTransactionByID(deleteInput.TransactionID)
 
 	if err != nil {
-		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("error getting transaction err: %s", err.Error()))
+		respondWithError(w, http.StatusNotFound, fmt.Sprintf("error getting transaction err: %s", err.Error()))
 		return
 	}
 
@@ -674,24 +710,108 @@ func (s *APIServer) handleDeleteTransaction(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	if transaction.Reconciled {
+		respondWithError(w, http.StatusBadRequest, "transaction is reconciled, undo the reconciliation to delete it")
+		return
+	}
+
+	if transaction.TransferID != nil {
+		respondWithError(w, http.StatusBadRequest, "transaction is part of a transfer, it changes only through its holding movement")
+		return
+	}
+
+	if err := s.archiveTransaction(store, transaction); err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, "Transaction deleted")
+}
+
+// archiveTransaction soft deletes the transaction reverting its payment from the account balance
+func (s *APIServer) archiveTransaction(store Storage, transaction *types.Transaction) error {
 	if transaction.Fulfilled {
 		transactionType := types.TransactionTypeDebit
 		if transaction.TransactionType == types.TransactionTypeDebit {
 			transactionType = types.TransactionTypeCredit
 		}
 
-		err = s.store.UpdateAccountBalance(transaction.AccountID, transaction.Amount, transactionType)
-		if err != nil {
+		if err := store.UpdateAccountBalance(transaction.AccountID, transaction.Amount, transactionType, transaction.EffectiveDate()); err != nil {
+			return err
+		}
+	}
+
+	return store.DeleteTransaction(transaction.ID)
+}
+
+// handleUnarchiveTransaction restores a deleted transaction, applying a fulfilled one to the account balance again
+func (s *APIServer) handleUnarchiveTransaction(w http.ResponseWriter, r *http.Request) {
+	id, err := getAndParseIDFromRequest(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	transaction, err := s.storeFor(r).GetTransactionByID(id)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	if !transaction.Archived {
+		respondWithError(w, http.StatusBadRequest, "transaction is not archived")
+		return
+	}
+
+	if _, err := s.storeFor(r).GetAccountByID(transaction.AccountID); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	store := s.storeFor(r)
+	if err := store.UnarchiveTransaction(transaction.ID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if transaction.Fulfilled {
+		if err := store.UpdateAccountBalance(transaction.AccountID, transaction.Amount, transaction.TransactionType, transaction.EffectiveDate()); err != nil {
 			respondWithError(w, http.StatusInternalServerError, err.Error())
 			return
 		}
 	}
 
-	err = s.store.DeleteTransaction(deleteInput.TransactionID)
+	transaction, err = s.storeFor(r).GetTransactionByID(id)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, transaction)
+}
+
+func (s *APIServer) handleUnarchiveRecurringTransaction(w http.ResponseWriter, r *http.Request) {
+	id, err := getAndParseIDFromRequest(r)
 	if err != nil {
 		respondWithError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	respondWithJSON(w, http.StatusOK, "Transaction deleted")
+	recurringTransaction, err := s.storeFor(r).GetRecurringTransactionByID(id)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	if !recurringTransaction.Archived {
+		respondWithError(w, http.StatusBadRequest, "recurring transaction is not archived")
+		return
+	}
+
+	if err := s.storeFor(r).UnarchiveRecurringTransaction(id); err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, "Recurring transaction restored successfully")
 }
@@ -0,0 +1,33 @@
+package apiserver
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultTrashDays = 30
+	maxTrashDays     = 365
+)
+
+// handleGetTrash lists the records archived in the last days, 30 by default
+func (s *APIServer) handleGetTrash(w http.ResponseWriter, r *http.Request) {
+	days := defaultTrashDays
+	if value := r.URL.Query().Get("days"); value != "" {
+		parsedDays, err := strconv.Atoi(value)
+		if err != nil || parsedDays < 1 || parsedDays > maxTrashDays {
+			respondWithError(w, http.StatusBadRequest, "days must be between 1 and 365")
+			return
+		}
+		days = parsedDays
+	}
+
+	items, err := s.store.GetTrash(time.Now().UTC().AddDate(0, 0, -days), s.workspaceIDFor(r))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, items)
+}
@@ -42,6 +42,11 @@ func (s *APIServer) handleCreateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := s.store.AcceptWorkspaceInvites(user); err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
 	respondWithJSON(w, http.StatusOK, user)
 }
 
@@ -0,0 +1,1217 @@
+package apiserver
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mdsavian/budget-tracker-api/internal/types"
+)
+
+const (
+	workspaceKey contextKey = "workspace"
+	// workspaceHeader picks the workspace of the routes that don't name a record
+	workspaceHeader = "X-Workspace-ID"
+	// defaultWorkspaceName is the workspace created for users without one
+	defaultWorkspaceName = "Personal"
+)
+
+// workspaceResources maps the path before the {id} of a route to the table of the record,
+// the record's workspace is the one the request works on
+var workspaceResources = map[string]string{
+	"account":               "account",
+	"category":              "category",
+	"category/archive":      "category",
+	"category/unarchive":    "category",
+	"creditcard":            "credit_card",
+	"creditcard/archive":    "credit_card",
+	"creditcard/unarchive":  "credit_card",
+	"transaction":           "transaction",
+	"transaction/unarchive": "transaction",
+	"recurring":             "recurring_transaction",
+	"recurring/unarchive":   "recurring_transaction",
+	"reconciliation":        "reconciliation",
+	"investment/holding":    "holding",
+	"loan":                  "loan",
+	"scenario":              "scenario",
+	"import":                "import_job",
+	"import/csv/profile":    "csv_profile",
+}
+
+// installationResources are shared by every workspace, only administrators reach them
+var installationResources = map[string]bool{
+	"user":    true,
+	"backup":  true,
+	"restore": true,
+	"audit":   true,
+}
+
+type CreateWorkspaceInput struct {
+	Name string `json:"name"`
+}
+
+type WorkspaceMemberInput struct {
+	Email string              `json:"email"`
+	Role  types.WorkspaceRole `json:"role"`
+}
+
+// authorizeWorkspace resolves the workspace of the request and checks the session user's role
+// in it allows the request. Users without a workspace get a personal one. The installation
+// wide routes are for administrators whatever their role.
+func (s *APIServer) authorizeWorkspace(w http.ResponseWriter, r *http.Request, session *types.Session) (*types.UserWorkspace, bool) {
+	if installationResources[requestResource(r)] {
+		user, err := s.store.GetUserByID(session.UserId)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, err.Error())
+			return nil, false
+		}
+		if !user.Admin {
+			respondWithError(w, http.StatusForbidden, "only an administrator can access this route")
+			return nil, false
+		}
+	}
+
+	workspaces, err := s.store.GetUserWorkspaces(session.UserId)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return nil, false
+	}
+
+	if len(workspaces) == 0 {
+		workspace, err := s.createPersonalWorkspace(session.UserId)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, err.Error())
+			return nil, false
+		}
+		workspaces = append(workspaces, workspace)
+	}
+
+	workspaceID, ok := s.getRequestWorkspaceID(w, r)
+	if !ok {
+		return nil, false
+	}
+
+	workspace := workspaces[0]
+	if workspaceID != nil {
+		workspace = nil
+		for _, userWorkspace := range workspaces {
+			if userWorkspace.ID == *workspaceID {
+				workspace = userWorkspace
+			}
+		}
+	}
+
+	if workspace == nil || !workspace.Role.Allows(requiredWorkspaceRole(r)) {
+		respondWithError(w, http.StatusForbidden, "you don't have access to this workspace")
+		return nil, false
+	}
+
+	return workspace, true
+}
+
+// getRequestWorkspaceID is the workspace of the record in the path, of the header, or nil
+// to use the user's first workspace
+func (s *APIServer) getRequestWorkspaceID(w http.ResponseWriter, r *http.Request) (*uuid.UUID, bool) {
+	resource := requestResource(r)
+
+	if id, err := getAndParseIDFromRequest(r); err == nil {
+		if resource == "workspace" {
+			return &id, true
+		}
+
+		if table, ok := workspaceResources[requestIDResource(r)]; ok {
+			workspaceID, err := s.store.GetResourceWorkspaceID(table, id)
+			if err != nil {
+				respondWithError(w, http.StatusNotFound, err.Error())
+				return nil, false
+			}
+			return &workspaceID, true
+		}
+	}
+
+	if header := r.Header.Get(workspaceHeader); header != "" {
+		workspaceID, err := uuid.Parse(header)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("%s is not a valid id", workspaceHeader))
+			return nil, false
+		}
+		return &workspaceID, true
+	}
+
+	return nil, true
+}
+
+// requiredWorkspaceRole is the role needed for the request: viewers read, editors change the
+// budget, owners manage the members. Any member creates a new workspace.
+func requiredWorkspaceRole(r *http.Request) types.WorkspaceRole {
+	switch {
+	case r.Method == http.MethodGet || r.Method == http.MethodPost && r.URL.Path == "/workspace":
+		return types.WorkspaceRoleViewer
+	case requestResource(r) == "workspace":
+		return types.WorkspaceRoleOwner
+	default:
+		return types.WorkspaceRoleEditor
+	}
+}
+
+// requestResource is the first segment of the request path
+func requestResource(r *http.Request) string {
+	return strings.Split(strings.Trim(r.URL.Path, "/"), "/")[0]
+}
+
+// requestIDResource is the path before the {id} of the route, /import/csv/profile/{id} is a csv
+// profile while /import/{id} is an import job
+func requestIDResource(r *http.Request) string {
+	segments := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	for i, segment := range segments {
+		if segment == r.PathValue("id") {
+			return strings.Join(segments[:i], "/")
+		}
+	}
+	return segments[0]
+}
+
+func (s *APIServer) createPersonalWorkspace(userID uuid.UUID) (*types.UserWorkspace, error) {
+	user, err := s.store.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	workspace := &types.Workspace{
+		ID:        uuid.Must(uuid.NewV7()),
+		Name:      defaultWorkspaceName,
+		CreatedAt: time.Now().UTC(),
+		UpdatedAt: time.Now().UTC(),
+	}
+	if err := s.createWorkspace(workspace, user); err != nil {
+		return nil, err
+	}
+
+	return &types.UserWorkspace{Workspace: workspace, Role: types.WorkspaceRoleOwner}, nil
+}
+
+// createWorkspace creates the workspace with the user as its owner
+func (s *APIServer) createWorkspace(workspace *types.Workspace, user *types.User) error {
+	if err := s.store.CreateWorkspace(workspace); err != nil {
+		return err
+	}
+
+	return s.store.CreateWorkspaceMember(&types.WorkspaceMember{
+		ID:          uuid.Must(uuid.NewV7()),
+		WorkspaceID: workspace.ID,
+		UserID:      &user.ID,
+		Email:       strings.ToLower(user.Email),
+		Role:        types.WorkspaceRoleOwner,
+		CreatedAt:   time.Now().UTC(),
+		UpdatedAt:   time.Now().UTC(),
+	})
+}
+
+// withWorkspace stores the workspace of the request in its context
+func withWorkspace(r *http.Request, workspace *types.UserWorkspace) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), workspaceKey, workspace))
+}
+
+// workspaceIDFor returns the workspace of the request, nil outside of a session
+func (s *APIServer) workspaceIDFor(r *http.Request) *uuid.UUID {
+	if workspace, ok := r.Context().Value(workspaceKey).(*types.UserWorkspace); ok {
+		return &workspace.ID
+	}
+	return nil
+}
+
+func (s *APIServer) handleCreateWorkspace(w http.ResponseWriter, r *http.Request) {
+	input := CreateWorkspaceInput{}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	input.Name = strings.TrimSpace(input.Name)
+	if input.Name == "" {
+		respondWithError(w, http.StatusBadRequest, "name is required")
+		return
+	}
+
+	user, err := s.store.GetUserByID(*s.storeFor(r).userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	workspace := &types.Workspace{
+		ID:        uuid.Must(uuid.NewV7()),
+		Name:      input.Name,
+		CreatedAt: time.Now().UTC(),
+		UpdatedAt: time.Now().UTC(),
+	}
+	if err := s.createWorkspace(workspace, user); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, &types.UserWorkspace{Workspace: workspace, Role: types.WorkspaceRoleOwner})
+}
+
+func (s *APIServer) handleGetWorkspaces(w http.ResponseWriter, r *http.Request) {
+	workspaces, err := s.store.GetUserWorkspaces(*s.storeFor(r).userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, workspaces)
+}
+
+func (s *APIServer) handleGetWorkspaceMembers(w http.ResponseWriter, r *http.Request) {
+	uID, err := getAndParseIDFromRequest(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	members, err := s.store.GetWorkspaceMembers(&uID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, members)
+}
+
+// handleCreateWorkspaceMember invites the email to the workspace, the member is linked to the
+// user with that email now or once they sign up
+func (s *APIServer) handleCreateWorkspaceMember(w http.ResponseWriter, r *http.Request) {
+	uID, err := getAndParseIDFromRequest(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	input := WorkspaceMemberInput{}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	input.Email = strings.ToLower(strings.TrimSpace(input.Email))
+	if !strings.Contains(input.Email, "@") {
+		respondWithError(w, http.StatusBadRequest, "email is not valid")
+		return
+	}
+	if !input.Role.Valid() {
+		respondWithError(w, http.StatusBadRequest, "role must be owner, editor or viewer")
+		return
+	}
+
+	member := &types.WorkspaceMember{
+		ID:          uuid.Must(uuid.NewV7()),
+		WorkspaceID: uID,
+		Email:       input.Email,
+		Role:        input.Role,
+		CreatedAt:   time.Now().UTC(),
+		UpdatedAt:   time.Now().UTC(),
+	}
+
+	if user, err := s.store.GetUserByEmail(input.Email); err == nil {
+		member.UserID = &user.ID
+	}
+
+	if err := s.store.CreateWorkspaceMember(member); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, member)
+}
+
+func (s *APIServer) handleUpdateWorkspaceMember(w http.ResponseWriter, r *http.Request) {
+	member, ok := s.getWorkspaceMemberFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	input := WorkspaceMemberInput{}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if !input.Role.Valid() {
+		respondWithError(w, http.StatusBadRequest, "role must be owner, editor or viewer")
+		return
+	}
+
+	if member.Role == types.WorkspaceRoleOwner && input.Role != types.WorkspaceRoleOwner {
+		if ok := s.checkOtherOwner(w, member); !ok {
+			return
+		}
+	}
+
+	member.Role = input.Role
+	member.UpdatedAt = time.Now().UTC()
+	if err := s.store.UpdateWorkspaceMember(member); err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, member)
+}
+
+func (s *APIServer) handleDeleteWorkspaceMember(w http.ResponseWriter, r *http.Request) {
+	member, ok := s.getWorkspaceMemberFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if member.Role == types.WorkspaceRoleOwner {
+		if ok := s.checkOtherOwner(w, member); !ok {
+			return
+		}
+	}
+
+	if err := s.store.DeleteWorkspaceMember(member.ID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, "Workspace member deleted successfully")
+}
+
+// getWorkspaceMemberFromRequest returns the member in the path if it belongs to the workspace in the path
+func (s *APIServer) getWorkspaceMemberFromRequest(w http.ResponseWriter, r *http.Request) (*types.WorkspaceMember, bool) {
+	uID, err := getAndParseIDFromRequest(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return nil, false
+	}
+
+	memberID, err := uuid.Parse(r.PathValue("memberId"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "error parsing member id from request")
+		return nil, false
+	}
+
+	member, err := s.store.GetWorkspaceMemberByID(memberID)
+	if err != nil || member.WorkspaceID != uID {
+		respondWithError(w, http.StatusNotFound, fmt.Sprintf("workspace member %v not found", memberID))
+		return nil, false
+	}
+
+	return member, true
+}
+
+// checkOtherOwner fails unless the workspace keeps an owner without the member
+func (s *APIServer) checkOtherOwner(w http.ResponseWriter, member *types.WorkspaceMember) bool {
+	members, err := s.store.GetWorkspaceMembers(&member.WorkspaceID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return false
+	}
+
+	for _, other := range members {
+		if other.ID != member.ID && other.Role == types.WorkspaceRoleOwner && other.UserID != nil {
+			return true
+		}
+	}
+
+	respondWithError(w, http.StatusBadRequest, "a workspace must keep at least one owner")
+	return false
+}
+
+// workspaceStore keeps the reads and changes made through it to the records of one workspace.
+// Records of other workspaces are reported as not found.
+type workspaceStore struct {
+	Storage
+	workspaceID uuid.UUID
+}
+
+// NewWorkspaceStore keeps the store to one workspace, as the command line imports run outside a session
+func NewWorkspaceStore(store Storage, workspaceID uuid.UUID) Storage {
+	return newWorkspaceStore(store, workspaceID)
+}
+
+func newWorkspaceStore(store Storage, workspaceID uuid.UUID) *workspaceStore {
+	return &workspaceStore{Storage: store, workspaceID: workspaceID}
+}
+
+// checkResource fails as not found when the record belongs to another workspace
+func (ws *workspaceStore) checkResource(table string, id uuid.UUID) error {
+	workspaceID, err := ws.Storage.GetResourceWorkspaceID(table, id)
+	if err != nil {
+		return err
+	}
+	if workspaceID != ws.workspaceID {
+		return fmt.Errorf("%s %v not found", strings.ReplaceAll(table, "_", " "), id)
+	}
+	return nil
+}
+
+// checkReferences checks the account, category and card a transaction or series points to
+func (ws *workspaceStore) checkReferences(accountID, categoryID uuid.UUID, creditCardID *uuid.UUID) error {
+	if err := ws.checkResource("account", accountID); err != nil {
+		return err
+	}
+	if err := ws.checkResource("category", categoryID); err != nil {
+		return err
+	}
+	if creditCardID != nil {
+		return ws.checkResource("credit_card", *creditCardID)
+	}
+	return nil
+}
+
+// accountIDs are the accounts of the workspace
+func (ws *workspaceStore) accountIDs() (map[uuid.UUID]bool, error) {
+	accounts, err := ws.GetAccounts()
+	if err != nil {
+		return nil, err
+	}
+
+	ids := map[uuid.UUID]bool{}
+	for _, account := range accounts {
+		ids[account.ID] = true
+	}
+	return ids, nil
+}
+
+// Account
+
+func (ws *workspaceStore) CreateAccount(account *types.Account) error {
+	account.WorkspaceID = ws.workspaceID
+	return ws.Storage.CreateAccount(account)
+}
+
+func (ws *workspaceStore) UpdateAccount(account *types.Account) error {
+	if err := ws.checkResource("account", account.ID); err != nil {
+		return err
+	}
+	return ws.Storage.UpdateAccount(account)
+}
+
+func (ws *workspaceStore) UpdateAccountBalance(id uuid.UUID, amount float32, transactionType types.TransactionType, date time.Time) error {
+	if err := ws.checkResource("account", id); err != nil {
+		return err
+	}
+	return ws.Storage.UpdateAccountBalance(id, amount, transactionType, date)
+}
+
+func (ws *workspaceStore) GetAccountBalanceAudit(id uuid.UUID) (*types.AccountBalanceAudit, error) {
+	if err := ws.checkResource("account", id); err != nil {
+		return nil, err
+	}
+	return ws.Storage.GetAccountBalanceAudit(id)
+}
+
+func (ws *workspaceStore) SetAccountBalance(id uuid.UUID, balance float32) error {
+	if err := ws.checkResource("account", id); err != nil {
+		return err
+	}
+	return ws.Storage.SetAccountBalance(id, balance)
+}
+
+func (ws *workspaceStore) DeleteAccount(id uuid.UUID) error {
+	if err := ws.checkResource("account", id); err != nil {
+		return err
+	}
+	return ws.Storage.DeleteAccount(id)
+}
+
+func (ws *workspaceStore) GetAccountByID(id uuid.UUID) (*types.Account, error) {
+	if err := ws.checkResource("account", id); err != nil {
+		return nil, err
+	}
+	return ws.Storage.GetAccountByID(id)
+}
+
+func (ws *workspaceStore) GetAccounts() ([]*types.Account, error) {
+	accounts, err := ws.Storage.GetAccounts()
+	if err != nil {
+		return nil, err
+	}
+
+	workspaceAccounts := []*types.Account{}
+	for _, account := range accounts {
+		if account.WorkspaceID == ws.workspaceID {
+			workspaceAccounts = append(workspaceAccounts, account)
+		}
+	}
+	return workspaceAccounts, nil
+}
+
+func (ws *workspaceStore) GetUniqueAccount(name string, accountType types.AccountType) (*types.Account, error) {
+	accounts, err := ws.GetAccounts()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, account := range accounts {
+		if account.Name == name && account.AccountType == accountType {
+			return account, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+// Category
+
+func (ws *workspaceStore) CreateCategory(category *types.Category) error {
+	category.WorkspaceID = ws.workspaceID
+	return ws.Storage.CreateCategory(category)
+}
+
+func (ws *workspaceStore) UpdateCategory(category *types.Category) error {
+	if err := ws.checkResource("category", category.ID); err != nil {
+		return err
+	}
+	return ws.Storage.UpdateCategory(category)
+}
+
+func (ws *workspaceStore) ArchiveCategory(id uuid.UUID) error {
+	if err := ws.checkResource("category", id); err != nil {
+		return err
+	}
+	return ws.Storage.ArchiveCategory(id)
+}
+
+func (ws *workspaceStore) UnarchiveCategory(id uuid.UUID) error {
+	if err := ws.checkResource("category", id); err != nil {
+		return err
+	}
+	return ws.Storage.UnarchiveCategory(id)
+}
+
+func (ws *workspaceStore) GetCategoryByID(id uuid.UUID) (*types.Category, error) {
+	if err := ws.checkResource("category", id); err != nil {
+		return nil, err
+	}
+	return ws.Storage.GetCategoryByID(id)
+}
+
+func (ws *workspaceStore) GetCategory() ([]*types.Category, error) {
+	categories, err := ws.Storage.GetCategory()
+	if err != nil {
+		return nil, err
+	}
+
+	workspaceCategories := []*types.Category{}
+	for _, category := range categories {
+		if category.WorkspaceID == ws.workspaceID {
+			workspaceCategories = append(workspaceCategories, category)
+		}
+	}
+	return workspaceCategories, nil
+}
+
+func (ws *workspaceStore) GetCategoryByDescription(description string) (*types.Category, error) {
+	categories, err := ws.GetCategory()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, category := range categories {
+		if category.Description == description {
+			return category, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+// Credit card
+
+func (ws *workspaceStore) CreateCreditCard(creditCard *types.CreditCard) error {
+	creditCard.WorkspaceID = ws.workspaceID
+	return ws.Storage.CreateCreditCard(creditCard)
+}
+
+func (ws *workspaceStore) UpdateCreditCard(creditCard *types.CreditCard) error {
+	if err := ws.checkResource("credit_card", creditCard.ID); err != nil {
+		return err
+	}
+	return ws.Storage.UpdateCreditCard(creditCard)
+}
+
+func (ws *workspaceStore) ArchiveCreditCard(id uuid.UUID) error {
+	if err := ws.checkResource("credit_card", id); err != nil {
+		return err
+	}
+	return ws.Storage.ArchiveCreditCard(id)
+}
+
+func (ws *workspaceStore) UnarchiveCreditCard(id uuid.UUID) error {
+	if err := ws.checkResource("credit_card", id); err != nil {
+		return err
+	}
+	return ws.Storage.UnarchiveCreditCard(id)
+}
+
+func (ws *workspaceStore) GetCreditCardByID(id uuid.UUID) (*types.CreditCard, error) {
+	if err := ws.checkResource("credit_card", id); err != nil {
+		return nil, err
+	}
+	return ws.Storage.GetCreditCardByID(id)
+}
+
+func (ws *workspaceStore) GetCreditCard() ([]*types.CreditCard, error) {
+	creditCards, err := ws.Storage.GetCreditCard()
+	if err != nil {
+		return nil, err
+	}
+
+	workspaceCreditCards := []*types.CreditCard{}
+	for _, creditCard := range creditCards {
+		if creditCard.WorkspaceID == ws.workspaceID {
+			workspaceCreditCards = append(workspaceCreditCards, creditCard)
+		}
+	}
+	return workspaceCreditCards, nil
+}
+
+func (ws *workspaceStore) GetUnpaidCreditCardTransactions(creditCardID uuid.UUID, from time.Time) ([]*types.Transaction, error) {
+	if err := ws.checkResource("credit_card", creditCardID); err != nil {
+		return nil, err
+	}
+	return ws.Storage.GetUnpaidCreditCardTransactions(creditCardID, from)
+}
+
+func (ws *workspaceStore) GetCreditCardByName(name string) (*types.CreditCard, error) {
+	creditCards, err := ws.GetCreditCard()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, creditCard := range creditCards {
+		if creditCard.Name == name {
+			return creditCard, nil
+		}
+	}
+	return nil, fmt.Errorf("credit card %v not found", name)
+}
+
+// Transaction
+
+func (ws *workspaceStore) CreateTransaction(transaction *types.Transaction) error {
+	if err := ws.checkReferences(transaction.AccountID, transaction.CategoryID, transaction.CreditCardID); err != nil {
+		return err
+	}
+	return ws.Storage.CreateTransaction(transaction)
+}
+
+func (ws *workspaceStore) UpdateTransaction(id uuid.UUID, update *types.Transaction) error {
+	if err := ws.checkResource("transaction", id); err != nil {
+		return err
+	}
+	if err := ws.checkReferences(update.AccountID, update.CategoryID, update.CreditCardID); err != nil {
+		return err
+	}
+	return ws.Storage.UpdateTransaction(id, update)
+}
+
+func (ws *workspaceStore) UpdateTransactionDate(id uuid.UUID, date time.Time) error {
+	if err := ws.checkResource("transaction", id); err != nil {
+		return err
+	}
+	return ws.Storage.UpdateTransactionDate(id, date)
+}
+
+func (ws *workspaceStore) FulfillTransaction(id uuid.UUID) error {
+	if err := ws.checkResource("transaction", id); err != nil {
+		return err
+	}
+	return ws.Storage.FulfillTransaction(id)
+}
+
+func (ws *workspaceStore) DeleteTransaction(id uuid.UUID) error {
+	if err := ws.checkResource("transaction", id); err != nil {
+		return err
+	}
+	return ws.Storage.DeleteTransaction(id)
+}
+
+func (ws *workspaceStore) UnarchiveTransaction(id uuid.UUID) error {
+	if err := ws.checkResource("transaction", id); err != nil {
+		return err
+	}
+	return ws.Storage.UnarchiveTransaction(id)
+}
+
+func (ws *workspaceStore) GetTransactionByID(id uuid.UUID) (*types.Transaction, error) {
+	if err := ws.checkResource("transaction", id); err != nil {
+		return nil, err
+	}
+	return ws.Storage.GetTransactionByID(id)
+}
+
+func (ws *workspaceStore) GetTransactionsByIDs(ids []uuid.UUID) ([]*types.Transaction, error) {
+	accountIDs, err := ws.accountIDs()
+	if err != nil {
+		return nil, err
+	}
+
+	transactions, err := ws.Storage.GetTransactionsByIDs(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	workspaceTransactions := []*types.Transaction{}
+	for _, transaction := range transactions {
+		if accountIDs[transaction.AccountID] {
+			workspaceTransactions = append(workspaceTransactions, transaction)
+		}
+	}
+	return workspaceTransactions, nil
+}
+
+func (ws *workspaceStore) GetTransactionsWithRecurringByDate(startDate, endDate time.Time, filter *types.TransactionFilter) ([]*types.TransactionView, error) {
+	return ws.Storage.GetTransactionsWithRecurringByDate(startDate, endDate, ws.filter(filter))
+}
+
+func (ws *workspaceStore) StreamTransactionsByDate(startDate, endDate time.Time, includeRecurring bool, filter *types.TransactionFilter, fn func(*types.TransactionView) error) error {
+	return ws.Storage.StreamTransactionsByDate(startDate, endDate, includeRecurring, ws.filter(filter), fn)
+}
+
+func (ws *workspaceStore) GetUnfulfilledTransactionsBefore(date time.Time) ([]*types.TransactionView, error) {
+	accountIDs, err := ws.accountIDs()
+	if err != nil {
+		return nil, err
+	}
+
+	transactions, err := ws.Storage.GetUnfulfilledTransactionsBefore(date)
+	if err != nil {
+		return nil, err
+	}
+
+	workspaceTransactions := []*types.TransactionView{}
+	for _, transaction := range transactions {
+		if accountIDs[transaction.AccountID] {
+			workspaceTransactions = append(workspaceTransactions, transaction)
+		}
+	}
+	return workspaceTransactions, nil
+}
+
+// filter copies the filter keeping it to the workspace accounts
+func (ws *workspaceStore) filter(filter *types.TransactionFilter) *types.TransactionFilter {
+	workspaceFilter := types.TransactionFilter{}
+	if filter != nil {
+		workspaceFilter = *filter
+	}
+	workspaceFilter.WorkspaceID = &ws.workspaceID
+	return &workspaceFilter
+}
+
+// Recurring transaction
+
+func (ws *workspaceStore) CreateRecurringTransaction(recurringTransaction *types.RecurringTransaction) error {
+	err := ws.checkReferences(recurringTransaction.AccountID, recurringTransaction.CategoryID, recurringTransaction.CreditCardID)
+	if err != nil {
+		return err
+	}
+	return ws.Storage.CreateRecurringTransaction(recurringTransaction)
+}
+
+func (ws *workspaceStore) UpdateRecurringTransaction(id uuid.UUID, update *types.RecurringTransaction) error {
+	if err := ws.checkResource("recurring_transaction", id); err != nil {
+		return err
+	}
+	return ws.Storage.UpdateRecurringTransaction(id, update)
+}
+
+func (ws *workspaceStore) ArchiveRecurringTransaction(id uuid.UUID) error {
+	if err := ws.checkResource("recurring_transaction", id); err != nil {
+		return err
+	}
+	return ws.Storage.ArchiveRecurringTransaction(id)
+}
+
+func (ws *workspaceStore) GetRecurringTransactionByID(id uuid.UUID) (*types.RecurringTransaction, error) {
+	if err := ws.checkResource("recurring_transaction", id); err != nil {
+		return nil, err
+	}
+	return ws.Storage.GetRecurringTransactionByID(id)
+}
+
+func (ws *workspaceStore) UnarchiveRecurringTransaction(id uuid.UUID) error {
+	if err := ws.checkResource("recurring_transaction", id); err != nil {
+		return err
+	}
+	return ws.Storage.UnarchiveRecurringTransaction(id)
+}
+
+// Reconciliation
+
+func (ws *workspaceStore) CreateReconciliation(reconciliation *types.Reconciliation) error {
+	if err := ws.checkResource("account", reconciliation.AccountID); err != nil {
+		return err
+	}
+	return ws.Storage.CreateReconciliation(reconciliation)
+}
+
+func (ws *workspaceStore) GetReconciliationByID(id uuid.UUID) (*types.Reconciliation, error) {
+	if err := ws.checkResource("reconciliation", id); err != nil {
+		return nil, err
+	}
+	return ws.Storage.GetReconciliationByID(id)
+}
+
+func (ws *workspaceStore) GetOpenReconciliation(accountID uuid.UUID) (*types.Reconciliation, error) {
+	if err := ws.checkResource("account", accountID); err != nil {
+		return nil, err
+	}
+	return ws.Storage.GetOpenReconciliation(accountID)
+}
+
+func (ws *workspaceStore) GetReconciliationTransactions(id uuid.UUID) ([]*types.Transaction, error) {
+	if err := ws.checkResource("reconciliation", id); err != nil {
+		return nil, err
+	}
+	return ws.Storage.GetReconciliationTransactions(id)
+}
+
+func (ws *workspaceStore) GetUnclearedTransactions(accountID uuid.UUID, statementDate time.Time) ([]*types.Transaction, error) {
+	if err := ws.checkResource("account", accountID); err != nil {
+		return nil, err
+	}
+	return ws.Storage.GetUnclearedTransactions(accountID, statementDate)
+}
+
+func (ws *workspaceStore) GetClearedBalance(accountID, reconciliationID uuid.UUID) (float32, error) {
+	if err := ws.checkResource("account", accountID); err != nil {
+		return 0, err
+	}
+	return ws.Storage.GetClearedBalance(accountID, reconciliationID)
+}
+
+func (ws *workspaceStore) GetReconciliations(accountID *uuid.UUID) ([]*types.Reconciliation, error) {
+	accountIDs, err := ws.accountIDs()
+	if err != nil {
+		return nil, err
+	}
+
+	reconciliations, err := ws.Storage.GetReconciliations(accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	workspaceReconciliations := []*types.Reconciliation{}
+	for _, reconciliation := range reconciliations {
+		if accountIDs[reconciliation.AccountID] {
+			workspaceReconciliations = append(workspaceReconciliations, reconciliation)
+		}
+	}
+	return workspaceReconciliations, nil
+}
+
+// Investment
+
+func (ws *workspaceStore) CreateHolding(holding *types.Holding) error {
+	if err := ws.checkResource("account", holding.AccountID); err != nil {
+		return err
+	}
+	return ws.Storage.CreateHolding(holding)
+}
+
+func (ws *workspaceStore) SaveHoldingPrice(price *types.HoldingPrice) error {
+	if err := ws.checkResource("holding", price.HoldingID); err != nil {
+		return err
+	}
+	return ws.Storage.SaveHoldingPrice(price)
+}
+
+func (ws *workspaceStore) CreateHoldingMovement(movement *types.HoldingMovement) error {
+	if err := ws.checkResource("holding", movement.HoldingID); err != nil {
+		return err
+	}
+	return ws.Storage.CreateHoldingMovement(movement)
+}
+
+func (ws *workspaceStore) SaveHoldingMovement(movement *types.HoldingMovement, holding *types.Holding, transactions []*types.Transaction) error {
+	if err := ws.checkResource("holding", holding.ID); err != nil {
+		return err
+	}
+	for _, transaction := range transactions {
+		if err := ws.checkReferences(transaction.AccountID, transaction.CategoryID, transaction.CreditCardID); err != nil {
+			return err
+		}
+	}
+	return ws.Storage.SaveHoldingMovement(movement, holding, transactions)
+}
+
+func (ws *workspaceStore) UpdateHolding(holding *types.Holding) error {
+	if err := ws.checkResource("holding", holding.ID); err != nil {
+		return err
+	}
+	return ws.Storage.UpdateHolding(holding)
+}
+
+func (ws *workspaceStore) GetHoldingByID(id uuid.UUID) (*types.Holding, error) {
+	if err := ws.checkResource("holding", id); err != nil {
+		return nil, err
+	}
+	return ws.Storage.GetHoldingByID(id)
+}
+
+// holdingIDs are the holdings of the workspace
+func (ws *workspaceStore) holdingIDs() (map[uuid.UUID]bool, error) {
+	holdings, err := ws.GetHoldings(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := map[uuid.UUID]bool{}
+	for _, holding := range holdings {
+		ids[holding.ID] = true
+	}
+	return ids, nil
+}
+
+func (ws *workspaceStore) GetHoldingPrices(holdingID *uuid.UUID) ([]*types.HoldingPrice, error) {
+	holdingIDs, err := ws.holdingIDs()
+	if err != nil {
+		return nil, err
+	}
+	if holdingID != nil && !holdingIDs[*holdingID] {
+		return nil, fmt.Errorf("holding %v not found", *holdingID)
+	}
+
+	prices, err := ws.Storage.GetHoldingPrices(holdingID)
+	if err != nil {
+		return nil, err
+	}
+
+	workspacePrices := []*types.HoldingPrice{}
+	for _, price := range prices {
+		if holdingIDs[price.HoldingID] {
+			workspacePrices = append(workspacePrices, price)
+		}
+	}
+	return workspacePrices, nil
+}
+
+func (ws *workspaceStore) GetHoldingMovements(holdingID *uuid.UUID) ([]*types.HoldingMovement, error) {
+	holdingIDs, err := ws.holdingIDs()
+	if err != nil {
+		return nil, err
+	}
+	if holdingID != nil && !holdingIDs[*holdingID] {
+		return nil, fmt.Errorf("holding %v not found", *holdingID)
+	}
+
+	movements, err := ws.Storage.GetHoldingMovements(holdingID)
+	if err != nil {
+		return nil, err
+	}
+
+	workspaceMovements := []*types.HoldingMovement{}
+	for _, movement := range movements {
+		if holdingIDs[movement.HoldingID] {
+			workspaceMovements = append(workspaceMovements, movement)
+		}
+	}
+	return workspaceMovements, nil
+}
+
+func (ws *workspaceStore) GetHoldings(accountID *uuid.UUID) ([]*types.Holding, error) {
+	accountIDs, err := ws.accountIDs()
+	if err != nil {
+		return nil, err
+	}
+
+	holdings, err := ws.Storage.GetHoldings(accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	workspaceHoldings := []*types.Holding{}
+	for _, holding := range holdings {
+		if accountIDs[holding.AccountID] {
+			workspaceHoldings = append(workspaceHoldings, holding)
+		}
+	}
+	return workspaceHoldings, nil
+}
+
+func (ws *workspaceStore) GetPositions(accountID *uuid.UUID) ([]*types.Position, error) {
+	accountIDs, err := ws.accountIDs()
+	if err != nil {
+		return nil, err
+	}
+
+	positions, err := ws.Storage.GetPositions(accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	workspacePositions := []*types.Position{}
+	for _, position := range positions {
+		if accountIDs[position.Holding.AccountID] {
+			workspacePositions = append(workspacePositions, position)
+		}
+	}
+	return workspacePositions, nil
+}
+
+// Loan
+
+func (ws *workspaceStore) CreateLoan(loan *types.Loan) error {
+	if err := ws.checkReferences(loan.AccountID, loan.CategoryID, nil); err != nil {
+		return err
+	}
+	return ws.Storage.CreateLoan(loan)
+}
+
+func (ws *workspaceStore) GetLoanByID(id uuid.UUID) (*types.Loan, error) {
+	if err := ws.checkResource("loan", id); err != nil {
+		return nil, err
+	}
+	return ws.Storage.GetLoanByID(id)
+}
+
+func (ws *workspaceStore) SaveLoanInstallments(transactions []*types.Transaction, installments, replaced []*types.LoanInstallment) error {
+	created := map[uuid.UUID]bool{}
+	for _, transaction := range transactions {
+		if err := ws.checkReferences(transaction.AccountID, transaction.CategoryID, transaction.CreditCardID); err != nil {
+			return err
+		}
+		created[transaction.ID] = true
+	}
+
+	for _, installment := range installments {
+		if err := ws.checkResource("loan", installment.LoanID); err != nil {
+			return err
+		}
+		if created[installment.TransactionID] {
+			continue
+		}
+		if err := ws.checkResource("transaction", installment.TransactionID); err != nil {
+			return err
+		}
+	}
+
+	for _, installment := range replaced {
+		if err := ws.checkResource("loan_installment", installment.ID); err != nil {
+			return err
+		}
+	}
+	return ws.Storage.SaveLoanInstallments(transactions, installments, replaced)
+}
+
+func (ws *workspaceStore) GetLoanInstallments(loanID *uuid.UUID) ([]*types.LoanInstallment, error) {
+	if loanID != nil {
+		if err := ws.checkResource("loan", *loanID); err != nil {
+			return nil, err
+		}
+		return ws.Storage.GetLoanInstallments(loanID)
+	}
+
+	loans, err := ws.GetLoans()
+	if err != nil {
+		return nil, err
+	}
+	loanIDs := map[uuid.UUID]bool{}
+	for _, loan := range loans {
+		loanIDs[loan.ID] = true
+	}
+
+	installments, err := ws.Storage.GetLoanInstallments(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	workspaceInstallments := []*types.LoanInstallment{}
+	for _, installment := range installments {
+		if loanIDs[installment.LoanID] {
+			workspaceInstallments = append(workspaceInstallments, installment)
+		}
+	}
+	return workspaceInstallments, nil
+}
+
+func (ws *workspaceStore) GetLoans() ([]*types.Loan, error) {
+	accountIDs, err := ws.accountIDs()
+	if err != nil {
+		return nil, err
+	}
+
+	loans, err := ws.Storage.GetLoans()
+	if err != nil {
+		return nil, err
+	}
+
+	workspaceLoans := []*types.Loan{}
+	for _, loan := range loans {
+		if accountIDs[loan.AccountID] {
+			workspaceLoans = append(workspaceLoans, loan)
+		}
+	}
+	return workspaceLoans, nil
+}
+
+// Scenario
+
+func (ws *workspaceStore) CreateScenario(scenario *types.Scenario) error {
+	scenario.WorkspaceID = ws.workspaceID
+	return ws.Storage.CreateScenario(scenario)
+}
+
+func (ws *workspaceStore) UpdateScenario(scenario *types.Scenario) error {
+	if err := ws.checkResource("scenario", scenario.ID); err != nil {
+		return err
+	}
+	return ws.Storage.UpdateScenario(scenario)
+}
+
+func (ws *workspaceStore) DeleteScenario(id uuid.UUID) error {
+	if err := ws.checkResource("scenario", id); err != nil {
+		return err
+	}
+	return ws.Storage.DeleteScenario(id)
+}
+
+func (ws *workspaceStore) GetScenarioByID(id uuid.UUID) (*types.Scenario, error) {
+	if err := ws.checkResource("scenario", id); err != nil {
+		return nil, err
+	}
+	return ws.Storage.GetScenarioByID(id)
+}
+
+// Import
+
+func (ws *workspaceStore) CreateImportJob(job *types.ImportJob) error {
+	job.WorkspaceID = ws.workspaceID
+	return ws.Storage.CreateImportJob(job)
+}
+
+func (ws *workspaceStore) GetImportJobByID(id uuid.UUID) (*types.ImportJob, error) {
+	if err := ws.checkResource("import_job", id); err != nil {
+		return nil, err
+	}
+	return ws.Storage.GetImportJobByID(id)
+}
+
+func (ws *workspaceStore) CreateCSVProfile(profile *types.CSVProfile) error {
+	if err := ws.checkReferences(profile.AccountID, profile.CategoryID, profile.CreditCardID); err != nil {
+		return err
+	}
+	profile.WorkspaceID = ws.workspaceID
+	return ws.Storage.CreateCSVProfile(profile)
+}
+
+func (ws *workspaceStore) DeleteCSVProfile(id uuid.UUID) error {
+	if err := ws.checkResource("csv_profile", id); err != nil {
+		return err
+	}
+	return ws.Storage.DeleteCSVProfile(id)
+}
+
+func (ws *workspaceStore) GetCSVProfileByID(id uuid.UUID) (*types.CSVProfile, error) {
+	if err := ws.checkResource("csv_profile", id); err != nil {
+		return nil, err
+	}
+	return ws.Storage.GetCSVProfileByID(id)
+}
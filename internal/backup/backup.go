@@ -0,0 +1,179 @@
+package backup
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mdsavian/budget-tracker-api/internal/types"
+)
+
+// Version is increased whenever the dump layout changes in a way older restores can't read.
+// Version 2 added reconciliations, scenarios, holdings, loans and workspaces, version 1
+// backups are restored into a new workspace.
+const Version = 2
+
+// ErrNotEmpty is returned by Restore when there is data and the restore is not forced
+var ErrNotEmpty = errors.New("there is data in the database, use force to replace it")
+
+type Storage interface {
+	GetAccounts() ([]*types.Account, error)
+	GetCategory() ([]*types.Category, error)
+	GetCreditCard() ([]*types.CreditCard, error)
+	GetRecurringTransactions() ([]*types.RecurringTransaction, error)
+	GetTransactions() ([]*types.Transaction, error)
+	GetCSVProfiles(workspaceID *uuid.UUID) ([]*types.CSVProfile, error)
+	GetReconciliations(accountID *uuid.UUID) ([]*types.Reconciliation, error)
+	GetScenarios(workspaceID *uuid.UUID) ([]*types.Scenario, error)
+	GetHoldings(accountID *uuid.UUID) ([]*types.Holding, error)
+	GetHoldingPrices(holdingID *uuid.UUID) ([]*types.HoldingPrice, error)
+	GetHoldingMovements(holdingID *uuid.UUID) ([]*types.HoldingMovement, error)
+	GetLoans() ([]*types.Loan, error)
+	GetLoanInstallments(loanID *uuid.UUID) ([]*types.LoanInstallment, error)
+	GetWorkspaces() ([]*types.Workspace, error)
+	GetWorkspaceMembers(workspaceID *uuid.UUID) ([]*types.WorkspaceMember, error)
+	HasBudgetData() (bool, error)
+	RestoreBackup(backup *types.Backup, replace bool) error
+}
+
+// Create reads everything the backup holds from the store
+func Create(store Storage) (*types.Backup, error) {
+	backup := &types.Backup{Version: Version, CreatedAt: time.Now().UTC()}
+
+	var err error
+	if backup.Workspaces, err = store.GetWorkspaces(); err != nil {
+		return nil, err
+	}
+	if backup.WorkspaceMembers, err = store.GetWorkspaceMembers(nil); err != nil {
+		return nil, err
+	}
+	if backup.Accounts, err = store.GetAccounts(); err != nil {
+		return nil, err
+	}
+	if backup.Categories, err = store.GetCategory(); err != nil {
+		return nil, err
+	}
+	if backup.CreditCards, err = store.GetCreditCard(); err != nil {
+		return nil, err
+	}
+	if backup.RecurringTransactions, err = store.GetRecurringTransactions(); err != nil {
+		return nil, err
+	}
+	if backup.Transactions, err = store.GetTransactions(); err != nil {
+		return nil, err
+	}
+	if backup.CSVProfiles, err = store.GetCSVProfiles(nil); err != nil {
+		return nil, err
+	}
+	if backup.Reconciliations, err = store.GetReconciliations(nil); err != nil {
+		return nil, err
+	}
+	if backup.Scenarios, err = store.GetScenarios(nil); err != nil {
+		return nil, err
+	}
+	if backup.Holdings, err = store.GetHoldings(nil); err != nil {
+		return nil, err
+	}
+	if backup.HoldingPrices, err = store.GetHoldingPrices(nil); err != nil {
+		return nil, err
+	}
+	if backup.HoldingMovements, err = store.GetHoldingMovements(nil); err != nil {
+		return nil, err
+	}
+	if backup.Loans, err = store.GetLoans(); err != nil {
+		return nil, err
+	}
+	if backup.LoanInstallments, err = store.GetLoanInstallments(nil); err != nil {
+		return nil, err
+	}
+
+	return backup, nil
+}
+
+// Restore recreates the backup data with the same ids. It refuses to run when
+// there is data in the store unless force is set, in which case the data is replaced.
+func Restore(store Storage, backup *types.Backup, force bool) error {
+	if backup.Version < 1 || backup.Version > Version {
+		return fmt.Errorf("backup version %d is not supported", backup.Version)
+	}
+
+	hasData, err := store.HasBudgetData()
+	if err != nil {
+		return err
+	}
+	if hasData && !force {
+		return ErrNotEmpty
+	}
+
+	return store.RestoreBackup(backup, hasData)
+}
+
+// Section is how many records of a kind the backup holds
+type Section struct {
+	Name  string
+	Count int
+}
+
+// Sections counts every kind of record in the backup, in the order they are restored
+func Sections(backup *types.Backup) []Section {
+	return []Section{
+		{"workspaces", len(backup.Workspaces)},
+		{"workspaceMembers", len(backup.WorkspaceMembers)},
+		{"accounts", len(backup.Accounts)},
+		{"categories", len(backup.Categories)},
+		{"creditCards", len(backup.CreditCards)},
+		{"recurringTransactions", len(backup.RecurringTransactions)},
+		{"transactions", len(backup.Transactions)},
+		{"csvProfiles", len(backup.CSVProfiles)},
+		{"reconciliations", len(backup.Reconciliations)},
+		{"scenarios", len(backup.Scenarios)},
+		{"holdings", len(backup.Holdings)},
+		{"holdingPrices", len(backup.HoldingPrices)},
+		{"holdingMovements", len(backup.HoldingMovements)},
+		{"loans", len(backup.Loans)},
+		{"loanInstallments", len(backup.LoanInstallments)},
+	}
+}
+
+// Write encodes the backup as json, gzipped when compress is set
+func Write(w io.Writer, backup *types.Backup, compress bool) error {
+	if !compress {
+		return json.NewEncoder(w).Encode(backup)
+	}
+
+	zw := gzip.NewWriter(w)
+	if err := json.NewEncoder(zw).Encode(backup); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// Read decodes a backup written by Write, detecting gzip by its header
+func Read(r io.Reader) (*types.Backup, error) {
+	br := bufio.NewReader(r)
+	header, err := br.Peek(2)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	var reader io.Reader = br
+	if len(header) == 2 && header[0] == 0x1f && header[1] == 0x8b {
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		reader = zr
+	}
+
+	backup := &types.Backup{}
+	if err := json.NewDecoder(reader).Decode(backup); err != nil {
+		return nil, fmt.Errorf("invalid backup: %w", err)
+	}
+	return backup, nil
+}
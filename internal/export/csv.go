@@ -0,0 +1,48 @@
+package export
+
+import (
+	"encoding/csv"
+	"io"
+
+	"github.com/mdsavian/budget-tracker-api/internal/types"
+)
+
+const csvFlushRows = 500
+
+type csvWriter struct {
+	writer *csv.Writer
+	rows   int
+}
+
+func newCSVWriter(w io.Writer) (*csvWriter, error) {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(header); err != nil {
+		return nil, err
+	}
+
+	return &csvWriter{writer: writer}, nil
+}
+
+func (c *csvWriter) Write(transaction *types.TransactionView) error {
+	cells := transactionCells(transaction)
+	record := make([]string, len(cells))
+	for i, cell := range cells {
+		record[i] = cell.value
+	}
+
+	if err := c.writer.Write(record); err != nil {
+		return err
+	}
+
+	c.rows++
+	if c.rows%csvFlushRows == 0 {
+		c.writer.Flush()
+		return c.writer.Error()
+	}
+	return nil
+}
+
+func (c *csvWriter) Close() error {
+	c.writer.Flush()
+	return c.writer.Error()
+}
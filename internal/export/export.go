@@ -0,0 +1,74 @@
+package export
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/mdsavian/budget-tracker-api/internal/types"
+)
+
+type Format string
+
+const (
+	FormatCSV  Format = "csv"
+	FormatXlsx Format = "xlsx"
+)
+
+func (f Format) ContentType() string {
+	if f == FormatXlsx {
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// TransactionWriter writes transactions one by one, so exports never hold the whole period in memory
+type TransactionWriter interface {
+	Write(*types.TransactionView) error
+	Close() error
+}
+
+func NewTransactionWriter(format Format, w io.Writer) (TransactionWriter, error) {
+	switch format {
+	case FormatCSV:
+		return newCSVWriter(w)
+	case FormatXlsx:
+		return newXlsxWriter(w)
+	default:
+		return nil, fmt.Errorf("format %q is not supported, use csv or xlsx", format)
+	}
+}
+
+var header = []string{"Date", "Effectuated date", "Description", "Type", "Amount", "Account", "Credit card", "Category", "Fulfilled", "Recurring"}
+
+type cell struct {
+	value   string
+	numeric bool
+}
+
+func transactionCells(transaction *types.TransactionView) []cell {
+	effectuatedDate := ""
+	if transaction.EffectuatedDate != nil {
+		effectuatedDate = transaction.EffectuatedDate.Format("2006-01-02")
+	}
+
+	creditCard := ""
+	if transaction.CreditCard != nil {
+		creditCard = *transaction.CreditCard
+	}
+
+	return []cell{
+		{value: transaction.Date.Format("2006-01-02")},
+		{value: effectuatedDate},
+		{value: transaction.Description},
+		{value: transaction.TransactionType.String()},
+		{value: strconv.FormatFloat(transaction.Amount, 'f', 2, 64), numeric: true},
+		{value: transaction.Account},
+		{value: creditCard},
+		{value: transaction.Category},
+		{value: strconv.FormatBool(transaction.Fulfilled)},
+		// virtual recurring occurrences have no transaction yet
+		{value: strconv.FormatBool(transaction.ID == uuid.Nil)},
+	}
+}
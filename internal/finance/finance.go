@@ -0,0 +1,124 @@
+package finance
+
+import (
+	"math"
+	"time"
+
+	"github.com/mdsavian/budget-tracker-api/internal/types"
+)
+
+// Installment is one payment of an amortization schedule. Balance is the principal
+// still owed after the payment.
+type Installment struct {
+	Number       int
+	DueDate      time.Time
+	Payment      float64
+	Interest     float64
+	Amortization float64
+	Balance      float64
+}
+
+// MonthlyRate converts an effective annual rate in percent to the compounded monthly rate
+func MonthlyRate(annualPercent float64) float64 {
+	if annualPercent <= 0 {
+		return 0
+	}
+	return math.Pow(1+annualPercent/100, 1.0/12) - 1
+}
+
+// PricePayment is the constant payment of a Price (french) table
+func PricePayment(principal, monthlyRate float64, term int) float64 {
+	if term <= 0 {
+		return 0
+	}
+	if monthlyRate == 0 {
+		return principal / float64(term)
+	}
+	return principal * monthlyRate / (1 - math.Pow(1+monthlyRate, -float64(term)))
+}
+
+// Schedule builds the amortization table of the principal over term monthly installments,
+// the first due on firstDueDate. SAC amortizes the same principal every month, Price pays
+// the same amount. The last installment takes the rounding so the balance ends at zero.
+func Schedule(system types.AmortizationSystem, principal, monthlyRate float64, term int, firstDueDate time.Time) []Installment {
+	installments := []Installment{}
+	if term <= 0 || principal <= 0 {
+		return installments
+	}
+
+	amortization := RoundMoney(principal / float64(term))
+	payment := RoundMoney(PricePayment(principal, monthlyRate, term))
+
+	balance := RoundMoney(principal)
+	for number := 1; number <= term; number++ {
+		interest := RoundMoney(balance * monthlyRate)
+
+		installment := Installment{Number: number, DueDate: DueDate(firstDueDate, number), Interest: interest}
+		switch {
+		case number == term:
+			installment.Amortization = balance
+		case system == types.AmortizationPrice:
+			installment.Amortization = math.Min(RoundMoney(payment-interest), balance)
+		default:
+			installment.Amortization = math.Min(amortization, balance)
+		}
+		installment.Payment = RoundMoney(installment.Amortization + interest)
+
+		balance = RoundMoney(balance - installment.Amortization)
+		installment.Balance = balance
+		installments = append(installments, installment)
+	}
+	return installments
+}
+
+// Term is how many installments pay off the principal keeping the installment as it is:
+// the same amortization for SAC, the same payment for Price. It is used after an extra
+// payment to shorten the loan instead of lowering the installments.
+func Term(system types.AmortizationSystem, principal, monthlyRate float64, installment Installment) int {
+	if principal <= 0 {
+		return 0
+	}
+
+	var term float64
+	switch {
+	case system == types.AmortizationSAC:
+		if installment.Amortization <= 0 {
+			return 0
+		}
+		term = principal / installment.Amortization
+	case monthlyRate == 0:
+		if installment.Payment <= 0 {
+			return 0
+		}
+		term = principal / installment.Payment
+	default:
+		// the payment must cover more than the interest or the loan is never paid off
+		if installment.Payment <= principal*monthlyRate {
+			return 0
+		}
+		term = -math.Log(1-principal*monthlyRate/installment.Payment) / math.Log(1+monthlyRate)
+	}
+
+	// a remainder under 1% of an installment goes into the last one, rounding the
+	// installments to cents would otherwise add an installment of a few cents
+	return int(math.Ceil(term - 0.01))
+}
+
+// DueDate is the date of the installment number, a month apart from the first on its
+// day, or on the last day of shorter months
+func DueDate(firstDueDate time.Time, number int) time.Time {
+	year, month, day := firstDueDate.Date()
+	first := time.Date(year, month+time.Month(number-1), 1, 0, 0, 0, 0, firstDueDate.Location())
+	lastDay := first.AddDate(0, 1, -1).Day()
+	return first.AddDate(0, 0, min(day, lastDay)-1)
+}
+
+// RoundMoney rounds to cents, float arithmetic leaves residues that are not real amounts
+func RoundMoney(value float64) float64 {
+	return math.Round(value*100) / 100
+}
+
+// RoundCents is RoundMoney for the float32 amounts of transactions and balances
+func RoundCents(value float32) float32 {
+	return float32(RoundMoney(float64(value)))
+}
@@ -0,0 +1,115 @@
+package finance
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mdsavian/budget-tracker-api/internal/types"
+)
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func TestSchedule(t *testing.T) {
+	tests := []struct {
+		name         string
+		system       types.AmortizationSystem
+		principal    float64
+		monthlyRate  float64
+		term         int
+		firstDueDate time.Time
+		want         []Installment
+	}{
+		{
+			name:         "sac amortizes the same principal every month",
+			system:       types.AmortizationSAC,
+			principal:    1000,
+			monthlyRate:  0.01,
+			term:         4,
+			firstDueDate: date(2024, time.January, 31),
+			want: []Installment{
+				{Number: 1, DueDate: date(2024, time.January, 31), Payment: 260, Interest: 10, Amortization: 250, Balance: 750},
+				{Number: 2, DueDate: date(2024, time.February, 29), Payment: 257.5, Interest: 7.5, Amortization: 250, Balance: 500},
+				{Number: 3, DueDate: date(2024, time.March, 31), Payment: 255, Interest: 5, Amortization: 250, Balance: 250},
+				{Number: 4, DueDate: date(2024, time.April, 30), Payment: 252.5, Interest: 2.5, Amortization: 250, Balance: 0},
+			},
+		},
+		{
+			name:         "price pays the same amount and the last installment takes the rounding",
+			system:       types.AmortizationPrice,
+			principal:    1000,
+			monthlyRate:  0.01,
+			term:         3,
+			firstDueDate: date(2024, time.January, 31),
+			want: []Installment{
+				{Number: 1, DueDate: date(2024, time.January, 31), Payment: 340.02, Interest: 10, Amortization: 330.02, Balance: 669.98},
+				{Number: 2, DueDate: date(2024, time.February, 29), Payment: 340.02, Interest: 6.7, Amortization: 333.32, Balance: 336.66},
+				{Number: 3, DueDate: date(2024, time.March, 31), Payment: 340.03, Interest: 3.37, Amortization: 336.66, Balance: 0},
+			},
+		},
+		{
+			name:         "price without interest splits the principal",
+			system:       types.AmortizationPrice,
+			principal:    100,
+			monthlyRate:  0,
+			term:         3,
+			firstDueDate: date(2024, time.March, 15),
+			want: []Installment{
+				{Number: 1, DueDate: date(2024, time.March, 15), Payment: 33.33, Amortization: 33.33, Balance: 66.67},
+				{Number: 2, DueDate: date(2024, time.April, 15), Payment: 33.33, Amortization: 33.33, Balance: 33.34},
+				{Number: 3, DueDate: date(2024, time.May, 15), Payment: 33.34, Amortization: 33.34, Balance: 0},
+			},
+		},
+		{
+			name:         "no term",
+			system:       types.AmortizationSAC,
+			principal:    1000,
+			monthlyRate:  0.01,
+			term:         0,
+			firstDueDate: date(2024, time.January, 31),
+			want:         []Installment{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Schedule(tt.system, tt.principal, tt.monthlyRate, tt.term, tt.firstDueDate)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d installments, want %d", len(got), len(tt.want))
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("installment %d: got %+v, want %+v", i+1, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestTerm(t *testing.T) {
+	tests := []struct {
+		name        string
+		system      types.AmortizationSystem
+		principal   float64
+		monthlyRate float64
+		installment Installment
+		want        int
+	}{
+		{"sac exact", types.AmortizationSAC, 1000, 0.01, Installment{Amortization: 250}, 4},
+		{"sac remainder adds an installment", types.AmortizationSAC, 1000, 0.01, Installment{Amortization: 300}, 4},
+		{"sac cents go into the last installment", types.AmortizationSAC, 1000.05, 0.01, Installment{Amortization: 250}, 4},
+		{"price rounded payment", types.AmortizationPrice, 1000, 0.01, Installment{Payment: 340.02}, 3},
+		{"price without interest", types.AmortizationPrice, 100, 0, Installment{Payment: 33.33}, 3},
+		{"payment not covering the interest", types.AmortizationPrice, 1000, 0.01, Installment{Payment: 10}, 0},
+		{"nothing owed", types.AmortizationPrice, 0, 0.01, Installment{Payment: 100}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Term(tt.system, tt.principal, tt.monthlyRate, tt.installment); got != tt.want {
+				t.Errorf("got %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package finance
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/mdsavian/budget-tracker-api/internal/types"
+)
+
+// maxPayoffMonths bounds plans whose payments barely cover the interest
+const maxPayoffMonths = 600
+
+// ErrNoPayoff is returned when the payments don't pay off the debts within maxPayoffMonths
+var ErrNoPayoff = errors.New("the payments don't pay off the debts within 50 years")
+
+// Payoff plans the debts month by month from start. Every month the debts accrue interest
+// and get their minimum payment, and what is left of the budget, the minimums plus extra,
+// goes to the debts in the strategy order. The minimum of a debt paid off rolls over to the next.
+func Payoff(debts []*types.PayoffDebt, extra float64, start time.Time, strategy types.PayoffStrategy) (*types.PayoffPlan, error) {
+	plan := &types.PayoffPlan{Strategy: strategy, Debts: []*types.PayoffDebtResult{}, Months: []*types.PayoffMonth{}}
+
+	budget := extra
+	balances := map[*types.PayoffDebt]float64{}
+	results := map[*types.PayoffDebt]*types.PayoffDebtResult{}
+	order := []*types.PayoffDebt{}
+	for _, debt := range debts {
+		result := &types.PayoffDebtResult{PayoffDebt: debt}
+		plan.Debts = append(plan.Debts, result)
+		if debt.Balance <= 0 {
+			continue
+		}
+
+		budget += debt.MinimumPayment
+		balances[debt] = RoundMoney(debt.Balance)
+		results[debt] = result
+		order = append(order, debt)
+	}
+
+	sort.SliceStable(order, func(i, j int) bool {
+		if strategy == types.PayoffAvalanche && order[i].Rate != order[j].Rate {
+			return order[i].Rate > order[j].Rate
+		}
+		return order[i].Balance < order[j].Balance
+	})
+
+	for number := 1; len(balances) > 0; number++ {
+		if number > maxPayoffMonths {
+			return nil, ErrNoPayoff
+		}
+
+		month := &types.PayoffMonth{Date: DueDate(start, number), Payments: []*types.PayoffPayment{}}
+		payments := map[*types.PayoffDebt]*types.PayoffPayment{}
+		available := budget
+
+		for _, debt := range order {
+			balance, ok := balances[debt]
+			if !ok {
+				continue
+			}
+
+			interest := RoundMoney(balance * MonthlyRate(debt.Rate))
+			payment := &types.PayoffPayment{DebtID: debt.ID, Interest: interest}
+			payment.Payment = min(debt.MinimumPayment, balance+interest)
+			balances[debt] = RoundMoney(balance + interest - payment.Payment)
+			available -= payment.Payment
+
+			payments[debt] = payment
+			month.Payments = append(month.Payments, payment)
+			results[debt].Interest += interest
+			plan.TotalInterest += interest
+		}
+
+		for _, debt := range order {
+			balance, ok := balances[debt]
+			if !ok || available < 0.01 {
+				continue
+			}
+
+			extraPayment := RoundMoney(min(available, balance))
+			payments[debt].Payment += extraPayment
+			payments[debt].Extra = extraPayment
+			balances[debt] = RoundMoney(balance - extraPayment)
+			available -= extraPayment
+		}
+
+		for _, debt := range order {
+			payment, ok := payments[debt]
+			if !ok {
+				continue
+			}
+
+			payment.Payment = RoundMoney(payment.Payment)
+			payment.Balance = balances[debt]
+			month.Total += payment.Payment
+			if payment.Balance <= 0 {
+				results[debt].PayoffDate = month.Date
+				delete(balances, debt)
+			}
+		}
+
+		month.Total = RoundMoney(month.Total)
+		plan.TotalPaid += month.Total
+		plan.Months = append(plan.Months, month)
+		plan.PayoffDate = &month.Date
+	}
+
+	for _, result := range plan.Debts {
+		result.Interest = RoundMoney(result.Interest)
+	}
+	plan.TotalInterest = RoundMoney(plan.TotalInterest)
+	plan.TotalPaid = RoundMoney(plan.TotalPaid)
+	return plan, nil
+}
@@ -0,0 +1,91 @@
+package finance
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mdsavian/budget-tracker-api/internal/types"
+)
+
+func TestPayoff(t *testing.T) {
+	small := &types.PayoffDebt{ID: uuid.New(), Description: "small", Balance: 500, Rate: 10, MinimumPayment: 50}
+	expensive := &types.PayoffDebt{ID: uuid.New(), Description: "expensive", Balance: 1000, Rate: 30, MinimumPayment: 50}
+
+	tests := []struct {
+		name          string
+		strategy      types.PayoffStrategy
+		firstExtra    *types.PayoffDebt
+		payoffDates   map[*types.PayoffDebt]time.Time
+		totalInterest float64
+	}{
+		{
+			name:       "snowball pays the smallest balance first",
+			strategy:   types.PayoffSnowball,
+			firstExtra: small,
+			payoffDates: map[*types.PayoffDebt]time.Time{
+				small:     date(2024, time.March, 31),
+				expensive: date(2024, time.June, 30),
+			},
+			totalInterest: 95.93,
+		},
+		{
+			name:       "avalanche pays the highest rate first",
+			strategy:   types.PayoffAvalanche,
+			firstExtra: expensive,
+			payoffDates: map[*types.PayoffDebt]time.Time{
+				small:     date(2024, time.June, 30),
+				expensive: date(2024, time.May, 31),
+			},
+			totalInterest: 75.87,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan, err := Payoff([]*types.PayoffDebt{small, expensive}, 200, date(2024, time.January, 31), tt.strategy)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			first := plan.Months[0].Payments[0]
+			if first.DebtID != tt.firstExtra.ID || first.Extra != 200 {
+				t.Errorf("first month extra went to %v (%v), want %s", first.DebtID, first.Extra, tt.firstExtra.Description)
+			}
+
+			for _, result := range plan.Debts {
+				want := tt.payoffDates[result.PayoffDebt]
+				if !result.PayoffDate.Equal(want) {
+					t.Errorf("%s paid off on %s, want %s", result.Description, result.PayoffDate.Format("2006-01-02"), want.Format("2006-01-02"))
+				}
+			}
+
+			if plan.TotalInterest != tt.totalInterest {
+				t.Errorf("total interest %v, want %v", plan.TotalInterest, tt.totalInterest)
+			}
+		})
+	}
+}
+
+func TestPayoffAvalancheTiesOnBalance(t *testing.T) {
+	larger := &types.PayoffDebt{ID: uuid.New(), Balance: 900, Rate: 20, MinimumPayment: 30}
+	smaller := &types.PayoffDebt{ID: uuid.New(), Balance: 300, Rate: 20, MinimumPayment: 30}
+
+	plan, err := Payoff([]*types.PayoffDebt{larger, smaller}, 100, date(2024, time.January, 1), types.PayoffAvalanche)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := plan.Months[0].Payments[0].DebtID; got != smaller.ID {
+		t.Errorf("the extra went to %v, want the smaller balance %v", got, smaller.ID)
+	}
+}
+
+func TestPayoffNeverPaidOff(t *testing.T) {
+	debt := &types.PayoffDebt{ID: uuid.New(), Balance: 10000, Rate: 100, MinimumPayment: 10}
+
+	_, err := Payoff([]*types.PayoffDebt{debt}, 0, date(2024, time.January, 1), types.PayoffSnowball)
+	if !errors.Is(err, ErrNoPayoff) {
+		t.Errorf("got %v, want %v", err, ErrNoPayoff)
+	}
+}
@@ -0,0 +1,238 @@
+package forecast
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mdsavian/budget-tracker-api/internal/finance"
+	"github.com/mdsavian/budget-tracker-api/internal/types"
+)
+
+type EntryKind string
+
+const (
+	// an unfulfilled transaction
+	EntryKindTransaction EntryKind = "transaction"
+	// an occurrence of a recurring transaction not created yet
+	EntryKindRecurring EntryKind = "recurring"
+	// the card transactions due on the same day, paid from the account as a single statement
+	EntryKindCreditCardStatement EntryKind = "creditCardStatement"
+	// the monthly yield of an account whose kind yields, earned on the first day of the month
+	EntryKindYield EntryKind = "yield"
+)
+
+// Item is a future movement of an account
+type Item struct {
+	AccountID              uuid.UUID
+	TransactionID          *uuid.UUID
+	RecurringTransactionID *uuid.UUID
+	CreditCardID           *uuid.UUID
+	CreditCard             string
+	TransactionType        types.TransactionType
+	Date                   time.Time
+	Description            string
+	Amount                 float64
+}
+
+// FromTransactionView converts a transaction, or a recurring occurrence when it has no id
+func FromTransactionView(transaction *types.TransactionView) *Item {
+	item := &Item{
+		AccountID:              transaction.AccountID,
+		RecurringTransactionID: transaction.RecurringTransactionID,
+		CreditCardID:           transaction.CreditCardID,
+		TransactionType:        transaction.TransactionType,
+		Date:                   transaction.Date,
+		Description:            transaction.Description,
+		Amount:                 transaction.Amount,
+	}
+
+	if transaction.ID != uuid.Nil {
+		id := transaction.ID
+		item.TransactionID = &id
+	}
+	if transaction.CreditCard != nil {
+		item.CreditCard = *transaction.CreditCard
+	}
+
+	return item
+}
+
+type Entry struct {
+	Kind                   EntryKind             `json:"kind"`
+	TransactionID          *uuid.UUID            `json:"transactionId,omitempty"`
+	RecurringTransactionID *uuid.UUID            `json:"recurringTransactionId,omitempty"`
+	CreditCardID           *uuid.UUID            `json:"creditCardId,omitempty"`
+	TransactionType        types.TransactionType `json:"transactionType"`
+	Description            string                `json:"description"`
+	// Amount is signed, debits are negative
+	Amount float64 `json:"amount"`
+	// Overdue entries were due before the forecast start and are applied on its first day
+	Overdue bool `json:"overdue,omitempty"`
+}
+
+type Day struct {
+	Date     time.Time `json:"date"`
+	Balance  float64   `json:"balance"`
+	Negative bool      `json:"negative"`
+	Entries  []*Entry  `json:"entries,omitempty"`
+}
+
+type AccountForecast struct {
+	AccountID         uuid.UUID  `json:"accountId"`
+	Account           string     `json:"account"`
+	StartBalance      float64    `json:"startBalance"`
+	EndBalance        float64    `json:"endBalance"`
+	LowestBalance     float64    `json:"lowestBalance"`
+	LowestBalanceDate time.Time  `json:"lowestBalanceDate"`
+	FirstNegativeDate *time.Time `json:"firstNegativeDate"`
+	NegativeDays      int        `json:"negativeDays"`
+	Days              []*Day     `json:"days"`
+}
+
+type Forecast struct {
+	Start    time.Time          `json:"start"`
+	Until    time.Time          `json:"until"`
+	Accounts []*AccountForecast `json:"accounts"`
+}
+
+// Build projects the daily balance of each account from start to until, starting from the
+// current account balance. Items dated before start are overdue and applied on the first day,
+// card items are grouped into one statement per card and due date. Accounts that yield earn
+// their monthly rate over the positive balance on the first day of each month.
+func Build(accounts []*types.Account, items []*Item, start, until time.Time) (*Forecast, error) {
+	start = truncateDay(start)
+	until = truncateDay(until)
+	if until.Before(start) {
+		return nil, fmt.Errorf("until must not be before %s", start.Format("2006-01-02"))
+	}
+
+	entries := map[uuid.UUID]map[time.Time][]*Entry{}
+	statements := map[string]*Entry{}
+	for _, item := range items {
+		date := truncateDay(item.Date)
+		if date.After(until) {
+			continue
+		}
+
+		overdue := date.Before(start)
+		if overdue {
+			date = start
+		}
+
+		amount := item.Amount
+		if item.TransactionType != types.TransactionTypeCredit {
+			amount = -amount
+		}
+
+		if entries[item.AccountID] == nil {
+			entries[item.AccountID] = map[time.Time][]*Entry{}
+		}
+
+		if item.CreditCardID != nil {
+			key := fmt.Sprintf("%s|%s|%s", item.AccountID, item.CreditCardID, date.Format("2006-01-02"))
+			statement, ok := statements[key]
+			if !ok {
+				statement = &Entry{
+					Kind:         EntryKindCreditCardStatement,
+					CreditCardID: item.CreditCardID,
+					Description:  item.CreditCard,
+				}
+				statements[key] = statement
+				entries[item.AccountID][date] = append(entries[item.AccountID][date], statement)
+			}
+			statement.Amount += amount
+			statement.Overdue = statement.Overdue || overdue
+			continue
+		}
+
+		kind := EntryKindTransaction
+		if item.TransactionID == nil {
+			kind = EntryKindRecurring
+		}
+
+		entries[item.AccountID][date] = append(entries[item.AccountID][date], &Entry{
+			Kind:                   kind,
+			TransactionID:          item.TransactionID,
+			RecurringTransactionID: item.RecurringTransactionID,
+			TransactionType:        item.TransactionType,
+			Description:            item.Description,
+			Amount:                 amount,
+			Overdue:                overdue,
+		})
+	}
+
+	// statements were summed with signs, present them as a debit or credit of the total
+	for _, statement := range statements {
+		statement.TransactionType = types.TransactionTypeDebit
+		if statement.Amount > 0 {
+			statement.TransactionType = types.TransactionTypeCredit
+		}
+	}
+
+	forecast := &Forecast{Start: start, Until: until, Accounts: []*AccountForecast{}}
+	for _, account := range accounts {
+		balance := float64(account.Balance)
+		accountForecast := &AccountForecast{
+			AccountID:         account.ID,
+			Account:           account.Name,
+			StartBalance:      finance.RoundMoney(balance),
+			LowestBalance:     math.Inf(1),
+			LowestBalanceDate: start,
+			Days:              []*Day{},
+		}
+
+		monthlyYield := monthlyYieldRate(account)
+		for date := start; !date.After(until); date = date.AddDate(0, 0, 1) {
+			dayEntries := entries[account.ID][date]
+			if monthlyYield > 0 && date.Day() == 1 && balance > 0 {
+				dayEntries = append(dayEntries, &Entry{
+					Kind:            EntryKindYield,
+					TransactionType: types.TransactionTypeCredit,
+					Description:     "Yield",
+					Amount:          balance * monthlyYield,
+				})
+			}
+			for _, entry := range dayEntries {
+				balance += entry.Amount
+				entry.Amount = finance.RoundMoney(entry.Amount)
+			}
+			sort.SliceStable(dayEntries, func(i, j int) bool { return dayEntries[i].Amount > dayEntries[j].Amount })
+
+			day := &Day{Date: date, Balance: finance.RoundMoney(balance), Entries: dayEntries}
+			day.Negative = day.Balance < 0
+			accountForecast.Days = append(accountForecast.Days, day)
+
+			if day.Balance < accountForecast.LowestBalance {
+				accountForecast.LowestBalance = day.Balance
+				accountForecast.LowestBalanceDate = date
+			}
+			if day.Negative {
+				accountForecast.NegativeDays++
+				if accountForecast.FirstNegativeDate == nil {
+					negativeDate := date
+					accountForecast.FirstNegativeDate = &negativeDate
+				}
+			}
+		}
+
+		accountForecast.EndBalance = finance.RoundMoney(balance)
+		forecast.Accounts = append(forecast.Accounts, accountForecast)
+	}
+
+	return forecast, nil
+}
+
+// monthlyYieldRate converts the annual yield rate of the account to a compounded monthly rate
+func monthlyYieldRate(account *types.Account) float64 {
+	if !account.Kind.Yields() || account.YieldRate <= 0 {
+		return 0
+	}
+	return finance.MonthlyRate(float64(account.YieldRate))
+}
+
+func truncateDay(date time.Time) time.Time {
+	return time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,192 @@
+package forecast
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mdsavian/budget-tracker-api/internal/finance"
+	"github.com/mdsavian/budget-tracker-api/internal/types"
+)
+
+func ValidateScenario(scenario *types.Scenario) error {
+	if scenario.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+
+	for i, item := range scenario.Items {
+		if err := validateScenarioItem(item); err != nil {
+			return fmt.Errorf("item %d: %w", i+1, err)
+		}
+	}
+
+	return nil
+}
+
+func validateScenarioItem(item *types.ScenarioItem) error {
+	switch item.Kind {
+	case types.ScenarioItemTransaction, types.ScenarioItemRecurring:
+		if item.AccountID == nil || *item.AccountID == uuid.Nil {
+			return fmt.Errorf("accountId is required")
+		}
+		if item.TransactionType != types.TransactionTypeCredit && item.TransactionType != types.TransactionTypeDebit {
+			return fmt.Errorf("transactionType must be Credit or Debit")
+		}
+		if item.Amount <= 0 {
+			return fmt.Errorf("amount must be positive")
+		}
+		if item.Date == nil {
+			return fmt.Errorf("date is required")
+		}
+		if item.Kind == types.ScenarioItemRecurring {
+			if item.Day < 1 || item.Day > 31 {
+				return fmt.Errorf("day must be between 1 and 31")
+			}
+			if item.EndDate != nil && item.EndDate.Before(*item.Date) {
+				return fmt.Errorf("endDate must not be before date")
+			}
+		}
+	case types.ScenarioItemCancelTransaction:
+		if item.TransactionID == nil {
+			return fmt.Errorf("transactionId is required")
+		}
+	case types.ScenarioItemCancelRecurring:
+		if item.RecurringTransactionID == nil {
+			return fmt.Errorf("recurringTransactionId is required")
+		}
+	default:
+		return fmt.Errorf("kind %q is not supported", item.Kind)
+	}
+
+	return nil
+}
+
+// ApplyScenario returns the items with the scenario cancellations removed and its hypothetical
+// transactions and recurring occurrences up to until added. Card purchases are moved to the
+// statement due date of the card.
+func ApplyScenario(items []*Item, scenario *types.Scenario, cards map[uuid.UUID]*types.CreditCard, until time.Time) ([]*Item, error) {
+	cancelledTransactions := map[uuid.UUID]bool{}
+	cancelledRecurring := map[uuid.UUID]time.Time{}
+	for _, scenarioItem := range scenario.Items {
+		switch scenarioItem.Kind {
+		case types.ScenarioItemCancelTransaction:
+			cancelledTransactions[*scenarioItem.TransactionID] = true
+		case types.ScenarioItemCancelRecurring:
+			from := time.Time{}
+			if scenarioItem.Date != nil {
+				from = truncateDay(*scenarioItem.Date)
+			}
+			cancelledRecurring[*scenarioItem.RecurringTransactionID] = from
+		}
+	}
+
+	result := []*Item{}
+	for _, item := range items {
+		if item.TransactionID != nil && cancelledTransactions[*item.TransactionID] {
+			continue
+		}
+		if item.RecurringTransactionID != nil {
+			if from, ok := cancelledRecurring[*item.RecurringTransactionID]; ok && !item.Date.Before(from) {
+				continue
+			}
+		}
+		result = append(result, item)
+	}
+
+	until = truncateDay(until)
+	for _, scenarioItem := range scenario.Items {
+		var dates []time.Time
+		switch scenarioItem.Kind {
+		case types.ScenarioItemTransaction:
+			dates = []time.Time{truncateDay(*scenarioItem.Date)}
+		case types.ScenarioItemRecurring:
+			dates = monthlyDates(scenarioItem, until)
+		default:
+			continue
+		}
+
+		var card *types.CreditCard
+		if scenarioItem.CreditCardID != nil {
+			var ok bool
+			card, ok = cards[*scenarioItem.CreditCardID]
+			if !ok {
+				return nil, fmt.Errorf("credit card %v not found", *scenarioItem.CreditCardID)
+			}
+		}
+
+		for _, date := range dates {
+			item := &Item{
+				AccountID:       *scenarioItem.AccountID,
+				TransactionType: scenarioItem.TransactionType,
+				Date:            date,
+				Description:     scenarioItem.Description,
+				Amount:          float64(scenarioItem.Amount),
+			}
+			if card != nil {
+				item.CreditCardID = &card.ID
+				item.CreditCard = card.Name
+				item.Date = card.DueDateFor(date)
+			}
+			result = append(result, item)
+		}
+	}
+
+	return result, nil
+}
+
+// monthlyDates lists the occurrences of a recurring item from its date up to until or its end date.
+// Days past the end of a month fall on its last day.
+func monthlyDates(item *types.ScenarioItem, until time.Time) []time.Time {
+	first := truncateDay(*item.Date)
+	if item.EndDate != nil && truncateDay(*item.EndDate).Before(until) {
+		until = truncateDay(*item.EndDate)
+	}
+
+	dates := []time.Time{}
+	for month := time.Date(first.Year(), first.Month(), 1, 0, 0, 0, 0, time.UTC); !month.After(until); month = month.AddDate(0, 1, 0) {
+		day := item.Day
+		if lastDay := month.AddDate(0, 1, -1).Day(); day > lastDay {
+			day = lastDay
+		}
+
+		date := month.AddDate(0, 0, day-1)
+		if date.Before(first) || date.After(until) {
+			continue
+		}
+		dates = append(dates, date)
+	}
+	return dates
+}
+
+type AccountComparison struct {
+	AccountID                 uuid.UUID  `json:"accountId"`
+	Account                   string     `json:"account"`
+	BaselineEndBalance        float64    `json:"baselineEndBalance"`
+	ScenarioEndBalance        float64    `json:"scenarioEndBalance"`
+	EndBalanceDelta           float64    `json:"endBalanceDelta"`
+	BaselineLowestBalance     float64    `json:"baselineLowestBalance"`
+	ScenarioLowestBalance     float64    `json:"scenarioLowestBalance"`
+	BaselineFirstNegativeDate *time.Time `json:"baselineFirstNegativeDate"`
+	ScenarioFirstNegativeDate *time.Time `json:"scenarioFirstNegativeDate"`
+}
+
+// Compare summarizes, per account, how the scenario forecast differs from the baseline.
+// Both forecasts must have been built from the same accounts.
+func Compare(baseline, scenario *Forecast) []*AccountComparison {
+	comparisons := make([]*AccountComparison, 0, len(baseline.Accounts))
+	for i, base := range baseline.Accounts {
+		simulated := scenario.Accounts[i]
+		comparisons = append(comparisons, &AccountComparison{
+			AccountID:                 base.AccountID,
+			Account:                   base.Account,
+			BaselineEndBalance:        base.EndBalance,
+			ScenarioEndBalance:        simulated.EndBalance,
+			EndBalanceDelta:           finance.RoundMoney(simulated.EndBalance - base.EndBalance),
+			BaselineLowestBalance:     base.LowestBalance,
+			ScenarioLowestBalance:     simulated.LowestBalance,
+			BaselineFirstNegativeDate: base.FirstNegativeDate,
+			ScenarioFirstNegativeDate: simulated.FirstNegativeDate,
+		})
+	}
+	return comparisons
+}
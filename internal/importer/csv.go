@@ -0,0 +1,241 @@
+package importer
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mdsavian/budget-tracker-api/internal/types"
+	"golang.org/x/text/encoding/charmap"
+)
+
+// CSVRow is a csv line parsed with a profile. Rows that could not be parsed
+// keep the reason in Error and are not imported.
+type CSVRow struct {
+	Row             int                   `json:"row"`
+	Date            time.Time             `json:"date"`
+	Description     string                `json:"description"`
+	Amount          float32               `json:"amount"`
+	TransactionType types.TransactionType `json:"transactionType"`
+	Category        string                `json:"category"`
+	CategoryID      uuid.UUID             `json:"categoryId"`
+	Error           string                `json:"error,omitempty"`
+}
+
+func ValidateCSVProfile(profile *types.CSVProfile) error {
+	if profile.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+
+	if len(profile.Delimiter) != 1 {
+		return fmt.Errorf("delimiter must be a single character")
+	}
+
+	switch profile.Encoding {
+	case types.CSVEncodingUTF8, types.CSVEncodingWindows1252, types.CSVEncodingISO88591:
+	default:
+		return fmt.Errorf("encoding %q is not supported", profile.Encoding)
+	}
+
+	switch profile.SignConvention {
+	case types.CSVSignNegativeDebit, types.CSVSignPositiveDebit:
+	default:
+		return fmt.Errorf("sign convention %q is not supported", profile.SignConvention)
+	}
+
+	if profile.DateFormat == "" {
+		return fmt.Errorf("dateFormat is required")
+	}
+
+	if profile.DateColumn < 0 || profile.DescriptionColumn < 0 || profile.AmountColumn < 0 ||
+		(profile.CategoryColumn != nil && *profile.CategoryColumn < 0) {
+		return fmt.Errorf("columns must be zero based indexes")
+	}
+
+	if profile.AccountID == uuid.Nil || profile.CategoryID == uuid.Nil {
+		return fmt.Errorf("accountId and categoryId are required")
+	}
+
+	return nil
+}
+
+// ParseCSV reads the file with the profile mapping, resolving the category column
+// against the existing categories and falling back to the profile category
+func ParseCSV(r io.Reader, profile *types.CSVProfile, categories []*types.Category) ([]*CSVRow, error) {
+	switch profile.Encoding {
+	case types.CSVEncodingWindows1252:
+		r = charmap.Windows1252.NewDecoder().Reader(r)
+	case types.CSVEncodingISO88591:
+		r = charmap.ISO8859_1.NewDecoder().Reader(r)
+	}
+
+	reader := csv.NewReader(r)
+	reader.Comma = rune(profile.Delimiter[0])
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	categoryIDs := map[string]uuid.UUID{}
+	for _, category := range categories {
+		categoryIDs[strings.ToLower(category.Description)] = category.ID
+	}
+
+	var rows []*CSVRow
+	for index := 1; ; index++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if index <= profile.SkipRows || isBlankRecord(record) {
+			continue
+		}
+
+		row := parseCSVRecord(index, record, profile)
+		if row.Error == "" {
+			row.CategoryID = profile.CategoryID
+			if id, ok := categoryIDs[strings.ToLower(row.Category)]; ok {
+				row.CategoryID = id
+			}
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+func parseCSVRecord(index int, record []string, profile *types.CSVProfile) *CSVRow {
+	row := &CSVRow{Row: index}
+
+	column := func(i int) (string, error) {
+		if i >= len(record) {
+			return "", fmt.Errorf("column %d not found", i)
+		}
+		return strings.TrimSpace(record[i]), nil
+	}
+
+	dateValue, err := column(profile.DateColumn)
+	if err != nil {
+		row.Error = err.Error()
+		return row
+	}
+	row.Date, err = time.Parse(profile.DateFormat, dateValue)
+	if err != nil {
+		row.Error = fmt.Sprintf("invalid date %q", dateValue)
+		return row
+	}
+
+	row.Description, err = column(profile.DescriptionColumn)
+	if err != nil {
+		row.Error = err.Error()
+		return row
+	}
+
+	amountValue, err := column(profile.AmountColumn)
+	if err != nil {
+		row.Error = err.Error()
+		return row
+	}
+	amount, err := parseAmount(amountValue, profile.DecimalComma)
+	if err != nil {
+		row.Error = err.Error()
+		return row
+	}
+	if amount == 0 {
+		row.Error = "amount is zero"
+		return row
+	}
+
+	isDebit := amount < 0
+	if profile.SignConvention == types.CSVSignPositiveDebit {
+		isDebit = amount > 0
+	}
+	row.TransactionType = types.TransactionTypeCredit
+	if isDebit {
+		row.TransactionType = types.TransactionTypeDebit
+	}
+	if amount < 0 {
+		amount = -amount
+	}
+	row.Amount = float32(amount)
+
+	if profile.CategoryColumn != nil {
+		row.Category, _ = column(*profile.CategoryColumn)
+	}
+
+	return row
+}
+
+func parseAmount(value string, decimalComma bool) (float64, error) {
+	cleaned := strings.NewReplacer("R$", "", " ", "", "\u00a0", "").Replace(value)
+	if decimalComma {
+		cleaned = strings.ReplaceAll(cleaned, ".", "")
+		cleaned = strings.ReplaceAll(cleaned, ",", ".")
+	} else {
+		cleaned = strings.ReplaceAll(cleaned, ",", "")
+	}
+
+	amount, err := strconv.ParseFloat(cleaned, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount %q", value)
+	}
+	return amount, nil
+}
+
+func isBlankRecord(record []string) bool {
+	for _, value := range record {
+		if strings.TrimSpace(value) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// ImportCSV persists the parsed rows through the store. Rows going to a credit
+// card are dated on the statement due date and left unfulfilled, as card debits
+// created by the API; account rows are fulfilled and update the account balance.
+func ImportCSV(store Storage, source string, rows []*CSVRow, profile *types.CSVProfile, creditCard *types.CreditCard, report *Report) error {
+	var entries []*Entry
+	for _, row := range rows {
+		if row.Error != "" {
+			report.Errors = append(report.Errors, &types.ImportRowError{File: source, Row: row.Row, Error: row.Error})
+			continue
+		}
+
+		date := row.Date
+		transaction := &types.Transaction{
+			ID:              uuid.Must(uuid.NewV7()),
+			AccountID:       profile.AccountID,
+			CategoryID:      row.CategoryID,
+			TransactionType: row.TransactionType,
+			Date:            date,
+			Description:     row.Description,
+			Amount:          row.Amount,
+			CreatedAt:       time.Now().UTC(),
+			UpdatedAt:       time.Now().UTC(),
+		}
+
+		if creditCard != nil {
+			transaction.CreditCardID = &creditCard.ID
+			transaction.Date = creditCard.DueDateFor(date)
+		} else {
+			transaction.Fulfilled = true
+			transaction.EffectuatedDate = &date
+		}
+
+		entries = append(entries, &Entry{
+			Source:      source,
+			Row:         row.Row,
+			Fingerprint: contentFingerprint("csv", source, row.Row, transaction),
+			Transaction: transaction,
+		})
+	}
+
+	return Persist(store, entries, report)
+}
@@ -0,0 +1,213 @@
+package importer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mdsavian/budget-tracker-api/internal/types"
+)
+
+type Storage interface {
+	CreateTransaction(*types.Transaction) error
+	UpdateAccountBalance(uuid.UUID, float32, types.TransactionType, time.Time) error
+	ExistsTransactionByExternalID(accountID uuid.UUID, externalID string) (bool, error)
+	FindMatchingTransaction(*types.Transaction) (*types.Transaction, error)
+	ExistsImportFingerprint(string) (bool, error)
+	CreateImportFingerprint(*types.ImportFingerprint) error
+}
+
+type Action string
+
+const (
+	// the row is new and a transaction is created for it
+	ActionCreate Action = "create"
+	// the row was not imported before but an equal transaction already exists, it is linked instead of created
+	ActionMatch Action = "match"
+	// the row was already imported
+	ActionSkip Action = "skip"
+)
+
+// Entry is a parsed row ready to be persisted
+type Entry struct {
+	Source      string
+	Row         int
+	Fingerprint string
+	Transaction *types.Transaction
+}
+
+type RowOutcome struct {
+	Source          string                `json:"source"`
+	Row             int                   `json:"row"`
+	Action          Action                `json:"action"`
+	TransactionID   uuid.UUID             `json:"transactionId"`
+	TransactionType types.TransactionType `json:"transactionType"`
+	Date            time.Time             `json:"date"`
+	Description     string                `json:"description"`
+	Amount          float32               `json:"amount"`
+}
+
+type Report struct {
+	DryRun  bool                    `json:"dryRun"`
+	Total   int                     `json:"total"`
+	Created []*types.Transaction    `json:"created"`
+	Matched int                     `json:"matched"`
+	Skipped int                     `json:"skipped"`
+	Ignored int                     `json:"ignored"`
+	Rows    []*RowOutcome           `json:"rows"`
+	Errors  []*types.ImportRowError `json:"errors"`
+
+	// OnProgress is called after each persisted entry with the number of entries processed
+	OnProgress func(processed int) `json:"-"`
+}
+
+func NewReport(dryRun bool) *Report {
+	return &Report{DryRun: dryRun, Created: []*types.Transaction{}, Rows: []*RowOutcome{}, Errors: []*types.ImportRowError{}}
+}
+
+// Fingerprint identifies an imported row across imports
+func Fingerprint(parts ...string) string {
+	hash := sha256.Sum256([]byte(strings.Join(parts, "|")))
+	return hex.EncodeToString(hash[:])
+}
+
+// contentFingerprint identifies a row by its source file, position and content
+func contentFingerprint(kind, source string, row int, transaction *types.Transaction) string {
+	creditCard := ""
+	if transaction.CreditCardID != nil {
+		creditCard = transaction.CreditCardID.String()
+	}
+
+	return Fingerprint(kind, source, fmt.Sprint(row),
+		transaction.AccountID.String(),
+		creditCard,
+		transaction.TransactionType.String(),
+		transaction.Date.Format("2006-01-02"),
+		transaction.Description,
+		fmt.Sprintf("%.2f", transaction.Amount))
+}
+
+// Persist creates the transactions of the entries not imported before, recording
+// their fingerprints. On a dry run report nothing is written and the report tells what would happen.
+func Persist(store Storage, entries []*Entry, report *Report) error {
+	report.Total += len(entries)
+
+	for i, entry := range entries {
+		if report.OnProgress != nil && i > 0 {
+			report.OnProgress(i)
+		}
+
+		transaction := entry.Transaction
+		outcome := &RowOutcome{
+			Source:          entry.Source,
+			Row:             entry.Row,
+			TransactionID:   transaction.ID,
+			TransactionType: transaction.TransactionType,
+			Date:            transaction.Date,
+			Description:     transaction.Description,
+			Amount:          transaction.Amount,
+		}
+		report.Rows = append(report.Rows, outcome)
+
+		alreadyImported, err := store.ExistsImportFingerprint(entry.Fingerprint)
+		if err != nil {
+			return err
+		}
+		if !alreadyImported && transaction.ExternalID != nil {
+			alreadyImported, err = store.ExistsTransactionByExternalID(transaction.AccountID, *transaction.ExternalID)
+			if err != nil {
+				return err
+			}
+		}
+
+		if alreadyImported {
+			outcome.Action = ActionSkip
+			report.Skipped++
+			continue
+		}
+
+		match, err := store.FindMatchingTransaction(transaction)
+		if err != nil {
+			return err
+		}
+
+		if match != nil {
+			outcome.Action = ActionMatch
+			outcome.TransactionID = match.ID
+			report.Matched++
+		} else {
+			outcome.Action = ActionCreate
+		}
+
+		if report.DryRun {
+			if match == nil {
+				report.Created = append(report.Created, transaction)
+			}
+			continue
+		}
+
+		if match == nil {
+			if err := store.CreateTransaction(transaction); err != nil {
+				return err
+			}
+			report.Created = append(report.Created, transaction)
+
+			if transaction.Fulfilled {
+				if err := store.UpdateAccountBalance(transaction.AccountID, transaction.Amount, transaction.TransactionType, transaction.EffectiveDate()); err != nil {
+					return err
+				}
+			}
+		}
+
+		err = store.CreateImportFingerprint(&types.ImportFingerprint{
+			Fingerprint:   entry.Fingerprint,
+			Source:        entry.Source,
+			Row:           entry.Row,
+			TransactionID: outcome.TransactionID,
+			CreatedAt:     time.Now().UTC(),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if report.OnProgress != nil {
+		report.OnProgress(len(entries))
+	}
+
+	return nil
+}
+
+// ImportStatement persists the statement lines as fulfilled transactions of the account.
+// Lines are identified by their FITID, so a statement can be imported more than once.
+func ImportStatement(store Storage, source string, lines []*StatementLine, accountID, categoryID uuid.UUID, report *Report) error {
+	entries := make([]*Entry, 0, len(lines))
+	for i, line := range lines {
+		date := line.Date
+		externalID := line.ExternalID
+		entries = append(entries, &Entry{
+			Source:      source,
+			Row:         i + 1,
+			Fingerprint: Fingerprint("ofx", accountID.String(), line.ExternalID),
+			Transaction: &types.Transaction{
+				ID:              uuid.Must(uuid.NewV7()),
+				AccountID:       accountID,
+				CategoryID:      categoryID,
+				TransactionType: line.TransactionType,
+				Date:            date,
+				EffectuatedDate: &date,
+				Description:     line.Description,
+				Amount:          line.Amount,
+				Fulfilled:       true,
+				ExternalID:      &externalID,
+				CreatedAt:       time.Now().UTC(),
+				UpdatedAt:       time.Now().UTC(),
+			},
+		})
+	}
+
+	return Persist(store, entries, report)
+}
@@ -0,0 +1,138 @@
+package importer
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/mdsavian/budget-tracker-api/internal/types"
+	"golang.org/x/text/encoding/charmap"
+)
+
+// StatementLine is a single movement read from a bank statement
+type StatementLine struct {
+	ExternalID      string
+	TransactionType types.TransactionType
+	Date            time.Time
+	Description     string
+	Amount          float32
+}
+
+// ParseOFX reads the STMTTRN entries of an OFX file. Both the SGML based 1.x
+// format (leaf tags without closing tags) and the XML based 2.x format are accepted.
+func ParseOFX(r io.Reader) ([]*StatementLine, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// 1.x files exported by brazilian banks are usually windows-1252 encoded
+	if !utf8.Valid(data) {
+		data, err = charmap.Windows1252.NewDecoder().Bytes(data)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	start := bytes.Index(bytes.ToUpper(data), []byte("<OFX>"))
+	if start < 0 {
+		return nil, fmt.Errorf("invalid ofx file: <OFX> tag not found")
+	}
+
+	var lines []*StatementLine
+	var current map[string]string
+
+	content := string(data[start:])
+	for len(content) > 0 {
+		open := strings.IndexByte(content, '<')
+		if open < 0 {
+			break
+		}
+		end := strings.IndexByte(content[open:], '>')
+		if end < 0 {
+			break
+		}
+
+		tag := strings.ToUpper(strings.TrimSpace(content[open+1 : open+end]))
+		content = content[open+end+1:]
+
+		value := content
+		if next := strings.IndexByte(content, '<'); next >= 0 {
+			value = content[:next]
+		}
+		value = strings.TrimSpace(value)
+
+		switch {
+		case tag == "STMTTRN":
+			current = map[string]string{}
+		case tag == "/STMTTRN":
+			if current == nil {
+				continue
+			}
+			line, err := newStatementLine(current)
+			if err != nil {
+				return nil, err
+			}
+			lines = append(lines, line)
+			current = nil
+		case current != nil && !strings.HasPrefix(tag, "/") && value != "":
+			current[tag] = value
+		}
+	}
+
+	return lines, nil
+}
+
+func newStatementLine(fields map[string]string) (*StatementLine, error) {
+	fitID := fields["FITID"]
+	if fitID == "" {
+		return nil, fmt.Errorf("statement transaction without FITID")
+	}
+
+	date, err := parseOFXDate(fields["DTPOSTED"])
+	if err != nil {
+		return nil, fmt.Errorf("transaction %s: %w", fitID, err)
+	}
+
+	amount, err := strconv.ParseFloat(strings.ReplaceAll(fields["TRNAMT"], ",", "."), 32)
+	if err != nil {
+		return nil, fmt.Errorf("transaction %s: invalid amount %q", fitID, fields["TRNAMT"])
+	}
+
+	transactionType := types.TransactionTypeCredit
+	if amount < 0 {
+		transactionType = types.TransactionTypeDebit
+		amount = -amount
+	}
+
+	description := fields["MEMO"]
+	if description == "" {
+		description = fields["NAME"]
+	}
+
+	return &StatementLine{
+		ExternalID:      fitID,
+		TransactionType: transactionType,
+		Date:            date,
+		Description:     description,
+		Amount:          float32(amount),
+	}, nil
+}
+
+// parseOFXDate handles the OFX datetime format, e.g. 20240115120000[-3:BRT], keeping only the date
+func parseOFXDate(value string) (time.Time, error) {
+	if len(value) < 8 {
+		return time.Time{}, fmt.Errorf("invalid date %q", value)
+	}
+
+	date, err := time.Parse("20060102", value[:8])
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q", value)
+	}
+
+	return date, nil
+}
@@ -0,0 +1,125 @@
+package importer
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mdsavian/budget-tracker-api/internal/types"
+	"golang.org/x/text/encoding/charmap"
+)
+
+const ofxSGML = `OFXHEADER:100
+DATA:OFXSGML
+VERSION:102
+ENCODING:USASCII
+
+<OFX>
+<BANKMSGSRSV1><STMTTRNRS><STMTRS><BANKTRANLIST>
+<STMTTRN>
+<TRNTYPE>DEBIT
+<DTPOSTED>20240115120000[-3:BRT]
+<TRNAMT>-45.90
+<FITID>1001
+<MEMO>Padaria
+</STMTTRN>
+<STMTTRN>
+<TRNTYPE>CREDIT
+<DTPOSTED>20240120
+<TRNAMT>1500.00
+<FITID>1002
+<NAME>Salary
+</STMTTRN>
+</BANKTRANLIST></STMTRS></STMTTRNRS></BANKMSGSRSV1>
+</OFX>`
+
+const ofxXML = `<?xml version="1.0" encoding="UTF-8"?>
+<?OFX OFXHEADER="200" VERSION="211"?>
+<OFX>
+  <BANKMSGSRSV1><STMTTRNRS><STMTRS><BANKTRANLIST>
+    <STMTTRN>
+      <TRNTYPE>DEBIT</TRNTYPE>
+      <DTPOSTED>20240201</DTPOSTED>
+      <TRNAMT>-12.34</TRNAMT>
+      <FITID>2001</FITID>
+      <NAME>Market</NAME>
+      <MEMO>Groceries</MEMO>
+    </STMTTRN>
+  </BANKTRANLIST></STMTRS></STMTTRNRS></BANKMSGSRSV1>
+</OFX>`
+
+func windows1252(t *testing.T, value string) string {
+	encoded, err := charmap.Windows1252.NewEncoder().String(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return encoded
+}
+
+func TestParseOFX(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []*StatementLine
+	}{
+		{
+			name:  "1.x sgml without closing tags",
+			input: ofxSGML,
+			want: []*StatementLine{
+				{ExternalID: "1001", TransactionType: types.TransactionTypeDebit, Date: time.Date(2024, time.January, 15, 0, 0, 0, 0, time.UTC), Description: "Padaria", Amount: 45.90},
+				{ExternalID: "1002", TransactionType: types.TransactionTypeCredit, Date: time.Date(2024, time.January, 20, 0, 0, 0, 0, time.UTC), Description: "Salary", Amount: 1500},
+			},
+		},
+		{
+			name:  "2.x xml prefers the memo",
+			input: ofxXML,
+			want: []*StatementLine{
+				{ExternalID: "2001", TransactionType: types.TransactionTypeDebit, Date: time.Date(2024, time.February, 1, 0, 0, 0, 0, time.UTC), Description: "Groceries", Amount: 12.34},
+			},
+		},
+		{
+			name:  "windows-1252 with comma decimals",
+			input: windows1252(t, "<OFX><STMTTRN><DTPOSTED>20240305<TRNAMT>-1234,56<FITID>3001<MEMO>Pão de açúcar</STMTTRN></OFX>"),
+			want: []*StatementLine{
+				{ExternalID: "3001", TransactionType: types.TransactionTypeDebit, Date: time.Date(2024, time.March, 5, 0, 0, 0, 0, time.UTC), Description: "Pão de açúcar", Amount: 1234.56},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseOFX(strings.NewReader(tt.input))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d lines, want %d", len(got), len(tt.want))
+			}
+			for i := range got {
+				if *got[i] != *tt.want[i] {
+					t.Errorf("line %d: got %+v, want %+v", i+1, *got[i], *tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestParseOFXErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"no ofx tag", "<STMTTRN><FITID>1</STMTTRN>"},
+		{"no fitid", "<OFX><STMTTRN><DTPOSTED>20240101<TRNAMT>1.00</STMTTRN></OFX>"},
+		{"invalid date", "<OFX><STMTTRN><DTPOSTED>2024<TRNAMT>1.00<FITID>1</STMTTRN></OFX>"},
+		{"invalid amount", "<OFX><STMTTRN><DTPOSTED>20240101<TRNAMT>abc<FITID>1</STMTTRN></OFX>"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseOFX(strings.NewReader(tt.input)); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
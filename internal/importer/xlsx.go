@@ -0,0 +1,390 @@
+package importer
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mdsavian/budget-tracker-api/internal/types"
+	"github.com/thedatashed/xlsxreader"
+)
+
+// XlsxColumns holds the column letter (A, B, C...) of each transaction field
+type XlsxColumns struct {
+	CreditCard      string `json:"creditCard"`
+	TransactionType string `json:"transactionType"`
+	Account         string `json:"account"`
+	Date            string `json:"date"`
+	Description     string `json:"description"`
+	Category        string `json:"category"`
+	Amount          string `json:"amount"`
+	Fulfilled       string `json:"fulfilled"`
+}
+
+type XlsxAccount struct {
+	Name        string            `json:"name"`
+	AccountType types.AccountType `json:"accountType"`
+}
+
+type XlsxCreditCard struct {
+	Name       string `json:"name"`
+	ClosingDay int    `json:"closingDay"`
+	DueDay     int    `json:"dueDay"`
+}
+
+type XlsxConfig struct {
+	// Sheet is the sheet name, when empty the sheet at SheetIndex is read
+	Sheet      string      `json:"sheet"`
+	SheetIndex int         `json:"sheetIndex"`
+	HeaderRows int         `json:"headerRows"`
+	Columns    XlsxColumns `json:"columns"`
+	// YesValue is the cell value meaning true on the credit card and fulfilled columns
+	YesValue      string `json:"yesValue"`
+	OnlyFulfilled bool   `json:"onlyFulfilled"`
+	// Accounts maps the value of the account column to the account receiving the transaction
+	Accounts   map[string]XlsxAccount `json:"accounts"`
+	CreditCard XlsxCreditCard         `json:"creditCard"`
+}
+
+// DefaultXlsxConfig is the layout of the original budget spreadsheet
+func DefaultXlsxConfig() *XlsxConfig {
+	return &XlsxConfig{
+		SheetIndex: 2,
+		HeaderRows: 1,
+		Columns: XlsxColumns{
+			CreditCard:      "A",
+			TransactionType: "B",
+			Account:         "C",
+			Date:            "D",
+			Description:     "E",
+			Category:        "F",
+			Amount:          "G",
+			Fulfilled:       "H",
+		},
+		YesValue:      "Sim",
+		OnlyFulfilled: true,
+		Accounts: map[string]XlsxAccount{
+			types.AccountTypePersonal.String(): {Name: "Bradesco", AccountType: types.AccountTypePersonal},
+			types.AccountTypeBusiness.String(): {Name: "Empresa", AccountType: types.AccountTypeBusiness},
+		},
+		CreditCard: XlsxCreditCard{Name: "Itaú", ClosingDay: 10, DueDay: 16},
+	}
+}
+
+// LoadXlsxConfig reads a json config file on top of the default config,
+// so the file only needs the values that differ from it
+func LoadXlsxConfig(path string) (*XlsxConfig, error) {
+	config := DefaultXlsxConfig()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+
+	return config, nil
+}
+
+type XlsxRow struct {
+	Row             int
+	CreditCard      bool
+	TransactionType types.TransactionType
+	Account         XlsxAccount
+	Date            time.Time
+	Description     string
+	Category        string
+	Amount          float32
+	Fulfilled       bool
+}
+
+// ParseXlsx reads the transactions of the configured sheet. Rows that cannot be
+// read are reported back instead of aborting the whole file.
+func ParseXlsx(fileName string, data []byte, config *XlsxConfig, report *Report) ([]*XlsxRow, error) {
+	xl, err := xlsxreader.NewReader(data)
+	if err != nil {
+		return nil, err
+	}
+
+	sheet := config.Sheet
+	if sheet == "" {
+		if config.SheetIndex < 0 || config.SheetIndex >= len(xl.Sheets) {
+			return nil, fmt.Errorf("sheet index %d not found, file has %d sheets", config.SheetIndex, len(xl.Sheets))
+		}
+		sheet = xl.Sheets[config.SheetIndex]
+	}
+
+	var rows []*XlsxRow
+	var lastValidDate time.Time
+
+	for row := range xl.ReadRows(sheet) {
+		if row.Error != nil {
+			report.Errors = append(report.Errors, &types.ImportRowError{File: fileName, Row: row.Index, Error: row.Error.Error()})
+			continue
+		}
+
+		if row.Index <= config.HeaderRows {
+			continue
+		}
+
+		cells := map[string]xlsxreader.Cell{}
+		for _, cell := range row.Cells {
+			cells[cell.Column] = cell
+		}
+
+		value := func(column string) string {
+			return strings.TrimSpace(cells[column].Value)
+		}
+
+		if len(cells) == 0 || value(config.Columns.Amount) == "" {
+			report.Ignored++
+			continue
+		}
+
+		// rows without date belong to the month of the previous dated row
+		date, err := time.Parse("2006-01-02", value(config.Columns.Date))
+		if err != nil {
+			if lastValidDate.IsZero() {
+				report.Errors = append(report.Errors, &types.ImportRowError{File: fileName, Row: row.Index, Error: fmt.Sprintf("invalid date %q", value(config.Columns.Date))})
+				continue
+			}
+			date = time.Date(lastValidDate.Year(), lastValidDate.Month(), 1, 0, 0, 0, 0, time.UTC)
+		}
+		lastValidDate = date
+
+		amount, err := strconv.ParseFloat(value(config.Columns.Amount), 32)
+		if err != nil || cells[config.Columns.Amount].Type != xlsxreader.TypeNumerical {
+			report.Errors = append(report.Errors, &types.ImportRowError{File: fileName, Row: row.Index, Error: fmt.Sprintf("invalid amount %q", value(config.Columns.Amount))})
+			continue
+		}
+
+		if amount <= 0 {
+			report.Ignored++
+			continue
+		}
+
+		fulfilled := value(config.Columns.Fulfilled) == config.YesValue
+		if config.OnlyFulfilled && !fulfilled {
+			report.Ignored++
+			continue
+		}
+
+		transactionType := types.TransactionType(value(config.Columns.TransactionType))
+		if transactionType != types.TransactionTypeCredit && transactionType != types.TransactionTypeDebit {
+			report.Errors = append(report.Errors, &types.ImportRowError{File: fileName, Row: row.Index, Error: fmt.Sprintf("invalid transaction type %q", transactionType)})
+			continue
+		}
+
+		accountValue := value(config.Columns.Account)
+		account, ok := config.Accounts[accountValue]
+		if !ok {
+			report.Errors = append(report.Errors, &types.ImportRowError{File: fileName, Row: row.Index, Error: fmt.Sprintf("account %q is not mapped", accountValue)})
+			continue
+		}
+
+		rows = append(rows, &XlsxRow{
+			Row:             row.Index,
+			CreditCard:      value(config.Columns.CreditCard) == config.YesValue,
+			TransactionType: transactionType,
+			Account:         account,
+			Date:            date,
+			Description:     value(config.Columns.Description),
+			Category:        value(config.Columns.Category),
+			Amount:          float32(amount),
+			Fulfilled:       fulfilled,
+		})
+	}
+
+	return rows, nil
+}
+
+type XlsxStorage interface {
+	Storage
+	GetAccounts() ([]*types.Account, error)
+	GetUniqueAccount(string, types.AccountType) (*types.Account, error)
+	CreateAccount(*types.Account) error
+	GetCategory() ([]*types.Category, error)
+	GetCategoryByDescription(string) (*types.Category, error)
+	CreateCategory(*types.Category) error
+	GetCreditCardByName(string) (*types.CreditCard, error)
+	CreateCreditCard(*types.CreditCard) error
+}
+
+// dryRunXlsxStorage reads from the store and discards the accounts, categories
+// and credit cards the import would create
+type dryRunXlsxStorage struct {
+	XlsxStorage
+}
+
+func (dryRunXlsxStorage) CreateAccount(*types.Account) error       { return nil }
+func (dryRunXlsxStorage) CreateCategory(*types.Category) error     { return nil }
+func (dryRunXlsxStorage) CreateCreditCard(*types.CreditCard) error { return nil }
+
+// ImportXlsx persists the rows, creating the accounts, categories and the credit card they reference
+func ImportXlsx(store XlsxStorage, fileName string, rows []*XlsxRow, config *XlsxConfig, report *Report) error {
+	if report.DryRun {
+		store = dryRunXlsxStorage{store}
+	}
+
+	accounts, err := store.GetAccounts()
+	if err != nil {
+		return err
+	}
+
+	categories, err := store.GetCategory()
+	if err != nil {
+		return err
+	}
+
+	var creditCard *types.CreditCard
+	var entries []*Entry
+
+	for _, row := range rows {
+		var account *types.Account
+		// search first on array avoiding calling the db for each transaction
+		for _, acc := range accounts {
+			if acc.Name == row.Account.Name && acc.AccountType == row.Account.AccountType {
+				account = acc
+				break
+			}
+		}
+		if account == nil {
+			account, err = getOrCreateAccount(row.Account.Name, row.Account.AccountType, store)
+			if err != nil {
+				report.Errors = append(report.Errors, &types.ImportRowError{File: fileName, Row: row.Row, Error: err.Error()})
+				continue
+			}
+			accounts = append(accounts, account)
+		}
+
+		var category *types.Category
+		for _, ctg := range categories {
+			if ctg.Description == strings.ToLower(row.Category) {
+				category = ctg
+				break
+			}
+		}
+		if category == nil {
+			category, err = GetOrCreateCategory(strings.ToLower(row.Category), store)
+			if err != nil {
+				report.Errors = append(report.Errors, &types.ImportRowError{File: fileName, Row: row.Row, Error: err.Error()})
+				continue
+			}
+			categories = append(categories, category)
+		}
+
+		newTransaction := &types.Transaction{
+			ID:              uuid.Must(uuid.NewV7()),
+			AccountID:       account.ID,
+			CategoryID:      category.ID,
+			TransactionType: row.TransactionType,
+			Date:            row.Date,
+			Description:     row.Description,
+			Amount:          row.Amount,
+			Fulfilled:       row.Fulfilled,
+			CreatedAt:       time.Now().UTC(),
+			UpdatedAt:       time.Now().UTC(),
+		}
+
+		if row.CreditCard {
+			if creditCard == nil {
+				creditCard, err = getOrCreateCreditCard(config.CreditCard, store)
+				if err != nil {
+					return err
+				}
+			}
+			newTransaction.CreditCardID = &creditCard.ID
+		}
+
+		entries = append(entries, &Entry{
+			Source:      fileName,
+			Row:         row.Row,
+			Fingerprint: contentFingerprint("xlsx", fileName, row.Row, newTransaction),
+			Transaction: newTransaction,
+		})
+	}
+
+	return Persist(store, entries, report)
+}
+
+type CategoryStorage interface {
+	GetCategoryByDescription(string) (*types.Category, error)
+	CreateCategory(*types.Category) error
+}
+
+func GetOrCreateCategory(description string, store CategoryStorage) (*types.Category, error) {
+	category, err := store.GetCategoryByDescription(description)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("error searching for category %s: %w", description, err)
+	}
+
+	if category != nil {
+		return category, nil
+	}
+
+	newCategory := &types.Category{
+		ID:          uuid.Must(uuid.NewV7()),
+		Description: description,
+		CreatedAt:   time.Now().UTC(),
+		UpdatedAt:   time.Now().UTC(),
+	}
+	if err := store.CreateCategory(newCategory); err != nil {
+		return nil, fmt.Errorf("error creating category %s: %w", description, err)
+	}
+
+	return newCategory, nil
+}
+
+func getOrCreateCreditCard(config XlsxCreditCard, store XlsxStorage) (*types.CreditCard, error) {
+	creditCard, _ := store.GetCreditCardByName(config.Name)
+	if creditCard != nil {
+		return creditCard, nil
+	}
+
+	creditCard = &types.CreditCard{
+		ID:         uuid.Must(uuid.NewV7()),
+		Name:       config.Name,
+		ClosingDay: config.ClosingDay,
+		DueDay:     config.DueDay,
+		CreatedAt:  time.Now().UTC(),
+		UpdatedAt:  time.Now().UTC(),
+	}
+	if err := store.CreateCreditCard(creditCard); err != nil {
+		return nil, fmt.Errorf("error creating credit card %s: %w", config.Name, err)
+	}
+
+	return creditCard, nil
+}
+
+func getOrCreateAccount(accountName string, accountType types.AccountType, store XlsxStorage) (*types.Account, error) {
+	account, err := store.GetUniqueAccount(accountName, accountType)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("error getting unique account %s %s: %w", accountName, accountType, err)
+	}
+
+	if account != nil {
+		return account, nil
+	}
+
+	newAccount := &types.Account{
+		ID:          uuid.Must(uuid.NewV7()),
+		AccountType: accountType,
+		Owner:       accountType.Owner(),
+		Kind:        types.AccountKindChecking,
+		Name:        accountName,
+		CreatedAt:   time.Now().UTC(),
+		UpdatedAt:   time.Now().UTC(),
+	}
+	if err := store.CreateAccount(newAccount); err != nil {
+		return nil, fmt.Errorf("error creating account %s: %w", accountName, err)
+	}
+
+	return newAccount, nil
+}
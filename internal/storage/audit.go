@@ -0,0 +1,106 @@
+package storage
+
+import (
+	"database/sql"
+
+	"github.com/google/uuid"
+	"github.com/mdsavian/budget-tracker-api/internal/types"
+)
+
+// Audit
+func (s *PostgresStore) createAuditTable() error {
+	query := `create table if not exists "audit" (
+		id UUID NOT NULL,
+		user_id UUID NULL,
+		request_id UUID NULL,
+		entity varchar (40) NOT NULL,
+		entity_id UUID NOT NULL,
+		action varchar (20) NOT NULL,
+		before jsonb NULL,
+		after jsonb NULL,
+		created_at timestamptz NOT NULL,
+
+		PRIMARY KEY ("id")
+	);
+	create index if not exists audit_entity on "audit" (entity, entity_id, created_at)`
+	_, err := s.db.Exec(query)
+	return err
+}
+
+// CreateAuditEntry appends an entry, audit entries are never updated or deleted
+func (s *PostgresStore) CreateAuditEntry(entry *types.AuditEntry) error {
+	query := `insert into "audit"
+		(id, user_id, request_id, entity, entity_id, action, before, after, created_at)
+		values ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
+
+	_, err := s.db.Exec(query,
+		entry.ID,
+		entry.UserID,
+		entry.RequestID,
+		entry.Entity,
+		entry.EntityID,
+		entry.Action,
+		nullableJSON(entry.Before),
+		nullableJSON(entry.After),
+		entry.CreatedAt)
+	return err
+}
+
+// GetAuditEntries returns the latest entries, optionally of an entity kind and a single entity
+func (s *PostgresStore) GetAuditEntries(entity types.AuditEntity, entityID *uuid.UUID, limit int) ([]*types.AuditEntry, error) {
+	query := `select * from "audit" a
+		where ($1 = '' or a.entity = $1)
+			and ($2::uuid is null or a.entity_id = $2)
+		order by a.created_at desc
+		limit $3`
+
+	rows, err := s.db.Query(query, entity, entityID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []*types.AuditEntry{}
+	for rows.Next() {
+		entry, err := scanIntoAuditEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+func scanIntoAuditEntry(rows *sql.Rows) (*types.AuditEntry, error) {
+	entry := &types.AuditEntry{}
+	var before, after []byte
+	err := rows.Scan(
+		&entry.ID,
+		&entry.UserID,
+		&entry.RequestID,
+		&entry.Entity,
+		&entry.EntityID,
+		&entry.Action,
+		&before,
+		&after,
+		&entry.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if before != nil {
+		entry.Before = before
+	}
+	if after != nil {
+		entry.After = after
+	}
+	return entry, nil
+}
+
+// nullableJSON stores missing documents as null instead of an empty jsonb
+func nullableJSON(value []byte) any {
+	if len(value) == 0 {
+		return nil
+	}
+	return string(value)
+}
@@ -0,0 +1,440 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mdsavian/budget-tracker-api/internal/types"
+)
+
+func (s *PostgresStore) GetRecurringTransactions() ([]*types.RecurringTransaction, error) {
+	rows, err := s.db.Query("select * from recurring_transaction r order by r.created_at")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	recurringTransactions := []*types.RecurringTransaction{}
+	for rows.Next() {
+		recurringTransaction, err := scanIntoRecurringTransaction(rows)
+		if err != nil {
+			return nil, err
+		}
+		recurringTransactions = append(recurringTransactions, recurringTransaction)
+	}
+	return recurringTransactions, nil
+}
+
+// GetTransactions returns every transaction, archived ones included
+func (s *PostgresStore) GetTransactions() ([]*types.Transaction, error) {
+	rows, err := s.db.Query(`select * from "transaction" t order by t.date, t.created_at`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	transactions := []*types.Transaction{}
+	for rows.Next() {
+		transaction, err := scanIntoTransaction(rows)
+		if err != nil {
+			return nil, err
+		}
+		transactions = append(transactions, transaction)
+	}
+	return transactions, nil
+}
+
+// HasBudgetData tells whether any of the tables a restore replaces has rows
+func (s *PostgresStore) HasBudgetData() (bool, error) {
+	exists := make([]string, 0, len(budgetTables))
+	for _, table := range budgetTables {
+		exists = append(exists, "exists (select 1 from "+table+")")
+	}
+	query := "select " + strings.Join(exists, " or ")
+
+	var hasData bool
+	err := s.db.QueryRow(query).Scan(&hasData)
+	return hasData, err
+}
+
+// RestoreBackup inserts the backup keeping ids, timestamps and archived flags.
+// With replace the current budget data and import history are deleted first.
+// Everything runs in a single database transaction.
+func (s *PostgresStore) RestoreBackup(backup *types.Backup, replace bool) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if replace {
+		if err := deleteBudgetData(tx); err != nil {
+			return err
+		}
+	}
+
+	// backups taken before workspaces existed go to a new workspace owned by every user
+	if len(backup.Workspaces) == 0 {
+		if err := addDefaultWorkspace(tx, backup); err != nil {
+			return err
+		}
+	}
+
+	for _, workspace := range backup.Workspaces {
+		_, err := tx.Exec(`insert into "workspace" (id, name, created_at, updated_at) values ($1, $2, $3, $4)`,
+			workspace.ID, workspace.Name, workspace.CreatedAt, workspace.UpdatedAt)
+		if err != nil {
+			return err
+		}
+	}
+
+	// users are not part of the backup, members whose user doesn't exist here wait for
+	// a user with their email as an invitation does
+	for _, member := range backup.WorkspaceMembers {
+		_, err := tx.Exec(`insert into "workspace_member"
+			(id, workspace_id, user_id, email, role, created_at, updated_at)
+			values ($1, $2, (select u.id from "user" u where u.id = $3 or lower(u.email) = $4 order by u.id = $3 desc limit 1),
+				$4, $5, $6, $7)`,
+			member.ID, member.WorkspaceID, member.UserID, member.Email, member.Role, member.CreatedAt, member.UpdatedAt)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, account := range backup.Accounts {
+		// backups taken before owner and kind existed only have the account type
+		if account.Owner == "" {
+			account.Owner = account.AccountType.Owner()
+		}
+		if account.Kind == "" {
+			account.Kind = types.AccountKindChecking
+		}
+
+		_, err := tx.Exec(`insert into account
+			(id, name, account_type, balance, opening_balance, owner, kind, yield_rate, opening_date, created_at, updated_at,
+				workspace_id)
+			values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`,
+			account.ID, account.Name, account.AccountType, account.Balance, account.OpeningBalance,
+			account.Owner, account.Kind, account.YieldRate, account.OpeningDate, account.CreatedAt, account.UpdatedAt,
+			account.WorkspaceID)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, category := range backup.Categories {
+		_, err := tx.Exec(`insert into "category"
+			(id, description, archived, created_at, updated_at, archived_at, workspace_id)
+			values ($1, $2, $3, $4, $5, $6, $7)`,
+			category.ID, category.Description, category.Archived, category.CreatedAt, category.UpdatedAt, category.ArchivedAt,
+			category.WorkspaceID)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, card := range backup.CreditCards {
+		_, err := tx.Exec(`insert into "credit_card"
+			(id, name, archived, due_day, closing_day, created_at, updated_at, archived_at, workspace_id)
+			values ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+			card.ID, card.Name, card.Archived, card.DueDay, card.ClosingDay, card.CreatedAt, card.UpdatedAt, card.ArchivedAt,
+			card.WorkspaceID)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, recurring := range backup.RecurringTransactions {
+		_, err := tx.Exec(`insert into "recurring_transaction"
+			(id, account_id, creditcard_id, category_id, transaction_type, day, description,
+				amount, archived, created_at, updated_at, archived_at)
+			values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`,
+			recurring.ID,
+			recurring.AccountID,
+			recurring.CreditCardID,
+			recurring.CategoryID,
+			recurring.TransactionType,
+			recurring.Day,
+			recurring.Description,
+			recurring.Amount,
+			recurring.Archived,
+			recurring.CreatedAt,
+			recurring.UpdatedAt,
+			recurring.ArchivedAt)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, reconciliation := range backup.Reconciliations {
+		_, err := tx.Exec(`insert into "reconciliation"
+			(id, account_id, statement_date, statement_balance, status, created_at, updated_at, completed_at)
+			values ($1, $2, $3, $4, $5, $6, $7, $8)`,
+			reconciliation.ID,
+			reconciliation.AccountID,
+			reconciliation.StatementDate,
+			reconciliation.StatementBalance,
+			reconciliation.Status,
+			reconciliation.CreatedAt,
+			reconciliation.UpdatedAt,
+			reconciliation.CompletedAt)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, transaction := range backup.Transactions {
+		_, err := tx.Exec(`insert into "transaction"
+			(id, account_id, creditcard_id, category_id, recurring_transaction_id, transaction_type, date, effectuated_date,
+				description, amount, fulfilled, archived, external_id, reconciliation_id, reconciled, created_at, updated_at, archived_at,
+				adjustment, transfer_id)
+			values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20)`,
+			transaction.ID,
+			transaction.AccountID,
+			transaction.CreditCardID,
+			transaction.CategoryID,
+			transaction.RecurringTransactionID,
+			transaction.TransactionType,
+			transaction.Date,
+			transaction.EffectuatedDate,
+			transaction.Description,
+			transaction.Amount,
+			transaction.Fulfilled,
+			transaction.Archived,
+			transaction.ExternalID,
+			transaction.ReconciliationID,
+			transaction.Reconciled,
+			transaction.CreatedAt,
+			transaction.UpdatedAt,
+			transaction.ArchivedAt,
+			transaction.Adjustment,
+			transaction.TransferID)
+		if err != nil {
+			return err
+		}
+	}
+
+	// profiles and scenarios of backups taken before they had a workspace go to the one of
+	// their account, or to the first workspace
+	for _, profile := range backup.CSVProfiles {
+		_, err := tx.Exec(`insert into "csv_profile"
+			(id, name, delimiter, encoding, skip_rows, date_column, date_format, description_column, amount_column,
+				category_column, decimal_comma, sign_convention, account_id, creditcard_id, category_id, created_at, updated_at,
+				workspace_id)
+			values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17,
+				coalesce(nullif($18 :: uuid, '00000000-0000-0000-0000-000000000000'),
+					(select a.workspace_id from account a where a.id = $13)))`,
+			profile.ID,
+			profile.Name,
+			profile.Delimiter,
+			profile.Encoding,
+			profile.SkipRows,
+			profile.DateColumn,
+			profile.DateFormat,
+			profile.DescriptionColumn,
+			profile.AmountColumn,
+			profile.CategoryColumn,
+			profile.DecimalComma,
+			profile.SignConvention,
+			profile.AccountID,
+			profile.CreditCardID,
+			profile.CategoryID,
+			profile.CreatedAt,
+			profile.UpdatedAt,
+			profile.WorkspaceID)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, scenario := range backup.Scenarios {
+		items, err := json.Marshal(scenario.Items)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(`insert into "scenario"
+			(id, name, description, items, created_at, updated_at, workspace_id)
+			values ($1, $2, $3, $4, $5, $6, coalesce(nullif($7 :: uuid, '00000000-0000-0000-0000-000000000000'),
+				(select w.id from workspace w order by w.created_at limit 1)))`,
+			scenario.ID, scenario.Name, scenario.Description, items, scenario.CreatedAt, scenario.UpdatedAt,
+			scenario.WorkspaceID)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, holding := range backup.Holdings {
+		_, err := tx.Exec(`insert into "holding"
+			(id, account_id, asset, asset_type, quantity, cost_basis, created_at, updated_at)
+			values ($1, $2, $3, $4, $5, $6, $7, $8)`,
+			holding.ID,
+			holding.AccountID,
+			holding.Asset,
+			holding.AssetType,
+			holding.Quantity,
+			holding.CostBasis,
+			holding.CreatedAt,
+			holding.UpdatedAt)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, price := range backup.HoldingPrices {
+		_, err := tx.Exec(`insert into "holding_price"
+			(id, holding_id, date, price, source, created_at)
+			values ($1, $2, $3, $4, $5, $6)`,
+			price.ID, price.HoldingID, price.Date, price.Price, price.Source, price.CreatedAt)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, loan := range backup.Loans {
+		_, err := tx.Exec(`insert into "loan"
+			(id, description, account_id, category_id, principal, rate, term, system, start_date, created_at, updated_at)
+			values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`,
+			loan.ID,
+			loan.Description,
+			loan.AccountID,
+			loan.CategoryID,
+			loan.Principal,
+			loan.Rate,
+			loan.Term,
+			loan.System,
+			loan.StartDate,
+			loan.CreatedAt,
+			loan.UpdatedAt)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, installment := range backup.LoanInstallments {
+		_, err := tx.Exec(`insert into "loan_installment"
+			(id, loan_id, transaction_id, number, due_date, payment, interest, amortization, balance, extra, created_at)
+			values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`,
+			installment.ID,
+			installment.LoanID,
+			installment.TransactionID,
+			installment.Number,
+			installment.DueDate,
+			installment.Payment,
+			installment.Interest,
+			installment.Amortization,
+			installment.Balance,
+			installment.Extra,
+			installment.CreatedAt)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, movement := range backup.HoldingMovements {
+		_, err := tx.Exec(`insert into "holding_movement"
+			(id, holding_id, transfer_id, kind, date, amount, quantity, cost_basis, created_at)
+			values ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+			movement.ID,
+			movement.HoldingID,
+			movement.TransferID,
+			movement.Kind,
+			movement.Date,
+			movement.Amount,
+			movement.Quantity,
+			movement.CostBasis,
+			movement.CreatedAt)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// addDefaultWorkspace adds a workspace owned by every user to the backup and moves the
+// accounts, categories, cards, csv profiles and scenarios to it
+func addDefaultWorkspace(tx *sql.Tx, backup *types.Backup) error {
+	workspace := &types.Workspace{
+		ID:        uuid.Must(uuid.NewV7()),
+		Name:      "Household",
+		CreatedAt: time.Now().UTC(),
+		UpdatedAt: time.Now().UTC(),
+	}
+	backup.Workspaces = []*types.Workspace{workspace}
+
+	rows, err := tx.Query(`select id, lower(email) from "user"`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		member := &types.WorkspaceMember{
+			ID:          uuid.Must(uuid.NewV7()),
+			WorkspaceID: workspace.ID,
+			UserID:      &uuid.UUID{},
+			Role:        types.WorkspaceRoleOwner,
+			CreatedAt:   time.Now().UTC(),
+			UpdatedAt:   time.Now().UTC(),
+		}
+		if err := rows.Scan(member.UserID, &member.Email); err != nil {
+			return err
+		}
+		backup.WorkspaceMembers = append(backup.WorkspaceMembers, member)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, account := range backup.Accounts {
+		account.WorkspaceID = workspace.ID
+	}
+	for _, category := range backup.Categories {
+		category.WorkspaceID = workspace.ID
+	}
+	for _, card := range backup.CreditCards {
+		card.WorkspaceID = workspace.ID
+	}
+	for _, profile := range backup.CSVProfiles {
+		profile.WorkspaceID = workspace.ID
+	}
+	for _, scenario := range backup.Scenarios {
+		scenario.WorkspaceID = workspace.ID
+	}
+	return nil
+}
+
+// deleteBudgetData removes the rows in dependency order, keeping users and sessions
+// budgetTables are the tables a restore replaces, in an order that deletes the references first
+var budgetTables = []string{
+	"import_job",
+	"scenario",
+	"holding_movement",
+	"holding_price",
+	"holding",
+	"loan_installment",
+	"loan",
+	"import_fingerprint",
+	"csv_profile",
+	`"transaction"`,
+	"reconciliation",
+	"recurring_transaction",
+	"credit_card",
+	"category",
+	"account",
+	"workspace_member",
+	"workspace",
+}
+
+func deleteBudgetData(tx *sql.Tx) error {
+	for _, table := range budgetTables {
+		if _, err := tx.Exec("delete from " + table); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mdsavian/budget-tracker-api/internal/finance"
+	"github.com/mdsavian/budget-tracker-api/internal/types"
+)
+
+// computedBalanceQuery computes the balance of the accounts from their opening balance
+// and fulfilled transactions since the opening date, comparing it with the stored one
+const computedBalanceQuery = `select a.id, a.name, a.opening_balance, a.balance,
+		a.opening_balance + coalesce(sum(case when t.transaction_type = $1 then t.amount else -t.amount end), 0) as computed
+	from account a
+	left join "transaction" t on t.account_id = a.id and t.fulfilled = true and t.archived = false
+		and (a.opening_date is null or coalesce(t.effectuated_date, t.date) >= a.opening_date)`
+
+func (s *PostgresStore) GetAccountBalanceAudit(accountID uuid.UUID) (*types.AccountBalanceAudit, error) {
+	query := computedBalanceQuery + `
+	where a.id = $2
+	group by a.id`
+
+	rows, err := s.db.Query(query, types.TransactionTypeCredit, accountID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		return scanIntoAccountBalanceAudit(rows)
+	}
+
+	return nil, fmt.Errorf("account %v not found", accountID)
+}
+
+func (s *PostgresStore) GetAccountBalanceAudits() ([]*types.AccountBalanceAudit, error) {
+	query := computedBalanceQuery + `
+	group by a.id
+	order by a.name`
+
+	rows, err := s.db.Query(query, types.TransactionTypeCredit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	audits := []*types.AccountBalanceAudit{}
+	for rows.Next() {
+		audit, err := scanIntoAccountBalanceAudit(rows)
+		if err != nil {
+			return nil, err
+		}
+		audits = append(audits, audit)
+	}
+	return audits, nil
+}
+
+// SetAccountBalance overwrites the stored balance, used to repair a drifted snapshot
+func (s *PostgresStore) SetAccountBalance(accountID uuid.UUID, balance float32) error {
+	query := `update account set balance = $1, updated_at = $2 where id = $3`
+	_, err := s.db.Exec(query, balance, time.Now().UTC(), accountID)
+	return err
+}
+
+func scanIntoAccountBalanceAudit(rows *sql.Rows) (*types.AccountBalanceAudit, error) {
+	audit := &types.AccountBalanceAudit{}
+	err := rows.Scan(
+		&audit.AccountID,
+		&audit.Account,
+		&audit.OpeningBalance,
+		&audit.StoredBalance,
+		&audit.ComputedBalance)
+	if err != nil {
+		return nil, err
+	}
+
+	audit.Drift = finance.RoundCents(audit.StoredBalance - audit.ComputedBalance)
+	return audit, nil
+}
+
+// GetBalanceHistory returns the balance at the end of each interval between the dates, from the
+// opening balance once taken and the fulfilled transactions from the opening date up to then. Without an account every account
+// of the workspace is summed, or every account when workspaceID is nil too.
+func (s *PostgresStore) GetBalanceHistory(accountID, workspaceID *uuid.UUID, startDate, endDate time.Time, interval types.BalanceInterval) ([]*types.BalancePoint, error) {
+	query := `with periods as (
+			select least((p + ('1 ' || $3) :: interval - interval '1 day') :: date, $2 :: date) as period_end
+			from generate_series(date_trunc($3, $1 :: timestamp), $2 :: timestamp, ('1 ' || $3) :: interval) p
+		),
+		opening as (
+			select coalesce(a.opening_date, '-infinity' :: date) as opening_date, a.opening_balance
+			from account a
+			where ($4 :: uuid is null or a.id = $4)
+				and ($6 :: uuid is null or a.workspace_id = $6)
+		),
+		movements as (
+			select coalesce(t.effectuated_date, t.date) as effectuated,
+				sum(case when t.transaction_type = $5 then t.amount else -t.amount end) as amount
+			from "transaction" t
+			join account a on a.id = t.account_id
+			where t.fulfilled = true
+				and t.archived = false
+				and ($4 :: uuid is null or t.account_id = $4)
+				and ($6 :: uuid is null or a.workspace_id = $6)
+				and (a.opening_date is null or coalesce(t.effectuated_date, t.date) >= a.opening_date)
+				and coalesce(t.effectuated_date, t.date) <= $2
+			group by 1
+		)
+		select p.period_end,
+			coalesce((select sum(o.opening_balance) from opening o where o.opening_date <= p.period_end), 0)
+				+ coalesce((select sum(m.amount) from movements m where m.effectuated <= p.period_end), 0)
+		from periods p
+		order by p.period_end`
+
+	rows, err := s.db.Query(query, startDate, endDate, string(interval), accountID, types.TransactionTypeCredit, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	points := []*types.BalancePoint{}
+	for rows.Next() {
+		point := &types.BalancePoint{}
+		if err := rows.Scan(&point.Date, &point.Balance); err != nil {
+			return nil, err
+		}
+		points = append(points, point)
+	}
+	return points, nil
+}
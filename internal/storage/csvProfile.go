@@ -0,0 +1,135 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/mdsavian/budget-tracker-api/internal/types"
+)
+
+// CSV profile
+func (s *PostgresStore) createCSVProfileTable() error {
+	query := `create table if not exists "csv_profile" (
+		id UUID NOT NULL,
+		name varchar (100) NOT NULL,
+		delimiter varchar (1) NOT NULL,
+		encoding varchar (20) NOT NULL,
+		skip_rows int NOT NULL DEFAULT 0,
+		date_column int NOT NULL,
+		date_format varchar (30) NOT NULL,
+		description_column int NOT NULL,
+		amount_column int NOT NULL,
+		category_column int NULL,
+		decimal_comma boolean NOT NULL DEFAULT false,
+		sign_convention varchar (20) NOT NULL,
+		account_id UUID NOT NULL,
+		creditcard_id UUID NULL,
+		category_id UUID NOT NULL,
+
+		created_at timestamptz NOT NULL,
+		updated_at timestamptz NOT NULL,
+
+		PRIMARY KEY ("id"),
+		CONSTRAINT uc_csv_profile_name UNIQUE(name),
+		CONSTRAINT "csv_profile_account" FOREIGN KEY ("account_id") REFERENCES "account" ("id"),
+		CONSTRAINT "csv_profile_card" FOREIGN KEY ("creditcard_id") REFERENCES "credit_card" ("id"),
+		CONSTRAINT "csv_profile_category" FOREIGN KEY ("category_id") REFERENCES "category" ("id")
+	)`
+	_, err := s.db.Exec(query)
+	return err
+}
+
+func (s *PostgresStore) CreateCSVProfile(profile *types.CSVProfile) error {
+	query := `insert into "csv_profile"
+		(id, name, delimiter, encoding, skip_rows, date_column, date_format, description_column, amount_column,
+			category_column, decimal_comma, sign_convention, account_id, creditcard_id, category_id, created_at, updated_at,
+			workspace_id)
+		values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18)`
+
+	_, err := s.db.Exec(query,
+		profile.ID,
+		profile.Name,
+		profile.Delimiter,
+		profile.Encoding,
+		profile.SkipRows,
+		profile.DateColumn,
+		profile.DateFormat,
+		profile.DescriptionColumn,
+		profile.AmountColumn,
+		profile.CategoryColumn,
+		profile.DecimalComma,
+		profile.SignConvention,
+		profile.AccountID,
+		profile.CreditCardID,
+		profile.CategoryID,
+		profile.CreatedAt,
+		profile.UpdatedAt,
+		profile.WorkspaceID)
+	return err
+}
+
+func (s *PostgresStore) DeleteCSVProfile(id uuid.UUID) error {
+	_, err := s.db.Exec(`delete from "csv_profile" where id = $1`, id)
+	return err
+}
+
+func (s *PostgresStore) GetCSVProfileByID(id uuid.UUID) (*types.CSVProfile, error) {
+	rows, err := s.db.Query(`select * from "csv_profile" where id = $1`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		return scanIntoCSVProfile(rows)
+	}
+
+	return nil, fmt.Errorf("csv profile %v not found", id)
+}
+
+// GetCSVProfiles returns the profiles of the workspace, or of every workspace when workspaceID is nil
+func (s *PostgresStore) GetCSVProfiles(workspaceID *uuid.UUID) ([]*types.CSVProfile, error) {
+	rows, err := s.db.Query(`select * from "csv_profile" p
+		where $1::uuid is null or p.workspace_id = $1
+		order by p.name`, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	profiles := []*types.CSVProfile{}
+	for rows.Next() {
+		profile, err := scanIntoCSVProfile(rows)
+		if err != nil {
+			return nil, err
+		}
+		profiles = append(profiles, profile)
+	}
+	return profiles, nil
+}
+
+func scanIntoCSVProfile(rows *sql.Rows) (*types.CSVProfile, error) {
+	profile := &types.CSVProfile{}
+	err := rows.Scan(
+		&profile.ID,
+		&profile.Name,
+		&profile.Delimiter,
+		&profile.Encoding,
+		&profile.SkipRows,
+		&profile.DateColumn,
+		&profile.DateFormat,
+		&profile.DescriptionColumn,
+		&profile.AmountColumn,
+		&profile.CategoryColumn,
+		&profile.DecimalComma,
+		&profile.SignConvention,
+		&profile.AccountID,
+		&profile.CreditCardID,
+		&profile.CategoryID,
+		&profile.CreatedAt,
+		&profile.UpdatedAt,
+		&profile.WorkspaceID)
+
+	return profile, err
+}
@@ -0,0 +1,59 @@
+package storage
+
+import (
+	"time"
+
+	"github.com/mdsavian/budget-tracker-api/internal/types"
+)
+
+// GetUnfulfilledTransactionsBefore returns the transactions dated before the date that were not fulfilled yet
+func (s *PostgresStore) GetUnfulfilledTransactionsBefore(date time.Time) ([]*types.TransactionView, error) {
+	query := `
+	SELECT 
+		t.id, 
+		t.account_id, 
+		a."name" AS Account,
+		t.creditcard_id,
+		c."name" AS CreditCard,
+		t.category_id,
+		c2.description AS Category,
+		t.recurring_transaction_id,
+		t.transaction_type,
+		t.date, 
+		t.effectuated_date,
+		t.description, 
+		t.amount, 
+		t.fulfilled,
+		t.adjustment,
+		t.transfer_id
+	FROM 
+		transaction t
+	LEFT JOIN 
+		credit_card c ON c.id = t.creditcard_id 
+	LEFT JOIN 
+		category c2 ON c2.id = t.category_id 
+	LEFT JOIN 
+		account a ON a.id = t.account_id
+	WHERE 
+		t.date < $1
+		AND t.fulfilled = false
+		AND t.archived = false
+	ORDER BY 
+		t.date`
+
+	rows, err := s.db.Query(query, date)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	transactions := []*types.TransactionView{}
+	for rows.Next() {
+		transaction, err := scanIntoTransactionView(rows)
+		if err != nil {
+			return nil, err
+		}
+		transactions = append(transactions, transaction)
+	}
+	return transactions, rows.Err()
+}
@@ -0,0 +1,207 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mdsavian/budget-tracker-api/internal/types"
+)
+
+// Import fingerprint
+func (s *PostgresStore) createImportFingerprintTable() error {
+	query := `create table if not exists "import_fingerprint" (
+		fingerprint varchar (64) NOT NULL,
+		source varchar (200) NOT NULL,
+		row int NOT NULL,
+		transaction_id UUID NOT NULL,
+		created_at timestamptz NOT NULL,
+
+		PRIMARY KEY ("fingerprint"),
+		CONSTRAINT "import_fingerprint_transaction" FOREIGN KEY ("transaction_id") REFERENCES "transaction" ("id")
+	);
+	CREATE INDEX IF NOT EXISTS "idx_import_fingerprint_transaction" ON "import_fingerprint" ("transaction_id");`
+	_, err := s.db.Exec(query)
+	return err
+}
+
+func (s *PostgresStore) CreateImportFingerprint(fingerprint *types.ImportFingerprint) error {
+	query := `insert into "import_fingerprint"
+		(fingerprint, source, row, transaction_id, created_at)
+		values ($1, $2, $3, $4, $5)`
+
+	_, err := s.db.Exec(query,
+		fingerprint.Fingerprint,
+		fingerprint.Source,
+		fingerprint.Row,
+		fingerprint.TransactionID,
+		fingerprint.CreatedAt)
+	return err
+}
+
+func (s *PostgresStore) ExistsImportFingerprint(fingerprint string) (bool, error) {
+	query := `select exists(select 1 from "import_fingerprint" where fingerprint = $1)`
+
+	var exists bool
+	if err := s.db.QueryRow(query, fingerprint).Scan(&exists); err != nil {
+		return false, err
+	}
+
+	return exists, nil
+}
+
+func (s *PostgresStore) DeleteImportFingerprintsByTransaction(transactionID uuid.UUID) error {
+	_, err := s.db.Exec(`delete from "import_fingerprint" where transaction_id = $1`, transactionID)
+	return err
+}
+
+// Import job
+func (s *PostgresStore) createImportJobTable() error {
+	query := `create table if not exists "import_job" (
+		id UUID NOT NULL,
+		format varchar (10) NOT NULL,
+		file_name varchar (200) NOT NULL,
+		status varchar (20) NOT NULL,
+		dry_run boolean NOT NULL DEFAULT false,
+		total_rows int NOT NULL DEFAULT 0,
+		processed_rows int NOT NULL DEFAULT 0,
+		created int NOT NULL DEFAULT 0,
+		matched int NOT NULL DEFAULT 0,
+		skipped int NOT NULL DEFAULT 0,
+		ignored int NOT NULL DEFAULT 0,
+		errors jsonb NOT NULL DEFAULT '[]',
+		transaction_ids jsonb NOT NULL DEFAULT '[]',
+		error text NOT NULL DEFAULT '',
+		created_at timestamptz NOT NULL,
+		updated_at timestamptz NOT NULL,
+		finished_at timestamptz NULL,
+
+		PRIMARY KEY ("id")
+	)`
+	_, err := s.db.Exec(query)
+	return err
+}
+
+func (s *PostgresStore) CreateImportJob(job *types.ImportJob) error {
+	query := `insert into "import_job"
+		(id, format, file_name, status, dry_run, created_at, updated_at, workspace_id)
+		values ($1, $2, $3, $4, $5, $6, $7, $8)`
+
+	_, err := s.db.Exec(query, job.ID, job.Format, job.FileName, job.Status, job.DryRun, job.CreatedAt, job.UpdatedAt,
+		job.WorkspaceID)
+	return err
+}
+
+func (s *PostgresStore) UpdateImportJob(job *types.ImportJob) error {
+	errors, err := json.Marshal(job.Errors)
+	if err != nil {
+		return err
+	}
+
+	transactionIDs, err := json.Marshal(job.TransactionIDs)
+	if err != nil {
+		return err
+	}
+
+	query := `UPDATE "import_job" SET
+		status = $1,
+		total_rows = $2,
+		processed_rows = $3,
+		created = $4,
+		matched = $5,
+		skipped = $6,
+		ignored = $7,
+		errors = $8,
+		transaction_ids = $9,
+		error = $10,
+		finished_at = $11,
+		updated_at = $12
+		WHERE id = $13`
+
+	_, err = s.db.Exec(query,
+		job.Status,
+		job.TotalRows,
+		job.ProcessedRows,
+		job.Created,
+		job.Matched,
+		job.Skipped,
+		job.Ignored,
+		errors,
+		transactionIDs,
+		job.Error,
+		job.FinishedAt,
+		time.Now().UTC(),
+		job.ID)
+	return err
+}
+
+// FailUnfinishedImportJobs marks as failed the jobs that were queued or running when the
+// server stopped, their files were only kept in memory and cannot be processed anymore
+func (s *PostgresStore) FailUnfinishedImportJobs() error {
+	query := `UPDATE "import_job" SET status = $1, error = $2, finished_at = $3, updated_at = $3
+		WHERE status in ($4, $5)`
+
+	_, err := s.db.Exec(query,
+		types.ImportJobStatusFailed,
+		"interrupted by a server restart",
+		time.Now().UTC(),
+		types.ImportJobStatusPending,
+		types.ImportJobStatusRunning)
+	return err
+}
+
+func (s *PostgresStore) GetImportJobByID(id uuid.UUID) (*types.ImportJob, error) {
+	query := `select id, format, file_name, status, dry_run, total_rows, processed_rows, created, matched,
+		skipped, ignored, errors, transaction_ids, error, created_at, updated_at, finished_at, workspace_id
+		from "import_job" where id = $1`
+	rows, err := s.db.Query(query, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		return scanIntoImportJob(rows)
+	}
+
+	return nil, fmt.Errorf("import job %v not found", id)
+}
+
+func scanIntoImportJob(rows *sql.Rows) (*types.ImportJob, error) {
+	job := &types.ImportJob{}
+	var errors, transactionIDs []byte
+	err := rows.Scan(
+		&job.ID,
+		&job.Format,
+		&job.FileName,
+		&job.Status,
+		&job.DryRun,
+		&job.TotalRows,
+		&job.ProcessedRows,
+		&job.Created,
+		&job.Matched,
+		&job.Skipped,
+		&job.Ignored,
+		&errors,
+		&transactionIDs,
+		&job.Error,
+		&job.CreatedAt,
+		&job.UpdatedAt,
+		&job.FinishedAt,
+		&job.WorkspaceID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(errors, &job.Errors); err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(transactionIDs, &job.TransactionIDs); err != nil {
+		return nil, err
+	}
+
+	return job, nil
+}
@@ -0,0 +1,305 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/mdsavian/budget-tracker-api/internal/types"
+)
+
+// Investment
+func (s *PostgresStore) createInvestmentTables() error {
+	query := `create table if not exists "holding" (
+		id UUID NOT NULL,
+		account_id UUID NOT NULL,
+		asset varchar (100) NOT NULL,
+		asset_type varchar (20) NOT NULL,
+		quantity numeric (20, 8) NOT NULL DEFAULT 0,
+		cost_basis numeric (14, 2) NOT NULL DEFAULT 0,
+		created_at timestamptz NOT NULL,
+		updated_at timestamptz NOT NULL,
+
+		PRIMARY KEY ("id"),
+		CONSTRAINT uc_holding_asset UNIQUE(account_id, asset),
+		CONSTRAINT "holding_account" FOREIGN KEY ("account_id") REFERENCES "account" ("id")
+	);
+
+	create table if not exists "holding_price" (
+		id UUID NOT NULL,
+		holding_id UUID NOT NULL,
+		date date NOT NULL,
+		price numeric (20, 8) NOT NULL,
+		source varchar (20) NOT NULL,
+		created_at timestamptz NOT NULL,
+
+		PRIMARY KEY ("id"),
+		CONSTRAINT uc_holding_price_date UNIQUE(holding_id, date),
+		CONSTRAINT "holding_price_holding" FOREIGN KEY ("holding_id") REFERENCES "holding" ("id")
+	);
+
+	create table if not exists "holding_movement" (
+		id UUID NOT NULL,
+		holding_id UUID NOT NULL,
+		transfer_id UUID NULL,
+		kind varchar (20) NOT NULL,
+		date date NOT NULL,
+		amount numeric (14, 2) NOT NULL,
+		quantity numeric (20, 8) NOT NULL,
+		cost_basis numeric (14, 2) NOT NULL,
+		created_at timestamptz NOT NULL,
+
+		PRIMARY KEY ("id"),
+		CONSTRAINT "holding_movement_holding" FOREIGN KEY ("holding_id") REFERENCES "holding" ("id")
+	)`
+	_, err := s.db.Exec(query)
+	return err
+}
+
+func (s *PostgresStore) CreateHolding(holding *types.Holding) error {
+	query := `insert into "holding"
+		(id, account_id, asset, asset_type, quantity, cost_basis, created_at, updated_at)
+		values ($1, $2, $3, $4, $5, $6, $7, $8)`
+
+	_, err := s.db.Exec(query,
+		holding.ID,
+		holding.AccountID,
+		holding.Asset,
+		holding.AssetType,
+		holding.Quantity,
+		holding.CostBasis,
+		holding.CreatedAt,
+		holding.UpdatedAt)
+	return err
+}
+
+func (s *PostgresStore) UpdateHolding(holding *types.Holding) error {
+	return updateHolding(s.db, holding)
+}
+
+func updateHolding(db execer, holding *types.Holding) error {
+	query := `UPDATE "holding" SET asset = $1, asset_type = $2, quantity = $3, cost_basis = $4, updated_at = $5 WHERE id = $6`
+	_, err := db.Exec(query, holding.Asset, holding.AssetType, holding.Quantity, holding.CostBasis, holding.UpdatedAt, holding.ID)
+	return err
+}
+
+func (s *PostgresStore) GetHoldingByID(id uuid.UUID) (*types.Holding, error) {
+	rows, err := s.db.Query(`select * from "holding" where id = $1`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		return scanIntoHolding(rows)
+	}
+
+	return nil, fmt.Errorf("holding %v not found", id)
+}
+
+// GetHoldings returns the holdings of the account, or of every account when accountID is nil
+func (s *PostgresStore) GetHoldings(accountID *uuid.UUID) ([]*types.Holding, error) {
+	rows, err := s.db.Query(`select * from "holding" h
+		where $1::uuid is null or h.account_id = $1
+		order by h.asset`, accountID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	holdings := []*types.Holding{}
+	for rows.Next() {
+		holding, err := scanIntoHolding(rows)
+		if err != nil {
+			return nil, err
+		}
+		holdings = append(holdings, holding)
+	}
+	return holdings, rows.Err()
+}
+
+func scanIntoHolding(rows *sql.Rows) (*types.Holding, error) {
+	holding := &types.Holding{}
+	err := rows.Scan(
+		&holding.ID,
+		&holding.AccountID,
+		&holding.Asset,
+		&holding.AssetType,
+		&holding.Quantity,
+		&holding.CostBasis,
+		&holding.CreatedAt,
+		&holding.UpdatedAt)
+	return holding, err
+}
+
+// SaveHoldingPrice stores the price of the holding on its date, replacing the price
+// already stored for that day
+func (s *PostgresStore) SaveHoldingPrice(price *types.HoldingPrice) error {
+	query := `insert into "holding_price"
+		(id, holding_id, date, price, source, created_at)
+		values ($1, $2, $3, $4, $5, $6)
+		on conflict (holding_id, date) do update set price = excluded.price, source = excluded.source
+		returning id, created_at`
+
+	return s.db.QueryRow(query,
+		price.ID,
+		price.HoldingID,
+		price.Date,
+		price.Price,
+		price.Source,
+		price.CreatedAt).Scan(&price.ID, &price.CreatedAt)
+}
+
+// GetHoldingPrices returns the prices of the holding, or of every holding when holdingID is nil, newest first
+func (s *PostgresStore) GetHoldingPrices(holdingID *uuid.UUID) ([]*types.HoldingPrice, error) {
+	rows, err := s.db.Query(`select * from "holding_price" hp
+		where $1::uuid is null or hp.holding_id = $1
+		order by hp.date desc`, holdingID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	prices := []*types.HoldingPrice{}
+	for rows.Next() {
+		price := &types.HoldingPrice{}
+		err := rows.Scan(
+			&price.ID,
+			&price.HoldingID,
+			&price.Date,
+			&price.Price,
+			&price.Source,
+			&price.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		prices = append(prices, price)
+	}
+	return prices, rows.Err()
+}
+
+// SaveHoldingMovement creates the fulfilled transactions of the movement applying them to their
+// account balances, updates the holding and stores the movement, in a single database transaction
+func (s *PostgresStore) SaveHoldingMovement(movement *types.HoldingMovement, holding *types.Holding, transactions []*types.Transaction) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, transaction := range transactions {
+		if err := createTransaction(tx, transaction); err != nil {
+			return err
+		}
+		if err := updateAccountBalance(tx, transaction.AccountID, transaction.Amount, transaction.TransactionType, transaction.EffectiveDate()); err != nil {
+			return err
+		}
+	}
+
+	if err := updateHolding(tx, holding); err != nil {
+		return err
+	}
+
+	if err := createHoldingMovement(tx, movement); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *PostgresStore) CreateHoldingMovement(movement *types.HoldingMovement) error {
+	return createHoldingMovement(s.db, movement)
+}
+
+func createHoldingMovement(db execer, movement *types.HoldingMovement) error {
+	query := `insert into "holding_movement"
+		(id, holding_id, transfer_id, kind, date, amount, quantity, cost_basis, created_at)
+		values ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
+
+	_, err := db.Exec(query,
+		movement.ID,
+		movement.HoldingID,
+		movement.TransferID,
+		movement.Kind,
+		movement.Date,
+		movement.Amount,
+		movement.Quantity,
+		movement.CostBasis,
+		movement.CreatedAt)
+	return err
+}
+
+// GetHoldingMovements returns the movements of the holding, or of every holding when holdingID is nil
+func (s *PostgresStore) GetHoldingMovements(holdingID *uuid.UUID) ([]*types.HoldingMovement, error) {
+	rows, err := s.db.Query(`select * from "holding_movement" m
+		where $1::uuid is null or m.holding_id = $1
+		order by m.date, m.created_at`, holdingID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	movements := []*types.HoldingMovement{}
+	for rows.Next() {
+		movement := &types.HoldingMovement{}
+		err := rows.Scan(
+			&movement.ID,
+			&movement.HoldingID,
+			&movement.TransferID,
+			&movement.Kind,
+			&movement.Date,
+			&movement.Amount,
+			&movement.Quantity,
+			&movement.CostBasis,
+			&movement.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		movements = append(movements, movement)
+	}
+	return movements, rows.Err()
+}
+
+// GetPositions returns the holdings of the account, or of every account when accountID is nil,
+// with their latest price and the return realized by their redemptions
+func (s *PostgresStore) GetPositions(accountID *uuid.UUID) ([]*types.Position, error) {
+	query := `select h.*, p.price, p.date,
+			coalesce((select sum(m.amount - m.cost_basis) from holding_movement m
+				where m.holding_id = h.id and m.kind = $2), 0)
+		from "holding" h
+		left join lateral (
+			select hp.price, hp.date from holding_price hp
+			where hp.holding_id = h.id
+			order by hp.date desc limit 1
+		) p on true
+		where $1::uuid is null or h.account_id = $1
+		order by h.asset`
+
+	rows, err := s.db.Query(query, accountID, types.HoldingMovementRedemption)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	positions := []*types.Position{}
+	for rows.Next() {
+		position := &types.Position{Holding: &types.Holding{}}
+		err := rows.Scan(
+			&position.ID,
+			&position.AccountID,
+			&position.Asset,
+			&position.AssetType,
+			&position.Quantity,
+			&position.CostBasis,
+			&position.CreatedAt,
+			&position.UpdatedAt,
+			&position.Price,
+			&position.PriceDate,
+			&position.Realized)
+		if err != nil {
+			return nil, err
+		}
+		positions = append(positions, position)
+	}
+	return positions, rows.Err()
+}
@@ -0,0 +1,228 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mdsavian/budget-tracker-api/internal/types"
+)
+
+// Loan
+func (s *PostgresStore) createLoanTables() error {
+	query := `create table if not exists "loan" (
+		id UUID NOT NULL,
+		description varchar (100) NOT NULL,
+		account_id UUID NOT NULL,
+		category_id UUID NOT NULL,
+		principal numeric (14, 2) NOT NULL,
+		rate numeric (8, 4) NOT NULL,
+		term integer NOT NULL,
+		system varchar (10) NOT NULL,
+		start_date date NOT NULL,
+		created_at timestamptz NOT NULL,
+		updated_at timestamptz NOT NULL,
+
+		PRIMARY KEY ("id"),
+		CONSTRAINT "loan_account" FOREIGN KEY ("account_id") REFERENCES "account" ("id"),
+		CONSTRAINT "loan_category" FOREIGN KEY ("category_id") REFERENCES "category" ("id")
+	);
+
+	create table if not exists "loan_installment" (
+		id UUID NOT NULL,
+		loan_id UUID NOT NULL,
+		transaction_id UUID NOT NULL,
+		number integer NOT NULL,
+		due_date date NOT NULL,
+		payment numeric (14, 2) NOT NULL,
+		interest numeric (14, 2) NOT NULL,
+		amortization numeric (14, 2) NOT NULL,
+		balance numeric (14, 2) NOT NULL,
+		extra boolean NOT NULL DEFAULT false,
+		created_at timestamptz NOT NULL,
+
+		PRIMARY KEY ("id"),
+		CONSTRAINT "loan_installment_loan" FOREIGN KEY ("loan_id") REFERENCES "loan" ("id"),
+		CONSTRAINT "loan_installment_transaction" FOREIGN KEY ("transaction_id") REFERENCES "transaction" ("id")
+	)`
+	_, err := s.db.Exec(query)
+	return err
+}
+
+// loanOutstanding is the principal of the loan minus what the paid installments amortized
+const loanOutstanding = `l.principal - coalesce((
+		select sum(i.amortization) from loan_installment i
+		join "transaction" t on t.id = i.transaction_id
+		where i.loan_id = l.id and t.fulfilled = true and t.archived = false), 0)`
+
+func (s *PostgresStore) CreateLoan(loan *types.Loan) error {
+	query := `insert into "loan"
+		(id, description, account_id, category_id, principal, rate, term, system, start_date, created_at, updated_at)
+		values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`
+
+	_, err := s.db.Exec(query,
+		loan.ID,
+		loan.Description,
+		loan.AccountID,
+		loan.CategoryID,
+		loan.Principal,
+		loan.Rate,
+		loan.Term,
+		loan.System,
+		loan.StartDate,
+		loan.CreatedAt,
+		loan.UpdatedAt)
+	return err
+}
+
+func (s *PostgresStore) GetLoanByID(id uuid.UUID) (*types.Loan, error) {
+	rows, err := s.db.Query(`select l.*, `+loanOutstanding+` from "loan" l where l.id = $1`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		return scanIntoLoan(rows)
+	}
+
+	return nil, fmt.Errorf("loan %v not found", id)
+}
+
+func (s *PostgresStore) GetLoans() ([]*types.Loan, error) {
+	rows, err := s.db.Query(`select l.*, ` + loanOutstanding + ` from "loan" l order by l.start_date, l.description`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	loans := []*types.Loan{}
+	for rows.Next() {
+		loan, err := scanIntoLoan(rows)
+		if err != nil {
+			return nil, err
+		}
+		loans = append(loans, loan)
+	}
+	return loans, rows.Err()
+}
+
+func scanIntoLoan(rows *sql.Rows) (*types.Loan, error) {
+	loan := &types.Loan{}
+	err := rows.Scan(
+		&loan.ID,
+		&loan.Description,
+		&loan.AccountID,
+		&loan.CategoryID,
+		&loan.Principal,
+		&loan.Rate,
+		&loan.Term,
+		&loan.System,
+		&loan.StartDate,
+		&loan.CreatedAt,
+		&loan.UpdatedAt,
+		&loan.Outstanding)
+	return loan, err
+}
+
+func createLoanInstallment(db execer, installment *types.LoanInstallment) error {
+	query := `insert into "loan_installment"
+		(id, loan_id, transaction_id, number, due_date, payment, interest, amortization, balance, extra, created_at)
+		values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`
+
+	_, err := db.Exec(query,
+		installment.ID,
+		installment.LoanID,
+		installment.TransactionID,
+		installment.Number,
+		installment.DueDate,
+		installment.Payment,
+		installment.Interest,
+		installment.Amortization,
+		installment.Balance,
+		installment.Extra,
+		installment.CreatedAt)
+	return err
+}
+
+// SaveLoanInstallments removes the replaced installments archiving their planned debits, then
+// creates the transactions, applying the fulfilled ones to their account balances, and the
+// installments, in a single database transaction
+func (s *PostgresStore) SaveLoanInstallments(transactions []*types.Transaction, installments, replaced []*types.LoanInstallment) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+	for _, installment := range replaced {
+		if _, err := tx.Exec(`delete from "loan_installment" where id = $1`, installment.ID); err != nil {
+			return err
+		}
+
+		// the installments replaced are unpaid, their debits never reached the balance
+		_, err := tx.Exec(`UPDATE "transaction" SET archived = true, archived_at = $1, updated_at = $1 WHERE id = $2 AND archived = false`,
+			now, installment.TransactionID)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, transaction := range transactions {
+		if err := createTransaction(tx, transaction); err != nil {
+			return err
+		}
+		if !transaction.Fulfilled {
+			continue
+		}
+		if err := updateAccountBalance(tx, transaction.AccountID, transaction.Amount, transaction.TransactionType, transaction.EffectiveDate()); err != nil {
+			return err
+		}
+	}
+
+	for _, installment := range installments {
+		if err := createLoanInstallment(tx, installment); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetLoanInstallments returns the schedule of the loan, or of every loan when loanID is nil
+func (s *PostgresStore) GetLoanInstallments(loanID *uuid.UUID) ([]*types.LoanInstallment, error) {
+	rows, err := s.db.Query(`select i.*, coalesce(t.fulfilled and t.archived = false, false)
+		from "loan_installment" i
+		left join "transaction" t on t.id = i.transaction_id
+		where $1::uuid is null or i.loan_id = $1
+		order by i.loan_id, i.due_date, i.number`, loanID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	installments := []*types.LoanInstallment{}
+	for rows.Next() {
+		installment := &types.LoanInstallment{}
+		err := rows.Scan(
+			&installment.ID,
+			&installment.LoanID,
+			&installment.TransactionID,
+			&installment.Number,
+			&installment.DueDate,
+			&installment.Payment,
+			&installment.Interest,
+			&installment.Amortization,
+			&installment.Balance,
+			&installment.Extra,
+			&installment.CreatedAt,
+			&installment.Paid)
+		if err != nil {
+			return nil, err
+		}
+		installments = append(installments, installment)
+	}
+	return installments, rows.Err()
+}
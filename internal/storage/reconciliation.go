@@ -0,0 +1,264 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/mdsavian/budget-tracker-api/internal/types"
+)
+
+// Reconciliation
+func (s *PostgresStore) createReconciliationTable() error {
+	query := `create table if not exists "reconciliation" (
+		id UUID NOT NULL,
+		account_id UUID NOT NULL,
+		statement_date date NOT NULL,
+		statement_balance numeric NOT NULL,
+		status varchar (20) NOT NULL,
+		created_at timestamptz NOT NULL,
+		updated_at timestamptz NOT NULL,
+		completed_at timestamptz NULL,
+
+		PRIMARY KEY ("id"),
+		CONSTRAINT "reconciliation_account" FOREIGN KEY ("account_id") REFERENCES "account" ("id")
+	)`
+	_, err := s.db.Exec(query)
+	return err
+}
+
+func (s *PostgresStore) CreateReconciliation(reconciliation *types.Reconciliation) error {
+	query := `insert into "reconciliation"
+		(id, account_id, statement_date, statement_balance, status, created_at, updated_at, completed_at)
+		values ($1, $2, $3, $4, $5, $6, $7, $8)`
+
+	_, err := s.db.Exec(query,
+		reconciliation.ID,
+		reconciliation.AccountID,
+		reconciliation.StatementDate,
+		reconciliation.StatementBalance,
+		reconciliation.Status,
+		reconciliation.CreatedAt,
+		reconciliation.UpdatedAt,
+		reconciliation.CompletedAt)
+	return err
+}
+
+func (s *PostgresStore) GetReconciliationByID(id uuid.UUID) (*types.Reconciliation, error) {
+	rows, err := s.db.Query(`select * from "reconciliation" where id = $1`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		return scanIntoReconciliation(rows)
+	}
+
+	return nil, fmt.Errorf("reconciliation %v not found", id)
+}
+
+// GetOpenReconciliation returns the open reconciliation of the account, or nil when there is none
+func (s *PostgresStore) GetOpenReconciliation(accountID uuid.UUID) (*types.Reconciliation, error) {
+	rows, err := s.db.Query(`select * from "reconciliation" where account_id = $1 and status = $2`,
+		accountID, types.ReconciliationStatusOpen)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		return scanIntoReconciliation(rows)
+	}
+
+	return nil, nil
+}
+
+func (s *PostgresStore) GetReconciliations(accountID *uuid.UUID) ([]*types.Reconciliation, error) {
+	query := `select * from "reconciliation" r
+		where $1::uuid is null or r.account_id = $1
+		order by r.statement_date desc, r.created_at desc`
+	rows, err := s.db.Query(query, accountID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reconciliations := []*types.Reconciliation{}
+	for rows.Next() {
+		reconciliation, err := scanIntoReconciliation(rows)
+		if err != nil {
+			return nil, err
+		}
+		reconciliations = append(reconciliations, reconciliation)
+	}
+	return reconciliations, nil
+}
+
+// GetReconciliationTransactions returns the transactions cleared in the reconciliation
+func (s *PostgresStore) GetReconciliationTransactions(reconciliationID uuid.UUID) ([]*types.Transaction, error) {
+	query := `select * from "transaction" t
+		where t.reconciliation_id = $1 and t.archived = false
+		order by coalesce(t.effectuated_date, t.date), t.created_at`
+	return s.queryTransactions(query, reconciliationID)
+}
+
+// GetUnclearedTransactions returns the fulfilled transactions of the account up to
+// the date that are not cleared in any reconciliation
+func (s *PostgresStore) GetUnclearedTransactions(accountID uuid.UUID, until time.Time) ([]*types.Transaction, error) {
+	query := `select * from "transaction" t
+		where t.account_id = $1
+			and t.fulfilled = true
+			and t.archived = false
+			and t.reconciliation_id is null
+			and coalesce(t.effectuated_date, t.date) <= $2
+		order by coalesce(t.effectuated_date, t.date), t.created_at`
+	return s.queryTransactions(query, accountID, until)
+}
+
+func (s *PostgresStore) queryTransactions(query string, args ...any) ([]*types.Transaction, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	transactions := []*types.Transaction{}
+	for rows.Next() {
+		transaction, err := scanIntoTransaction(rows)
+		if err != nil {
+			return nil, err
+		}
+		transactions = append(transactions, transaction)
+	}
+	return transactions, nil
+}
+
+// GetClearedBalance sums the opening balance of the account, the transactions reconciled
+// in completed reconciliations and the ones cleared in the given reconciliation
+func (s *PostgresStore) GetClearedBalance(accountID, reconciliationID uuid.UUID) (float32, error) {
+	query := `select a.opening_balance + coalesce(sum(case when t.transaction_type = $3 then t.amount else -t.amount end), 0)
+		from account a
+		left join "transaction" t on t.account_id = a.id
+			and t.archived = false
+			and (t.reconciled = true or t.reconciliation_id = $2)
+			and (a.opening_date is null or coalesce(t.effectuated_date, t.date) >= a.opening_date)
+		where a.id = $1
+		group by a.id`
+
+	var balance float32
+	err := s.db.QueryRow(query, accountID, reconciliationID, types.TransactionTypeCredit).Scan(&balance)
+	return balance, err
+}
+
+// ClearTransactions links the fulfilled, not reconciled transactions of the account to the
+// reconciliation, returning how many were cleared
+func (s *PostgresStore) ClearTransactions(reconciliationID, accountID uuid.UUID, transactionIDs []uuid.UUID) (int64, error) {
+	query := `UPDATE "transaction" SET reconciliation_id = $1, updated_at = $2
+		WHERE id = any($3::uuid[])
+			and account_id = $4
+			and fulfilled = true
+			and archived = false
+			and reconciled = false`
+
+	result, err := s.db.Exec(query, reconciliationID, time.Now().UTC(), pq.Array(uuidStrings(transactionIDs)), accountID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (s *PostgresStore) UnclearTransactions(reconciliationID uuid.UUID, transactionIDs []uuid.UUID) (int64, error) {
+	query := `UPDATE "transaction" SET reconciliation_id = null, updated_at = $1
+		WHERE id = any($2::uuid[]) and reconciliation_id = $3 and reconciled = false`
+
+	result, err := s.db.Exec(query, time.Now().UTC(), pq.Array(uuidStrings(transactionIDs)), reconciliationID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// CompleteReconciliation locks the cleared transactions and completes the reconciliation
+func (s *PostgresStore) CompleteReconciliation(id uuid.UUID) error {
+	return s.setReconciliationStatus(id, types.ReconciliationStatusCompleted, true)
+}
+
+// UndoReconciliation unlocks the transactions and reopens the reconciliation, keeping them cleared
+func (s *PostgresStore) UndoReconciliation(id uuid.UUID) error {
+	return s.setReconciliationStatus(id, types.ReconciliationStatusOpen, false)
+}
+
+func (s *PostgresStore) setReconciliationStatus(id uuid.UUID, status types.ReconciliationStatus, reconciled bool) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+	var completedAt *time.Time
+	if reconciled {
+		completedAt = &now
+	}
+
+	_, err = tx.Exec(`UPDATE "transaction" SET reconciled = $1, updated_at = $2 WHERE reconciliation_id = $3`,
+		reconciled, now, id)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`UPDATE "reconciliation" SET status = $1, completed_at = $2, updated_at = $3 WHERE id = $4`,
+		status, completedAt, now, id)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// DeleteReconciliation removes an open reconciliation, unclearing its transactions
+func (s *PostgresStore) DeleteReconciliation(id uuid.UUID) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`UPDATE "transaction" SET reconciliation_id = null, updated_at = $1 WHERE reconciliation_id = $2`,
+		time.Now().UTC(), id)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`delete from "reconciliation" where id = $1`, id); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func scanIntoReconciliation(rows *sql.Rows) (*types.Reconciliation, error) {
+	reconciliation := &types.Reconciliation{}
+	err := rows.Scan(
+		&reconciliation.ID,
+		&reconciliation.AccountID,
+		&reconciliation.StatementDate,
+		&reconciliation.StatementBalance,
+		&reconciliation.Status,
+		&reconciliation.CreatedAt,
+		&reconciliation.UpdatedAt,
+		&reconciliation.CompletedAt)
+
+	return reconciliation, err
+}
+
+func uuidStrings(ids []uuid.UUID) []string {
+	values := make([]string, 0, len(ids))
+	for _, id := range ids {
+		values = append(values, id.String())
+	}
+	return values
+}
@@ -0,0 +1,261 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mdsavian/budget-tracker-api/internal/types"
+)
+
+// categorySeriesQuery fills every month of the window for the categories with transactions in it,
+// so months without transactions count as zero
+const categorySeriesQuery = `with months as (
+		select generate_series($1 :: date, $2 :: date, interval '1 month') :: date as month
+	),
+	totals as (
+		select t.category_id, t.transaction_type, date_trunc('month', t.date) :: date as month, sum(t.amount) as total
+		from "transaction" t
+		where t.archived = false
+			and t.adjustment = false
+			and t.transfer_id is null
+			and ($3 :: uuid is null or t.account_id in (select a.id from account a where a.workspace_id = $3))
+			and t.date >= $1
+			and t.date < $2 :: date + interval '1 month'
+		group by 1, 2, 3
+	),
+	keys as (
+		select distinct t.category_id, t.transaction_type from totals t
+	),
+	series as (
+		select k.category_id, c.description, k.transaction_type, m.month, coalesce(t.total, 0) as total
+		from keys k
+		join category c on c.id = k.category_id
+		cross join months m
+		left join totals t on t.category_id = k.category_id and t.transaction_type = k.transaction_type and t.month = m.month
+	)`
+
+// GetMonthlyReport aggregates the transactions between the months, both included, of the
+// workspace accounts or of every account when workspaceID is nil
+func (s *PostgresStore) GetMonthlyReport(from, to time.Time, workspaceID *uuid.UUID) (*types.MonthlyReport, error) {
+	report := &types.MonthlyReport{From: from, To: to}
+
+	var err error
+	if report.Months, err = s.getMonthlyTotals(from, to, workspaceID); err != nil {
+		return nil, err
+	}
+	if report.Categories, err = s.getCategoryReports(from, to, workspaceID); err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+func (s *PostgresStore) getMonthlyTotals(from, to time.Time, workspaceID *uuid.UUID) ([]*types.MonthlyTotal, error) {
+	query := `with months as (
+			select generate_series($1 :: date, $2 :: date, interval '1 month') :: date as month
+		),
+		totals as (
+			select m.month,
+				coalesce(sum(t.amount) filter (where t.transaction_type = $3), 0) as credit,
+				coalesce(sum(t.amount) filter (where t.transaction_type = $4), 0) as debit
+			from months m
+			left join "transaction" t on date_trunc('month', t.date) :: date = m.month and t.archived = false
+				and t.adjustment = false and t.transfer_id is null
+				and ($5 :: uuid is null or t.account_id in (select a.id from account a where a.workspace_id = $5))
+			group by m.month
+		)
+		select month, credit, debit, credit - debit,
+			case when credit > 0 then round((credit - debit) / credit, 4) end
+		from totals
+		order by month`
+
+	rows, err := s.db.Query(query, from, to, types.TransactionTypeCredit, types.TransactionTypeDebit, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	months := []*types.MonthlyTotal{}
+	for rows.Next() {
+		month := &types.MonthlyTotal{}
+		if err := rows.Scan(&month.Month, &month.Credit, &month.Debit, &month.Net, &month.SavingsRate); err != nil {
+			return nil, err
+		}
+		months = append(months, month)
+	}
+	return months, rows.Err()
+}
+
+func (s *PostgresStore) getCategoryReports(from, to time.Time, workspaceID *uuid.UUID) ([]*types.CategoryReport, error) {
+	query := categorySeriesQuery + `
+	select s.category_id, s.description, s.transaction_type,
+		sum(s.total), avg(s.total), percentile_cont(0.5) within group (order by s.total), min(s.total), max(s.total)
+	from series s
+	group by 1, 2, 3
+	order by s.transaction_type, sum(s.total) desc`
+
+	rows, err := s.db.Query(query, from, to, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reports := []*types.CategoryReport{}
+	byKey := map[string]*types.CategoryReport{}
+	for rows.Next() {
+		report := &types.CategoryReport{Series: []*types.MonthlyAmount{}}
+		err := rows.Scan(
+			&report.CategoryID,
+			&report.Category,
+			&report.TransactionType,
+			&report.Total,
+			&report.Average,
+			&report.Median,
+			&report.Min,
+			&report.Max)
+		if err != nil {
+			return nil, err
+		}
+		reports = append(reports, report)
+		byKey[categoryReportKey(report.CategoryID, report.TransactionType)] = report
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	seriesRows, err := s.db.Query(categorySeriesQuery+`
+	select s.category_id, s.transaction_type, s.month, s.total
+	from series s
+	order by s.month`, from, to, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	defer seriesRows.Close()
+
+	for seriesRows.Next() {
+		var categoryID uuid.UUID
+		var transactionType types.TransactionType
+		amount := &types.MonthlyAmount{}
+		if err := seriesRows.Scan(&categoryID, &transactionType, &amount.Month, &amount.Total); err != nil {
+			return nil, err
+		}
+
+		if report, ok := byKey[categoryReportKey(categoryID, transactionType)]; ok {
+			report.Series = append(report.Series, amount)
+		}
+	}
+	return reports, seriesRows.Err()
+}
+
+func categoryReportKey(categoryID uuid.UUID, transactionType types.TransactionType) string {
+	return fmt.Sprintf("%s|%s", categoryID, transactionType)
+}
+
+// GetNetWorth returns the net worth by account type at the end of each month between the
+// months, the last point never going past today. Without a workspace every account is included.
+func (s *PostgresStore) GetNetWorth(from, to, today time.Time, workspaceID *uuid.UUID) ([]*types.NetWorthPoint, error) {
+	query := `with points as (
+			select least((m + interval '1 month' - interval '1 day') :: date, $3 :: date) as point
+			from generate_series($1 :: date, $2 :: date, interval '1 month') m
+		),
+		cards as (
+			select p.point, t.account_id,
+				case when t.transaction_type = $4 then -t.amount else t.amount end as amount,
+				t.date <= (case when extract(day from p.point) < c.due_day
+					then date_trunc('month', p.point)
+					else date_trunc('month', p.point) + interval '1 month'
+				end + (c.due_day - 1) * interval '1 day') :: date as on_statement
+			from points p
+			join "transaction" t on t.archived = false
+				and least(t.created_at :: date, t.date) <= p.point
+				and (t.fulfilled = false or coalesce(t.effectuated_date, t.date) > p.point)
+			join credit_card c on c.id = t.creditcard_id
+		),
+		movements as (
+			select p.point, h.id as holding_id, h.account_id,
+				sum(case when m.kind = $5 then m.quantity else -m.quantity end) as quantity,
+				sum(case when m.kind = $5 then m.cost_basis else -m.cost_basis end) as cost_basis,
+				sum(case when m.kind = $5 then 0 else m.amount - m.cost_basis end) as realized
+			from points p
+			join holding h on true
+			join holding_movement m on m.holding_id = h.id and m.date <= p.point
+			group by p.point, h.id, h.account_id
+		),
+		gains as (
+			select mv.point, mv.account_id,
+				coalesce(mv.quantity * (
+					select hp.price from holding_price hp
+					where hp.holding_id = mv.holding_id and hp.date <= mv.point
+					order by hp.date desc limit 1) - mv.cost_basis, 0) + mv.realized as gain
+			from movements mv
+		),
+		loans as (
+			select p.point, l.account_id, l.principal - coalesce((
+				select sum(i.amortization) from loan_installment i
+				join "transaction" t on t.id = i.transaction_id
+				where i.loan_id = l.id
+					and t.fulfilled = true
+					and t.archived = false
+					and coalesce(t.effectuated_date, t.date) <= p.point), 0) as outstanding
+			from points p
+			join loan l on l.start_date <= p.point
+		)
+		select p.point, a.account_type,
+			sum((case when a.opening_date is null or a.opening_date <= p.point then a.opening_balance else 0 end) + coalesce((
+				select sum(case when t.transaction_type = $4 then t.amount else -t.amount end)
+				from "transaction" t
+				where t.account_id = a.id
+					and t.fulfilled = true
+					and t.archived = false
+					and (a.opening_date is null or coalesce(t.effectuated_date, t.date) >= a.opening_date)
+					and coalesce(t.effectuated_date, t.date) <= p.point), 0)),
+			coalesce(sum((select sum(c.amount) from cards c where c.point = p.point and c.account_id = a.id and c.on_statement)), 0),
+			coalesce(sum((select sum(c.amount) from cards c where c.point = p.point and c.account_id = a.id and not c.on_statement)), 0),
+			coalesce(sum((select sum(g.gain) from gains g where g.point = p.point and g.account_id = a.id)), 0),
+			coalesce(sum((select sum(l.outstanding) from loans l where l.point = p.point and l.account_id = a.id)), 0)
+		from points p
+		cross join account a
+		where $6 :: uuid is null or a.workspace_id = $6
+		group by p.point, a.account_type
+		order by p.point, a.account_type`
+
+	rows, err := s.db.Query(query, from, to, today, types.TransactionTypeCredit, types.HoldingMovementContribution, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	points := []*types.NetWorthPoint{}
+	var point *types.NetWorthPoint
+	for rows.Next() {
+		var date time.Time
+		byAccountType := &types.NetWorthByAccountType{}
+		err := rows.Scan(
+			&date,
+			&byAccountType.AccountType,
+			&byAccountType.Accounts,
+			&byAccountType.CreditCardStatements,
+			&byAccountType.Installments,
+			&byAccountType.InvestmentGains,
+			&byAccountType.Loans)
+		if err != nil {
+			return nil, err
+		}
+		byAccountType.NetWorth = byAccountType.Accounts - byAccountType.CreditCardStatements - byAccountType.Installments +
+			byAccountType.InvestmentGains - byAccountType.Loans
+
+		if point == nil || !point.Date.Equal(date) {
+			point = &types.NetWorthPoint{Date: date, ByAccountType: []*types.NetWorthByAccountType{}}
+			points = append(points, point)
+		}
+		point.ByAccountType = append(point.ByAccountType, byAccountType)
+		point.Accounts += byAccountType.Accounts
+		point.CreditCardStatements += byAccountType.CreditCardStatements
+		point.Installments += byAccountType.Installments
+		point.InvestmentGains += byAccountType.InvestmentGains
+		point.Loans += byAccountType.Loans
+		point.NetWorth += byAccountType.NetWorth
+	}
+	return points, rows.Err()
+}
@@ -0,0 +1,113 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/mdsavian/budget-tracker-api/internal/types"
+)
+
+// Scenario
+func (s *PostgresStore) createScenarioTable() error {
+	query := `create table if not exists "scenario" (
+		id UUID NOT NULL,
+		name varchar (100) NOT NULL,
+		description varchar (500) NOT NULL DEFAULT '',
+		items jsonb NOT NULL DEFAULT '[]',
+		created_at timestamptz NOT NULL,
+		updated_at timestamptz NOT NULL,
+
+		PRIMARY KEY ("id")
+	)`
+	_, err := s.db.Exec(query)
+	return err
+}
+
+func (s *PostgresStore) CreateScenario(scenario *types.Scenario) error {
+	items, err := json.Marshal(scenario.Items)
+	if err != nil {
+		return err
+	}
+
+	query := `insert into "scenario"
+		(id, name, description, items, created_at, updated_at, workspace_id)
+		values ($1, $2, $3, $4, $5, $6, $7)`
+
+	_, err = s.db.Exec(query, scenario.ID, scenario.Name, scenario.Description, items, scenario.CreatedAt, scenario.UpdatedAt,
+		scenario.WorkspaceID)
+	return err
+}
+
+func (s *PostgresStore) UpdateScenario(scenario *types.Scenario) error {
+	items, err := json.Marshal(scenario.Items)
+	if err != nil {
+		return err
+	}
+
+	query := `UPDATE "scenario" SET name = $1, description = $2, items = $3, updated_at = $4 WHERE id = $5`
+	_, err = s.db.Exec(query, scenario.Name, scenario.Description, items, scenario.UpdatedAt, scenario.ID)
+	return err
+}
+
+func (s *PostgresStore) DeleteScenario(id uuid.UUID) error {
+	_, err := s.db.Exec(`delete from "scenario" where id = $1`, id)
+	return err
+}
+
+func (s *PostgresStore) GetScenarioByID(id uuid.UUID) (*types.Scenario, error) {
+	rows, err := s.db.Query(`select * from "scenario" where id = $1`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		return scanIntoScenario(rows)
+	}
+
+	return nil, fmt.Errorf("scenario %v not found", id)
+}
+
+// GetScenarios returns the scenarios of the workspace, or of every workspace when workspaceID is nil
+func (s *PostgresStore) GetScenarios(workspaceID *uuid.UUID) ([]*types.Scenario, error) {
+	rows, err := s.db.Query(`select * from "scenario" s
+		where $1::uuid is null or s.workspace_id = $1
+		order by s.name`, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	scenarios := []*types.Scenario{}
+	for rows.Next() {
+		scenario, err := scanIntoScenario(rows)
+		if err != nil {
+			return nil, err
+		}
+		scenarios = append(scenarios, scenario)
+	}
+	return scenarios, nil
+}
+
+func scanIntoScenario(rows *sql.Rows) (*types.Scenario, error) {
+	scenario := &types.Scenario{}
+	var items []byte
+	err := rows.Scan(
+		&scenario.ID,
+		&scenario.Name,
+		&scenario.Description,
+		&items,
+		&scenario.CreatedAt,
+		&scenario.UpdatedAt,
+		&scenario.WorkspaceID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(items, &scenario.Items); err != nil {
+		return nil, err
+	}
+	return scenario, nil
+}
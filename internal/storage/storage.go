@@ -7,7 +7,7 @@ import (
 	"time"
 
 	"github.com/google/uuid"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 	"github.com/mdsavian/budget-tracker-api/internal/types"
 )
 
@@ -15,6 +15,12 @@ type PostgresStore struct {
 	db *sql.DB
 }
 
+// execer runs a statement on the database or inside a database transaction, for the writes
+// that are also made as part of a larger change
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
 func NewPostgresStore() (*PostgresStore, error) {
 	postgresPort := os.Getenv("DB_PORT")
 	postgresUser := os.Getenv("DB_USER")
@@ -64,10 +70,46 @@ func (s *PostgresStore) createTables() error {
 		return err
 	}
 
+	if err := s.createReconciliationTable(); err != nil {
+		return err
+	}
+
 	if err := s.createTransactionTable(); err != nil {
 		return err
 	}
 
+	if err := s.createInvestmentTables(); err != nil {
+		return err
+	}
+
+	if err := s.createLoanTables(); err != nil {
+		return err
+	}
+
+	if err := s.createCSVProfileTable(); err != nil {
+		return err
+	}
+
+	if err := s.createImportFingerprintTable(); err != nil {
+		return err
+	}
+
+	if err := s.createImportJobTable(); err != nil {
+		return err
+	}
+
+	if err := s.createScenarioTable(); err != nil {
+		return err
+	}
+
+	if err := s.createWorkspaceTables(); err != nil {
+		return err
+	}
+
+	if err := s.createAuditTable(); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -92,7 +134,8 @@ func (s *PostgresStore) createRecurringTransactionTable() error {
 		CONSTRAINT "recurring_transaction_account" FOREIGN KEY ("account_id") REFERENCES "account" ("id"),
 		CONSTRAINT "recurring_transaction_card" FOREIGN KEY ("creditcard_id") REFERENCES "credit_card" ("id"),
 		CONSTRAINT "recurring_transaction_category" FOREIGN KEY ("category_id") REFERENCES "category" ("id")
-	)`
+	);
+		ALTER TABLE "recurring_transaction" ADD COLUMN IF NOT EXISTS "archived_at" timestamptz NULL;`
 	_, err := s.db.Exec(query)
 	if err != nil {
 		return err
@@ -127,9 +170,9 @@ func (s *PostgresStore) CreateRecurringTransaction(recurringTransaction *types.R
 }
 
 func (s *PostgresStore) ArchiveRecurringTransaction(recurringTransactionID uuid.UUID) error {
-	query := `UPDATE recurring_transaction SET archived = $1 where id = $2`
+	query := `UPDATE recurring_transaction SET archived = $1, archived_at = $2 where id = $3`
 
-	conn, err := s.db.Query(query, true, recurringTransactionID)
+	conn, err := s.db.Query(query, true, time.Now().UTC(), recurringTransactionID)
 	if err != nil {
 		defer conn.Close()
 		return err
@@ -139,6 +182,12 @@ func (s *PostgresStore) ArchiveRecurringTransaction(recurringTransactionID uuid.
 	return nil
 }
 
+func (s *PostgresStore) UnarchiveRecurringTransaction(recurringTransactionID uuid.UUID) error {
+	query := `UPDATE recurring_transaction SET archived = false, archived_at = null, updated_at = $1 where id = $2`
+	_, err := s.db.Exec(query, time.Now().UTC(), recurringTransactionID)
+	return err
+}
+
 func (s *PostgresStore) UpdateRecurringTransaction(recurringTransactionID uuid.UUID, update *types.RecurringTransaction) error {
 	query := `UPDATE recurring_transaction SET 
 		account_id = COALESCE($1, account_id),
@@ -195,7 +244,8 @@ func scanIntoRecurringTransaction(rows *sql.Rows) (*types.RecurringTransaction,
 		&recurringTransaction.Amount,
 		&recurringTransaction.Archived,
 		&recurringTransaction.CreatedAt,
-		&recurringTransaction.UpdatedAt)
+		&recurringTransaction.UpdatedAt,
+		&recurringTransaction.ArchivedAt)
 
 	return recurringTransaction, err
 
@@ -224,7 +274,14 @@ func (s *PostgresStore) createTransactionTable() error {
 		CONSTRAINT "transaction_recurring" FOREIGN KEY ("recurring_transaction_id") REFERENCES "recurring_transaction" ("id")
 	);
 		ALTER TABLE "transaction" ADD COLUMN IF NOT EXISTS "effectuated_date" date;
-		ALTER TABLE "transaction" ADD COLUMN IF NOT EXISTS "archived" boolean NOT NULL DEFAULT false;`
+		ALTER TABLE "transaction" ADD COLUMN IF NOT EXISTS "archived" boolean NOT NULL DEFAULT false;
+		ALTER TABLE "transaction" ADD COLUMN IF NOT EXISTS "external_id" varchar(100);
+		CREATE UNIQUE INDEX IF NOT EXISTS "uq_transaction_external_id" ON "transaction" ("account_id", "external_id");
+		ALTER TABLE "transaction" ADD COLUMN IF NOT EXISTS "reconciliation_id" UUID NULL REFERENCES "reconciliation" ("id");
+		ALTER TABLE "transaction" ADD COLUMN IF NOT EXISTS "reconciled" boolean NOT NULL DEFAULT false;
+		ALTER TABLE "transaction" ADD COLUMN IF NOT EXISTS "archived_at" timestamptz NULL;
+		ALTER TABLE "transaction" ADD COLUMN IF NOT EXISTS "adjustment" boolean NOT NULL DEFAULT false;
+		ALTER TABLE "transaction" ADD COLUMN IF NOT EXISTS "transfer_id" UUID NULL;`
 
 	_, err := s.db.Exec(query)
 	if err != nil {
@@ -235,12 +292,16 @@ func (s *PostgresStore) createTransactionTable() error {
 }
 
 func (s *PostgresStore) CreateTransaction(transaction *types.Transaction) error {
+	return createTransaction(s.db, transaction)
+}
+
+func createTransaction(db execer, transaction *types.Transaction) error {
 	query := `insert into "transaction" 
 	(id, account_id, creditcard_id, category_id, recurring_transaction_id, transaction_type, date,effectuated_date, description, 
-		amount, fulfilled, created_at, updated_at)
-	values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`
+		amount, fulfilled, created_at, updated_at, external_id, adjustment, transfer_id)
+	values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)`
 
-	conn, err := s.db.Query(query,
+	_, err := db.Exec(query,
 		transaction.ID,
 		transaction.AccountID,
 		transaction.CreditCardID,
@@ -253,21 +314,25 @@ func (s *PostgresStore) CreateTransaction(transaction *types.Transaction) error
 		transaction.Amount,
 		transaction.Fulfilled,
 		time.Now(),
-		time.Now())
-	if err != nil {
-		return err
-	}
-
-	defer conn.Close()
-	return nil
+		time.Now(),
+		transaction.ExternalID,
+		transaction.Adjustment,
+		transaction.TransferID)
+	return err
 }
 
 func (s *PostgresStore) DeleteTransaction(transacionID uuid.UUID) error {
-	query := `UPDATE "transaction" SET archived = true, updated_at = $1 WHERE id = $2`
+	query := `UPDATE "transaction" SET archived = true, archived_at = $1, updated_at = $1 WHERE id = $2`
 	_, err := s.db.Exec(query, time.Now().UTC(), transacionID)
 	return err
 }
 
+func (s *PostgresStore) UnarchiveTransaction(transactionID uuid.UUID) error {
+	query := `UPDATE "transaction" SET archived = false, archived_at = null, updated_at = $1 WHERE id = $2`
+	_, err := s.db.Exec(query, time.Now().UTC(), transactionID)
+	return err
+}
+
 func (s *PostgresStore) FulfillTransaction(transactionID uuid.UUID) error {
 	query := `UPDATE "transaction" 
 		SET fulfilled = $1,
@@ -309,6 +374,24 @@ func (s *PostgresStore) UpdateTransaction(transactionID uuid.UUID, update *types
 	return nil
 }
 
+func (s *PostgresStore) UpdateTransactionDate(transactionID uuid.UUID, date time.Time) error {
+	query := `UPDATE "transaction" SET "date" = $1, updated_at = $2 WHERE id = $3`
+	_, err := s.db.Exec(query, date, time.Now().UTC(), transactionID)
+	return err
+}
+
+// GetUnpaidCreditCardTransactions returns the transactions of the card not paid yet and due from the date
+func (s *PostgresStore) GetUnpaidCreditCardTransactions(creditCardID uuid.UUID, from time.Time) ([]*types.Transaction, error) {
+	query := `select * from "transaction" t
+		where t.creditcard_id = $1
+			and t.fulfilled = false
+			and t.archived = false
+			and t.reconciled = false
+			and t."date" >= $2
+		order by t."date", t.created_at`
+	return s.queryTransactions(query, creditCardID, from)
+}
+
 func (s *PostgresStore) GetTransactionByID(id uuid.UUID) (*types.Transaction, error) {
 	query := "select * from transaction where id = $1"
 	rows, err := s.db.Query(query, id)
@@ -325,7 +408,92 @@ func (s *PostgresStore) GetTransactionByID(id uuid.UUID) (*types.Transaction, er
 	return nil, fmt.Errorf("transaction %v not found", id)
 }
 
-func (s *PostgresStore) GetTransactionsWithRecurringByDate(startDate, endDate time.Time) ([]*types.TransactionView, error) {
+// GetTransactionsByIDs returns the transactions with the given ids, leaving out the ones that do not exist
+func (s *PostgresStore) GetTransactionsByIDs(ids []uuid.UUID) ([]*types.Transaction, error) {
+	query := `select * from "transaction" where id = any($1::uuid[])`
+	rows, err := s.db.Query(query, pq.Array(uuidStrings(ids)))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	transactions := []*types.Transaction{}
+	for rows.Next() {
+		transaction, err := scanIntoTransaction(rows)
+		if err != nil {
+			return nil, err
+		}
+		transactions = append(transactions, transaction)
+	}
+	return transactions, nil
+}
+
+func (s *PostgresStore) ExistsTransactionByExternalID(accountID uuid.UUID, externalID string) (bool, error) {
+	query := `select exists(select 1 from "transaction" where account_id = $1 and external_id = $2)`
+
+	var exists bool
+	if err := s.db.QueryRow(query, accountID, externalID).Scan(&exists); err != nil {
+		return false, err
+	}
+
+	return exists, nil
+}
+
+// FindMatchingTransaction returns an existing transaction equal to the given one that
+// is not linked to an imported row yet, or nil when there is none
+func (s *PostgresStore) FindMatchingTransaction(transaction *types.Transaction) (*types.Transaction, error) {
+	query := `select * from "transaction" t
+		where t.account_id = $1
+			and t.creditcard_id IS NOT DISTINCT FROM $2
+			and t.transaction_type = $3
+			and t.date = $4
+			and round(t.amount, 2) = round($5::numeric, 2)
+			and t.description = $6
+			and t.archived = false
+			and not exists (select 1 from "import_fingerprint" f where f.transaction_id = t.id)
+		order by t.created_at
+		limit 1`
+
+	rows, err := s.db.Query(query,
+		transaction.AccountID,
+		transaction.CreditCardID,
+		transaction.TransactionType,
+		transaction.Date,
+		transaction.Amount,
+		transaction.Description)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		return scanIntoTransaction(rows)
+	}
+
+	return nil, nil
+}
+
+func (s *PostgresStore) GetTransactionsWithRecurringByDate(startDate, endDate time.Time, filter *types.TransactionFilter) ([]*types.TransactionView, error) {
+	transactions := []*types.TransactionView{}
+
+	err := s.StreamTransactionsByDate(startDate, endDate, true, filter, func(transaction *types.TransactionView) error {
+		transactions = append(transactions, transaction)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return transactions, nil
+}
+
+// StreamTransactionsByDate calls fn for each transaction of the period matching the filter as it is
+// read from the database, optionally including the virtual occurrences of the recurring transactions
+func (s *PostgresStore) StreamTransactionsByDate(startDate, endDate time.Time, includeRecurring bool, filter *types.TransactionFilter, fn func(*types.TransactionView) error) error {
+	if filter == nil {
+		filter = &types.TransactionFilter{}
+	}
+
 	query := `
 	WITH RECURRING_DATES AS (
 		SELECT 
@@ -345,6 +513,10 @@ func (s *PostgresStore) GetTransactionsWithRecurringByDate(startDate, endDate ti
 			recurring_transaction
 		WHERE 
 			archived = false 			
+			AND ($4::uuid IS NULL OR account_id = $4)
+			AND ($5::uuid IS NULL OR creditcard_id = $5)
+			AND ($6::uuid IS NULL OR category_id = $6)
+			AND ($7::uuid IS NULL OR account_id IN (SELECT id FROM account WHERE workspace_id = $7))
 	)
 	SELECT 
 		t.id, 
@@ -360,7 +532,9 @@ func (s *PostgresStore) GetTransactionsWithRecurringByDate(startDate, endDate ti
 		t.effectuated_date,
 		t.description, 
 		t.amount, 
-		t.fulfilled
+		t.fulfilled,
+		t.adjustment,
+		t.transfer_id
 	FROM 
 		transaction t
 	LEFT JOIN 
@@ -373,6 +547,10 @@ func (s *PostgresStore) GetTransactionsWithRecurringByDate(startDate, endDate ti
 		((t.effectuated_date IS NOT NULL AND t.effectuated_date BETWEEN $1 AND $2)
 		OR (t.date BETWEEN $1 AND $2))
 		AND t.archived = false
+		AND ($4::uuid IS NULL OR t.account_id = $4)
+		AND ($5::uuid IS NULL OR t.creditcard_id = $5)
+		AND ($6::uuid IS NULL OR t.category_id = $6)
+		AND ($7::uuid IS NULL OR a.workspace_id = $7)
 	UNION ALL
 	SELECT 
 		NULL AS id,
@@ -388,7 +566,9 @@ func (s *PostgresStore) GetTransactionsWithRecurringByDate(startDate, endDate ti
 		NULL as effectuated_date,
 		r.description, 
 		r.amount, 
-		false AS fulfilled
+		false AS fulfilled,
+		false AS adjustment,
+		NULL AS transfer_id
 	FROM 
 		RECURRING_DATES r
 	LEFT JOIN 
@@ -405,26 +585,27 @@ func (s *PostgresStore) GetTransactionsWithRecurringByDate(startDate, endDate ti
 		account a ON a.id = r.account_id
 	WHERE 
 		t.id IS NULL
+		AND $3::boolean
 	ORDER BY 
 		date desc;`
 
-	rows, err := s.db.Query(query, startDate, endDate)
+	rows, err := s.db.Query(query, startDate, endDate, includeRecurring, filter.AccountID, filter.CreditCardID, filter.CategoryID,
+		filter.WorkspaceID)
 	if err != nil {
-		defer rows.Close()
-		return nil, err
+		return err
 	}
 	defer rows.Close()
 
-	transactions := []*types.TransactionView{}
-
 	for rows.Next() {
 		transaction, err := scanIntoTransactionView(rows)
 		if err != nil {
-			return nil, err
+			return err
+		}
+		if err := fn(transaction); err != nil {
+			return err
 		}
-		transactions = append(transactions, transaction)
 	}
-	return transactions, nil
+	return rows.Err()
 }
 
 func scanIntoTransactionView(rows *sql.Rows) (*types.TransactionView, error) {
@@ -444,6 +625,8 @@ func scanIntoTransactionView(rows *sql.Rows) (*types.TransactionView, error) {
 		&transaction.Description,
 		&transaction.Amount,
 		&transaction.Fulfilled,
+		&transaction.Adjustment,
+		&transaction.TransferID,
 	)
 	return transaction, err
 }
@@ -464,7 +647,13 @@ func scanIntoTransaction(rows *sql.Rows) (*types.Transaction, error) {
 		&transaction.CreatedAt,
 		&transaction.UpdatedAt,
 		&transaction.EffectuatedDate,
-		&transaction.Archived)
+		&transaction.Archived,
+		&transaction.ExternalID,
+		&transaction.ReconciliationID,
+		&transaction.Reconciled,
+		&transaction.ArchivedAt,
+		&transaction.Adjustment,
+		&transaction.TransferID)
 
 	return transaction, err
 }
@@ -481,17 +670,19 @@ func (s *PostgresStore) createCreditCardTable() error {
 				updated_at timestamptz NOT NULL, 
 				CONSTRAINT uc_name UNIQUE(name),
 				PRIMARY KEY ("id")
-	)`
+	);
+		ALTER TABLE "credit_card" ADD COLUMN IF NOT EXISTS "archived_at" timestamptz NULL;`
 	_, err := s.db.Exec(query)
 	return err
 }
 
 func (s *PostgresStore) CreateCreditCard(creditCard *types.CreditCard) error {
 	query := `insert into "credit_card" 
-	(id, name, due_day, closing_day, created_at, updated_at)
-	values ($1, $2, $3, $4, $5, $6)`
+	(id, name, due_day, closing_day, created_at, updated_at, workspace_id)
+	values ($1, $2, $3, $4, $5, $6, $7)`
 
-	conn, err := s.db.Query(query, creditCard.ID, creditCard.Name, creditCard.DueDay, creditCard.ClosingDay, creditCard.CreatedAt, creditCard.UpdatedAt)
+	conn, err := s.db.Query(query, creditCard.ID, creditCard.Name, creditCard.DueDay, creditCard.ClosingDay, creditCard.CreatedAt, creditCard.UpdatedAt,
+		creditCard.WorkspaceID)
 	if err != nil {
 		defer conn.Close()
 		return err
@@ -555,14 +746,16 @@ func scanIntoCreditCard(rows *sql.Rows) (*types.CreditCard, error) {
 		&card.DueDay,
 		&card.ClosingDay,
 		&card.CreatedAt,
-		&card.UpdatedAt)
+		&card.UpdatedAt,
+		&card.ArchivedAt,
+		&card.WorkspaceID)
 
 	return card, err
 }
 
 func (s *PostgresStore) ArchiveCreditCard(creditCardID uuid.UUID) error {
-	query := `UPDATE credit_card SET archived = $1 where id = $2`
-	conn, err := s.db.Query(query, true, creditCardID)
+	query := `UPDATE credit_card SET archived = $1, archived_at = $2 where id = $3`
+	conn, err := s.db.Query(query, true, time.Now().UTC(), creditCardID)
 	if err != nil {
 		defer conn.Close()
 		return err
@@ -572,6 +765,18 @@ func (s *PostgresStore) ArchiveCreditCard(creditCardID uuid.UUID) error {
 	return nil
 }
 
+func (s *PostgresStore) UnarchiveCreditCard(creditCardID uuid.UUID) error {
+	query := `UPDATE credit_card SET archived = false, archived_at = null, updated_at = $1 where id = $2`
+	_, err := s.db.Exec(query, time.Now().UTC(), creditCardID)
+	return err
+}
+
+func (s *PostgresStore) UpdateCreditCard(creditCard *types.CreditCard) error {
+	query := `UPDATE credit_card SET name = $1, due_day = $2, closing_day = $3, updated_at = $4 where id = $5`
+	_, err := s.db.Exec(query, creditCard.Name, creditCard.DueDay, creditCard.ClosingDay, creditCard.UpdatedAt, creditCard.ID)
+	return err
+}
+
 // Category
 func (s *PostgresStore) CreateCategoryTable() error {
 	query := `create table if not exists "category" (
@@ -582,7 +787,8 @@ func (s *PostgresStore) CreateCategoryTable() error {
 				updated_at timestamptz NOT NULL, 
 				CONSTRAINT uc_description UNIQUE(description),
 				PRIMARY KEY ("id")
-	)`
+	);
+		ALTER TABLE "category" ADD COLUMN IF NOT EXISTS "archived_at" timestamptz NULL;`
 	_, err := s.db.Exec(query)
 	if err != nil {
 		return err
@@ -593,10 +799,10 @@ func (s *PostgresStore) CreateCategoryTable() error {
 
 func (s *PostgresStore) CreateCategory(category *types.Category) error {
 	query := `insert into "category" 
-	(id, description, created_at, updated_at)
-	values ($1, $2, $3, $4)`
+	(id, description, created_at, updated_at, workspace_id)
+	values ($1, $2, $3, $4, $5)`
 
-	conn, err := s.db.Query(query, category.ID, category.Description, category.CreatedAt, category.UpdatedAt)
+	conn, err := s.db.Query(query, category.ID, category.Description, category.CreatedAt, category.UpdatedAt, category.WorkspaceID)
 	if err != nil {
 		defer conn.Close()
 		return err
@@ -616,7 +822,9 @@ func (s *PostgresStore) GetCategoryByDescription(description string) (*types.Cat
 		&category.Description,
 		&category.Archived,
 		&category.CreatedAt,
-		&category.UpdatedAt)
+		&category.UpdatedAt,
+		&category.ArchivedAt,
+		&category.WorkspaceID)
 	if err != nil {
 		return nil, err
 	}
@@ -663,14 +871,16 @@ func scanIntoCategory(rows *sql.Rows) (*types.Category, error) {
 		&category.Description,
 		&category.Archived,
 		&category.CreatedAt,
-		&category.UpdatedAt)
+		&category.UpdatedAt,
+		&category.ArchivedAt,
+		&category.WorkspaceID)
 
 	return category, err
 }
 
 func (s *PostgresStore) ArchiveCategory(categoryID uuid.UUID) error {
-	query := `UPDATE category SET archived = $1 where id = $2`
-	conn, err := s.db.Query(query, true, categoryID)
+	query := `UPDATE category SET archived = $1, archived_at = $2 where id = $3`
+	conn, err := s.db.Query(query, true, time.Now().UTC(), categoryID)
 	if err != nil {
 		defer conn.Close()
 		return err
@@ -680,6 +890,18 @@ func (s *PostgresStore) ArchiveCategory(categoryID uuid.UUID) error {
 	return nil
 }
 
+func (s *PostgresStore) UnarchiveCategory(categoryID uuid.UUID) error {
+	query := `UPDATE category SET archived = false, archived_at = null, updated_at = $1 where id = $2`
+	_, err := s.db.Exec(query, time.Now().UTC(), categoryID)
+	return err
+}
+
+func (s *PostgresStore) UpdateCategory(category *types.Category) error {
+	query := `UPDATE category SET description = $1, updated_at = $2 where id = $3`
+	_, err := s.db.Exec(query, category.Description, category.UpdatedAt, category.ID)
+	return err
+}
+
 // Session
 func (s *PostgresStore) createSessionTable() error {
 	query := `create table if not exists "session" (
@@ -767,7 +989,12 @@ func (s *PostgresStore) createUserTable() error {
 				name varchar (200) NOT NULL, 
 				email varchar (200) NOT NULL, 
 				password varchar NOT NULL
-				)`
+				);
+
+	ALTER TABLE "user" ADD COLUMN IF NOT EXISTS "admin" boolean NOT NULL DEFAULT false;
+	UPDATE "user" SET admin = true
+		WHERE id = (select u.id from "user" u order by u.created_at limit 1)
+			AND NOT EXISTS (select 1 from "user" u where u.admin)`
 	_, err := s.db.Exec(query)
 	if err != nil {
 		return err
@@ -776,19 +1003,14 @@ func (s *PostgresStore) createUserTable() error {
 	return nil
 }
 
+// CreateUser makes the first user of the installation its administrator
 func (s *PostgresStore) CreateUser(user *types.User) error {
 	query := `insert into "user" 
-	(id, name, email, password, created_at, updated_at)
-	values ($1, $2, $3, $4, $5, $6)`
-
-	conn, err := s.db.Query(query, user.ID, user.Name, user.Email, user.EncryptedPassword, user.CreatedAt, user.UpdatedAt)
-	if err != nil {
-		defer conn.Close()
-		return err
-	}
+	(id, name, email, password, created_at, updated_at, admin)
+	values ($1, $2, $3, $4, $5, $6, not exists (select 1 from "user" u where u.admin))
+	returning admin`
 
-	defer conn.Close()
-	return nil
+	return s.db.QueryRow(query, user.ID, user.Name, user.Email, user.EncryptedPassword, user.CreatedAt, user.UpdatedAt).Scan(&user.Admin)
 }
 
 func (s *PostgresStore) DeleteUser(id uuid.UUID) error {
@@ -840,7 +1062,8 @@ func scanIntoUser(rows *sql.Rows) (*types.User, error) {
 		&user.UpdatedAt,
 		&user.Name,
 		&user.Email,
-		&user.EncryptedPassword)
+		&user.EncryptedPassword,
+		&user.Admin)
 
 	return user, err
 }
@@ -855,7 +1078,14 @@ func (s *PostgresStore) createAccountTable() error {
 				name varchar (200) NOT NULL, 
 				account_type varchar (50) NOT NULL,
 				CONSTRAINT "uq_name_type" UNIQUE(name, account_type)
-				)`
+				);
+				ALTER TABLE account ADD COLUMN IF NOT EXISTS "opening_balance" numeric NOT NULL DEFAULT 0;
+				ALTER TABLE account ADD COLUMN IF NOT EXISTS "owner" varchar (20) NULL;
+				UPDATE account SET owner = case when account_type = 'Conta PJ' then 'business' else 'personal' end WHERE owner IS NULL;
+				ALTER TABLE account ALTER COLUMN "owner" SET NOT NULL;
+				ALTER TABLE account ADD COLUMN IF NOT EXISTS "kind" varchar (20) NOT NULL DEFAULT 'checking';
+				ALTER TABLE account ADD COLUMN IF NOT EXISTS "yield_rate" numeric NOT NULL DEFAULT 0;
+				ALTER TABLE account ADD COLUMN IF NOT EXISTS "opening_date" date NULL;`
 	_, err := s.db.Exec(query)
 	if err != nil {
 		return err
@@ -864,48 +1094,30 @@ func (s *PostgresStore) createAccountTable() error {
 	return nil
 }
 
-func (s *PostgresStore) UpdateAccountBalance(accountID uuid.UUID, amount float32, transactionType types.TransactionType) error {
-	var balance float32
-	var newBalance float32
-
-	query := "select balance from account where id = $1"
-	rows, err := s.db.Query(query, accountID)
-	if err != nil {
-		defer rows.Close()
-		return err
-	}
-	defer rows.Close()
-
-	for rows.Next() {
-		err := rows.Scan(&balance)
-		if err != nil {
-			return err
-		}
-	}
+// UpdateAccountBalance applies a transaction effective on date to the stored balance in a single statement,
+// so concurrent updates can't overwrite each other. Transactions before the opening date leave it unchanged.
+func (s *PostgresStore) UpdateAccountBalance(accountID uuid.UUID, amount float32, transactionType types.TransactionType, date time.Time) error {
+	return updateAccountBalance(s.db, accountID, amount, transactionType, date)
+}
 
-	if transactionType == types.TransactionTypeCredit {
-		newBalance = balance + amount
-	} else {
-		newBalance = balance - amount
+func updateAccountBalance(db execer, accountID uuid.UUID, amount float32, transactionType types.TransactionType, date time.Time) error {
+	if transactionType != types.TransactionTypeCredit {
+		amount = -amount
 	}
 
-	query = `update account set balance = $1 where id = $2`
-	conn, err := s.db.Query(query, newBalance, accountID)
-	if err != nil {
-		defer conn.Close()
-		return err
-	}
-	defer conn.Close()
-
-	return nil
+	query := `update account set balance = balance + $1, updated_at = $2
+		where id = $3 and (opening_date is null or opening_date <= $4 :: date)`
+	_, err := db.Exec(query, amount, time.Now().UTC(), accountID, date)
+	return err
 }
 
 func (s *PostgresStore) CreateAccount(acc *types.Account) error {
 	query := `insert into account 
-	(id, name, account_type, balance, created_at, updated_at)
-	values ($1, $2, $3, $4, $5, $6)`
+	(id, name, account_type, balance, opening_balance, owner, kind, yield_rate, opening_date, created_at, updated_at, workspace_id)
+	values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`
 
-	conn, err := s.db.Query(query, acc.ID, acc.Name, acc.AccountType, acc.Balance, acc.CreatedAt, acc.UpdatedAt)
+	conn, err := s.db.Query(query, acc.ID, acc.Name, acc.AccountType, acc.Balance, acc.OpeningBalance,
+		acc.Owner, acc.Kind, acc.YieldRate, acc.OpeningDate, acc.CreatedAt, acc.UpdatedAt, acc.WorkspaceID)
 	if err != nil {
 		defer conn.Close()
 		return err
@@ -915,6 +1127,12 @@ func (s *PostgresStore) CreateAccount(acc *types.Account) error {
 	return nil
 }
 
+func (s *PostgresStore) UpdateAccount(acc *types.Account) error {
+	query := `update account set name = $1, account_type = $2, owner = $3, kind = $4, yield_rate = $5, updated_at = $6 where id = $7`
+	_, err := s.db.Exec(query, acc.Name, acc.AccountType, acc.Owner, acc.Kind, acc.YieldRate, acc.UpdatedAt, acc.ID)
+	return err
+}
+
 func (s *PostgresStore) DeleteAccount(id uuid.UUID) error {
 	query := "delete from account where id = $1"
 
@@ -954,7 +1172,13 @@ func (s *PostgresStore) GetUniqueAccount(name string, accountType types.AccountT
 		&account.UpdatedAt,
 		&account.Balance,
 		&account.Name,
-		&account.AccountType)
+		&account.AccountType,
+		&account.OpeningBalance,
+		&account.Owner,
+		&account.Kind,
+		&account.YieldRate,
+		&account.OpeningDate,
+		&account.WorkspaceID)
 
 	if err != nil {
 		return nil, err
@@ -989,6 +1213,12 @@ func scanIntoAccount(rows *sql.Rows) (*types.Account, error) {
 		&account.UpdatedAt,
 		&account.Balance,
 		&account.Name,
-		&account.AccountType)
+		&account.AccountType,
+		&account.OpeningBalance,
+		&account.Owner,
+		&account.Kind,
+		&account.YieldRate,
+		&account.OpeningDate,
+		&account.WorkspaceID)
 	return account, err
 }
@@ -0,0 +1,57 @@
+package storage
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mdsavian/budget-tracker-api/internal/types"
+)
+
+// GetTrash lists the records archived since the date, latest first. Records archived before
+// archived_at existed use their last update instead. Without a workspace every record is listed.
+func (s *PostgresStore) GetTrash(since time.Time, workspaceID *uuid.UUID) ([]*types.TrashItem, error) {
+	query := `select * from (
+			select $2::varchar as entity, t.id, t.description, t.amount, t."date", coalesce(t.archived_at, t.updated_at) as archived_at
+			from "transaction" t
+			where t.archived = true
+				and ($6::uuid is null or t.account_id in (select a.id from account a where a.workspace_id = $6))
+			union all
+			select $3::varchar, r.id, r.description, r.amount, null, coalesce(r.archived_at, r.updated_at)
+			from recurring_transaction r
+			where r.archived = true
+				and ($6::uuid is null or r.account_id in (select a.id from account a where a.workspace_id = $6))
+			union all
+			select $4::varchar, c.id, c.description, null, null, coalesce(c.archived_at, c.updated_at)
+			from category c
+			where c.archived = true
+				and ($6::uuid is null or c.workspace_id = $6)
+			union all
+			select $5::varchar, cc.id, cc.name, null, null, coalesce(cc.archived_at, cc.updated_at)
+			from credit_card cc
+			where cc.archived = true
+				and ($6::uuid is null or cc.workspace_id = $6)
+		) trash
+		where trash.archived_at >= $1
+		order by trash.archived_at desc`
+
+	rows, err := s.db.Query(query, since,
+		types.AuditEntityTransaction,
+		types.AuditEntityRecurringTransaction,
+		types.AuditEntityCategory,
+		types.AuditEntityCreditCard,
+		workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []*types.TrashItem{}
+	for rows.Next() {
+		item := &types.TrashItem{}
+		if err := rows.Scan(&item.Entity, &item.ID, &item.Description, &item.Amount, &item.Date, &item.ArchivedAt); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
@@ -0,0 +1,245 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/mdsavian/budget-tracker-api/internal/types"
+)
+
+// workspaceResourceQueries find the workspace of a record, directly or through its account
+var workspaceResourceQueries = map[string]string{
+	"account":               `select workspace_id from account where id = $1`,
+	"category":              `select workspace_id from category where id = $1`,
+	"credit_card":           `select workspace_id from credit_card where id = $1`,
+	"transaction":           `select a.workspace_id from "transaction" t join account a on a.id = t.account_id where t.id = $1`,
+	"recurring_transaction": `select a.workspace_id from recurring_transaction r join account a on a.id = r.account_id where r.id = $1`,
+	"reconciliation":        `select a.workspace_id from reconciliation r join account a on a.id = r.account_id where r.id = $1`,
+	"holding":               `select a.workspace_id from holding h join account a on a.id = h.account_id where h.id = $1`,
+	"loan":                  `select a.workspace_id from loan l join account a on a.id = l.account_id where l.id = $1`,
+	"loan_installment": `select a.workspace_id from loan_installment i join loan l on l.id = i.loan_id
+		join account a on a.id = l.account_id where i.id = $1`,
+	"scenario":    `select workspace_id from scenario where id = $1`,
+	"import_job":  `select workspace_id from import_job where id = $1`,
+	"csv_profile": `select workspace_id from csv_profile where id = $1`,
+}
+
+// Workspace
+// The data from before workspaces goes to a first workspace owned by every user.
+func (s *PostgresStore) createWorkspaceTables() error {
+	query := `create table if not exists "workspace" (
+		id UUID NOT NULL,
+		name varchar (100) NOT NULL,
+		created_at timestamptz NOT NULL,
+		updated_at timestamptz NOT NULL,
+
+		PRIMARY KEY ("id")
+	);
+
+	create table if not exists "workspace_member" (
+		id UUID NOT NULL,
+		workspace_id UUID NOT NULL,
+		user_id UUID NULL,
+		email varchar (200) NOT NULL,
+		role varchar (20) NOT NULL,
+		created_at timestamptz NOT NULL,
+		updated_at timestamptz NOT NULL,
+
+		PRIMARY KEY ("id"),
+		CONSTRAINT uc_workspace_member_email UNIQUE(workspace_id, email),
+		CONSTRAINT "workspace_member_workspace" FOREIGN KEY ("workspace_id") REFERENCES "workspace" ("id"),
+		CONSTRAINT "workspace_member_user" FOREIGN KEY ("user_id") REFERENCES "user" ("id")
+	);
+
+	ALTER TABLE account ADD COLUMN IF NOT EXISTS "workspace_id" UUID NULL REFERENCES "workspace" ("id");
+	ALTER TABLE category ADD COLUMN IF NOT EXISTS "workspace_id" UUID NULL REFERENCES "workspace" ("id");
+	ALTER TABLE credit_card ADD COLUMN IF NOT EXISTS "workspace_id" UUID NULL REFERENCES "workspace" ("id");
+	ALTER TABLE scenario ADD COLUMN IF NOT EXISTS "workspace_id" UUID NULL REFERENCES "workspace" ("id");
+	ALTER TABLE import_job ADD COLUMN IF NOT EXISTS "workspace_id" UUID NULL REFERENCES "workspace" ("id");
+	ALTER TABLE csv_profile ADD COLUMN IF NOT EXISTS "workspace_id" UUID NULL REFERENCES "workspace" ("id");
+
+	with created as (
+		insert into workspace (id, name, created_at, updated_at)
+		select gen_random_uuid(), 'Household', now(), now()
+		where not exists (select 1 from workspace)
+			and (exists (select 1 from account) or exists (select 1 from category) or exists (select 1 from credit_card)
+				or exists (select 1 from scenario) or exists (select 1 from import_job))
+		returning id
+	)
+	insert into workspace_member (id, workspace_id, user_id, email, role, created_at, updated_at)
+	select gen_random_uuid(), c.id, u.id, lower(u.email), 'owner', now(), now()
+	from created c
+	cross join "user" u;
+
+	UPDATE account SET workspace_id = (select id from workspace order by created_at limit 1) WHERE workspace_id IS NULL;
+	UPDATE category SET workspace_id = (select id from workspace order by created_at limit 1) WHERE workspace_id IS NULL;
+	UPDATE credit_card SET workspace_id = (select id from workspace order by created_at limit 1) WHERE workspace_id IS NULL;
+	UPDATE scenario SET workspace_id = (select id from workspace order by created_at limit 1) WHERE workspace_id IS NULL;
+	UPDATE import_job SET workspace_id = (select id from workspace order by created_at limit 1) WHERE workspace_id IS NULL;
+	UPDATE csv_profile p SET workspace_id = (select a.workspace_id from account a where a.id = p.account_id) WHERE p.workspace_id IS NULL;
+	ALTER TABLE account ALTER COLUMN "workspace_id" SET NOT NULL;
+	ALTER TABLE category ALTER COLUMN "workspace_id" SET NOT NULL;
+	ALTER TABLE credit_card ALTER COLUMN "workspace_id" SET NOT NULL;
+	ALTER TABLE scenario ALTER COLUMN "workspace_id" SET NOT NULL;
+	ALTER TABLE import_job ALTER COLUMN "workspace_id" SET NOT NULL;
+	ALTER TABLE csv_profile ALTER COLUMN "workspace_id" SET NOT NULL;
+
+	ALTER TABLE account DROP CONSTRAINT IF EXISTS "uq_name_type";
+	CREATE UNIQUE INDEX IF NOT EXISTS "uq_account_workspace_name_type" ON account ("workspace_id", "name", "account_type");
+	ALTER TABLE category DROP CONSTRAINT IF EXISTS "uc_description";
+	CREATE UNIQUE INDEX IF NOT EXISTS "uq_category_workspace_description" ON category ("workspace_id", "description");
+	ALTER TABLE credit_card DROP CONSTRAINT IF EXISTS "uc_name";
+	CREATE UNIQUE INDEX IF NOT EXISTS "uq_credit_card_workspace_name" ON credit_card ("workspace_id", "name");
+	ALTER TABLE csv_profile DROP CONSTRAINT IF EXISTS "uc_csv_profile_name";
+	CREATE UNIQUE INDEX IF NOT EXISTS "uq_csv_profile_workspace_name" ON csv_profile ("workspace_id", "name");`
+	_, err := s.db.Exec(query)
+	return err
+}
+
+func (s *PostgresStore) CreateWorkspace(workspace *types.Workspace) error {
+	query := `insert into "workspace" (id, name, created_at, updated_at) values ($1, $2, $3, $4)`
+	_, err := s.db.Exec(query, workspace.ID, workspace.Name, workspace.CreatedAt, workspace.UpdatedAt)
+	return err
+}
+
+func (s *PostgresStore) GetWorkspaces() ([]*types.Workspace, error) {
+	rows, err := s.db.Query(`select * from "workspace" w order by w.created_at`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	workspaces := []*types.Workspace{}
+	for rows.Next() {
+		workspace := &types.Workspace{}
+		if err := rows.Scan(&workspace.ID, &workspace.Name, &workspace.CreatedAt, &workspace.UpdatedAt); err != nil {
+			return nil, err
+		}
+		workspaces = append(workspaces, workspace)
+	}
+	return workspaces, rows.Err()
+}
+
+// GetUserWorkspaces returns the workspaces the user is a member of with the user's role,
+// in the order the user joined them
+func (s *PostgresStore) GetUserWorkspaces(userID uuid.UUID) ([]*types.UserWorkspace, error) {
+	rows, err := s.db.Query(`select w.*, m.role
+		from workspace_member m
+		join "workspace" w on w.id = m.workspace_id
+		where m.user_id = $1
+		order by m.created_at, w.name`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	workspaces := []*types.UserWorkspace{}
+	for rows.Next() {
+		workspace := &types.UserWorkspace{Workspace: &types.Workspace{}}
+		err := rows.Scan(&workspace.ID, &workspace.Name, &workspace.CreatedAt, &workspace.UpdatedAt, &workspace.Role)
+		if err != nil {
+			return nil, err
+		}
+		workspaces = append(workspaces, workspace)
+	}
+	return workspaces, rows.Err()
+}
+
+// GetResourceWorkspaceID returns the workspace of the record with the id in the table
+func (s *PostgresStore) GetResourceWorkspaceID(table string, id uuid.UUID) (uuid.UUID, error) {
+	query, ok := workspaceResourceQueries[table]
+	if !ok {
+		return uuid.Nil, fmt.Errorf("%s records don't belong to a workspace", table)
+	}
+
+	var workspaceID uuid.UUID
+	err := s.db.QueryRow(query, id).Scan(&workspaceID)
+	if err == sql.ErrNoRows {
+		return uuid.Nil, fmt.Errorf("%s %v not found", strings.ReplaceAll(table, "_", " "), id)
+	}
+	return workspaceID, err
+}
+
+func (s *PostgresStore) CreateWorkspaceMember(member *types.WorkspaceMember) error {
+	query := `insert into "workspace_member"
+		(id, workspace_id, user_id, email, role, created_at, updated_at)
+		values ($1, $2, $3, $4, $5, $6, $7)`
+
+	_, err := s.db.Exec(query,
+		member.ID,
+		member.WorkspaceID,
+		member.UserID,
+		member.Email,
+		member.Role,
+		member.CreatedAt,
+		member.UpdatedAt)
+	return err
+}
+
+func (s *PostgresStore) UpdateWorkspaceMember(member *types.WorkspaceMember) error {
+	query := `UPDATE "workspace_member" SET role = $1, updated_at = $2 WHERE id = $3`
+	_, err := s.db.Exec(query, member.Role, member.UpdatedAt, member.ID)
+	return err
+}
+
+func (s *PostgresStore) DeleteWorkspaceMember(id uuid.UUID) error {
+	_, err := s.db.Exec(`delete from "workspace_member" where id = $1`, id)
+	return err
+}
+
+func (s *PostgresStore) GetWorkspaceMemberByID(id uuid.UUID) (*types.WorkspaceMember, error) {
+	rows, err := s.db.Query(`select * from "workspace_member" where id = $1`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		return scanIntoWorkspaceMember(rows)
+	}
+
+	return nil, fmt.Errorf("workspace member %v not found", id)
+}
+
+// GetWorkspaceMembers returns the members of the workspace, or of every workspace when workspaceID is nil
+func (s *PostgresStore) GetWorkspaceMembers(workspaceID *uuid.UUID) ([]*types.WorkspaceMember, error) {
+	rows, err := s.db.Query(`select * from "workspace_member" m
+		where $1::uuid is null or m.workspace_id = $1
+		order by m.created_at`, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := []*types.WorkspaceMember{}
+	for rows.Next() {
+		member, err := scanIntoWorkspaceMember(rows)
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, member)
+	}
+	return members, rows.Err()
+}
+
+// AcceptWorkspaceInvites links the invitations sent to the user's email to the user
+func (s *PostgresStore) AcceptWorkspaceInvites(user *types.User) error {
+	query := `UPDATE "workspace_member" SET user_id = $1, updated_at = now() WHERE user_id IS NULL AND email = lower($2)`
+	_, err := s.db.Exec(query, user.ID, user.Email)
+	return err
+}
+
+func scanIntoWorkspaceMember(rows *sql.Rows) (*types.WorkspaceMember, error) {
+	member := &types.WorkspaceMember{}
+	err := rows.Scan(
+		&member.ID,
+		&member.WorkspaceID,
+		&member.UserID,
+		&member.Email,
+		&member.Role,
+		&member.CreatedAt,
+		&member.UpdatedAt)
+	return member, err
+}
@@ -1,6 +1,7 @@
 package types
 
 import (
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
@@ -31,6 +32,8 @@ type User struct {
 	EncryptedPassword string    `json:"-"`
 	CreatedAt         time.Time `json:"created_at"`
 	UpdatedAt         time.Time `json:"updated_at"`
+	// Admin users manage the whole installation: users, backup, restore and the audit log
+	Admin bool `json:"admin"`
 }
 
 func (u *User) ValidPassword(password string) bool {
@@ -48,13 +51,105 @@ func (at AccountType) String() string {
 	return string(at)
 }
 
+// Owner returns the owner the legacy account type stood for
+func (at AccountType) Owner() AccountOwner {
+	if at == AccountTypeBusiness {
+		return AccountOwnerBusiness
+	}
+	return AccountOwnerPersonal
+}
+
+type AccountOwner string
+
+const (
+	AccountOwnerBusiness AccountOwner = "business"
+	AccountOwnerPersonal AccountOwner = "personal"
+)
+
+func (o AccountOwner) Valid() bool {
+	return o == AccountOwnerBusiness || o == AccountOwnerPersonal
+}
+
+// AccountType returns the legacy account type label of the owner
+func (o AccountOwner) AccountType() AccountType {
+	if o == AccountOwnerBusiness {
+		return AccountTypeBusiness
+	}
+	return AccountTypePersonal
+}
+
+type AccountKind string
+
+const (
+	AccountKindChecking   AccountKind = "checking"
+	AccountKindSavings    AccountKind = "savings"
+	AccountKindCash       AccountKind = "cash"
+	AccountKindInvestment AccountKind = "investment"
+	AccountKindWallet     AccountKind = "wallet"
+	AccountKindLoan       AccountKind = "loan"
+)
+
+var AccountKinds = []AccountKind{
+	AccountKindChecking,
+	AccountKindSavings,
+	AccountKindCash,
+	AccountKindInvestment,
+	AccountKindWallet,
+	AccountKindLoan,
+}
+
+func (k AccountKind) Valid() bool {
+	for _, kind := range AccountKinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// Reconcilable tells if the account has statements to reconcile against, cash has none
+func (k AccountKind) Reconcilable() bool {
+	return k != AccountKindCash
+}
+
+// Yields tells if the balance earns the account yield rate
+func (k AccountKind) Yields() bool {
+	return k == AccountKindSavings
+}
+
+// PaysCreditCards tells if card statements can be paid from the account
+func (k AccountKind) PaysCreditCards() bool {
+	return k == AccountKindChecking || k == AccountKindWallet
+}
+
 type Account struct {
-	ID          uuid.UUID   `json:"id"`
-	Name        string      `json:"name"`
-	Balance     float32     `json:"balance"`
-	AccountType AccountType `json:"account_type"`
-	CreatedAt   time.Time   `json:"created_at"`
-	UpdatedAt   time.Time   `json:"updated_at"`
+	ID   uuid.UUID `json:"id"`
+	Name string    `json:"name"`
+	// Balance is a snapshot kept up to date by the handlers, it should always be equal
+	// to the opening balance plus the fulfilled transactions of the account
+	Balance        float32 `json:"balance"`
+	OpeningBalance float32 `json:"opening_balance"`
+	// OpeningDate is when the opening balance was taken, balances before it start from zero
+	OpeningDate *time.Time `json:"opening_date"`
+	// AccountType is the legacy label of the owner, kept in sync with it
+	AccountType AccountType  `json:"account_type"`
+	Owner       AccountOwner `json:"owner"`
+	Kind        AccountKind  `json:"kind"`
+	// YieldRate is the annual rate in percent earned by the kinds that yield
+	YieldRate   float32   `json:"yield_rate"`
+	WorkspaceID uuid.UUID `json:"workspace_id"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// AccountBalanceAudit compares the stored balance of an account with the one computed from its transactions
+type AccountBalanceAudit struct {
+	AccountID       uuid.UUID `json:"accountId"`
+	Account         string    `json:"account"`
+	OpeningBalance  float32   `json:"openingBalance"`
+	StoredBalance   float32   `json:"storedBalance"`
+	ComputedBalance float32   `json:"computedBalance"`
+	Drift           float32   `json:"drift"`
 }
 
 type Category struct {
@@ -63,16 +158,30 @@ type Category struct {
 	Archived    bool      `json:"archived"`
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
+	// ArchivedAt is nil for items archived before it was recorded
+	ArchivedAt  *time.Time `json:"archived_at"`
+	WorkspaceID uuid.UUID  `json:"workspace_id"`
 }
 
 type CreditCard struct {
-	ID         uuid.UUID `json:"id"`
-	Name       string    `json:"name"`
-	Archived   bool      `json:"archived"`
-	DueDay     int       `json:"dueDay"`
-	ClosingDay int       `json:"closingDay"`
-	CreatedAt  time.Time `json:"createdAt"`
-	UpdatedAt  time.Time `json:"updatedAt"`
+	ID          uuid.UUID  `json:"id"`
+	Name        string     `json:"name"`
+	Archived    bool       `json:"archived"`
+	DueDay      int        `json:"dueDay"`
+	ClosingDay  int        `json:"closingDay"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	UpdatedAt   time.Time  `json:"updatedAt"`
+	ArchivedAt  *time.Time `json:"archivedAt"`
+	WorkspaceID uuid.UUID  `json:"workspaceId"`
+}
+
+// DueDateFor returns the statement due date of a purchase made on the given date
+func (c *CreditCard) DueDateFor(date time.Time) time.Time {
+	debitDayToAdd := c.DueDay - date.Day()
+	if date.Day() < c.ClosingDay {
+		return date.AddDate(0, 0, debitDayToAdd)
+	}
+	return date.AddDate(0, 1, debitDayToAdd)
 }
 
 type TransactionType string
@@ -100,9 +209,29 @@ type Transaction struct {
 	Amount          float32         `json:"amount"`
 	Fulfilled       bool            `json:"fulfilled"`
 	Archived        bool            `json:"archived"`
+	ExternalID      *string         `json:"externalId"`
 
-	CreatedAt time.Time `json:"createdAt"`
-	UpdatedAt time.Time `json:"updatedAt"`
+	// ReconciliationID is set when the transaction is cleared in a reconciliation,
+	// Reconciled once that reconciliation is completed
+	ReconciliationID *uuid.UUID `json:"reconciliationId"`
+	Reconciled       bool       `json:"reconciled"`
+
+	CreatedAt  time.Time  `json:"createdAt"`
+	UpdatedAt  time.Time  `json:"updatedAt"`
+	ArchivedAt *time.Time `json:"archivedAt"`
+
+	// Adjustment transactions correct the account balance, they are not income or expenses
+	Adjustment bool `json:"adjustment"`
+	// TransferID links the two sides of a transfer between accounts, which is not income or expense either
+	TransferID *uuid.UUID `json:"transferId"`
+}
+
+// EffectiveDate is the day the transaction counts in the account balance
+func (t *Transaction) EffectiveDate() time.Time {
+	if t.EffectuatedDate != nil {
+		return *t.EffectuatedDate
+	}
+	return t.Date
 }
 
 type TransactionView struct {
@@ -120,6 +249,17 @@ type TransactionView struct {
 	Description            string          `json:"description"`
 	Amount                 float64         `json:"amount"`
 	Fulfilled              bool            `json:"fulfilled"`
+	Adjustment             bool            `json:"adjustment"`
+	TransferID             *uuid.UUID      `json:"transferId"`
+}
+
+// TransactionFilter narrows the transactions listed, nil fields don't filter
+type TransactionFilter struct {
+	AccountID    *uuid.UUID
+	CreditCardID *uuid.UUID
+	CategoryID   *uuid.UUID
+	// WorkspaceID keeps the transactions of the workspace accounts
+	WorkspaceID *uuid.UUID
 }
 
 type RecurringTransaction struct {
@@ -135,5 +275,572 @@ type RecurringTransaction struct {
 	Archived        bool            `json:"archived"`
 	CreatedAt       time.Time       `json:"createdAt"`
 
+	UpdatedAt  time.Time  `json:"updatedAt"`
+	ArchivedAt *time.Time `json:"archivedAt"`
+}
+
+type CSVSignConvention string
+
+const (
+	// negative amounts are debits, as in bank account exports
+	CSVSignNegativeDebit CSVSignConvention = "negativeDebit"
+	// positive amounts are debits, as in most card exports
+	CSVSignPositiveDebit CSVSignConvention = "positiveDebit"
+)
+
+type CSVEncoding string
+
+const (
+	CSVEncodingUTF8        CSVEncoding = "utf-8"
+	CSVEncodingWindows1252 CSVEncoding = "windows-1252"
+	CSVEncodingISO88591    CSVEncoding = "iso-8859-1"
+)
+
+// CSVProfile describes how the columns of a csv export map to a transaction
+type CSVProfile struct {
+	ID                uuid.UUID         `json:"id"`
+	Name              string            `json:"name"`
+	Delimiter         string            `json:"delimiter"`
+	Encoding          CSVEncoding       `json:"encoding"`
+	SkipRows          int               `json:"skipRows"`
+	DateColumn        int               `json:"dateColumn"`
+	DateFormat        string            `json:"dateFormat"`
+	DescriptionColumn int               `json:"descriptionColumn"`
+	AmountColumn      int               `json:"amountColumn"`
+	CategoryColumn    *int              `json:"categoryColumn"`
+	DecimalComma      bool              `json:"decimalComma"`
+	SignConvention    CSVSignConvention `json:"signConvention"`
+	AccountID         uuid.UUID         `json:"accountId"`
+	CreditCardID      *uuid.UUID        `json:"creditCardId"`
+	CategoryID        uuid.UUID         `json:"categoryId"`
+	CreatedAt         time.Time         `json:"createdAt"`
+	UpdatedAt         time.Time         `json:"updatedAt"`
+	WorkspaceID       uuid.UUID         `json:"workspaceId"`
+}
+
+// ImportFingerprint links an imported row to the transaction it created or matched
+type ImportFingerprint struct {
+	Fingerprint   string    `json:"fingerprint"`
+	Source        string    `json:"source"`
+	Row           int       `json:"row"`
+	TransactionID uuid.UUID `json:"transactionId"`
+	CreatedAt     time.Time `json:"createdAt"`
+}
+
+type ImportFormat string
+
+const (
+	ImportFormatXlsx ImportFormat = "xlsx"
+	ImportFormatCSV  ImportFormat = "csv"
+	ImportFormatOFX  ImportFormat = "ofx"
+)
+
+type ImportJobStatus string
+
+const (
+	ImportJobStatusPending   ImportJobStatus = "pending"
+	ImportJobStatusRunning   ImportJobStatus = "running"
+	ImportJobStatusCompleted ImportJobStatus = "completed"
+	ImportJobStatusFailed    ImportJobStatus = "failed"
+	ImportJobStatusReverted  ImportJobStatus = "reverted"
+)
+
+type ImportRowError struct {
+	File  string `json:"file"`
+	Row   int    `json:"row"`
+	Error string `json:"error"`
+}
+
+// ImportJob is an uploaded file imported in background
+type ImportJob struct {
+	ID             uuid.UUID         `json:"id"`
+	Format         ImportFormat      `json:"format"`
+	FileName       string            `json:"fileName"`
+	Status         ImportJobStatus   `json:"status"`
+	DryRun         bool              `json:"dryRun"`
+	TotalRows      int               `json:"totalRows"`
+	ProcessedRows  int               `json:"processedRows"`
+	Created        int               `json:"created"`
+	Matched        int               `json:"matched"`
+	Skipped        int               `json:"skipped"`
+	Ignored        int               `json:"ignored"`
+	Errors         []*ImportRowError `json:"errors"`
+	TransactionIDs []uuid.UUID       `json:"transactionIds"`
+	Error          string            `json:"error"`
+	CreatedAt      time.Time         `json:"createdAt"`
+	UpdatedAt      time.Time         `json:"updatedAt"`
+	FinishedAt     *time.Time        `json:"finishedAt"`
+	WorkspaceID    uuid.UUID         `json:"workspaceId"`
+}
+
+// Backup is a portable dump of the budget data. Sessions, users and import
+// history are not part of it.
+type Backup struct {
+	Version               int                     `json:"version"`
+	CreatedAt             time.Time               `json:"createdAt"`
+	Accounts              []*Account              `json:"accounts"`
+	Categories            []*Category             `json:"categories"`
+	CreditCards           []*CreditCard           `json:"creditCards"`
+	RecurringTransactions []*RecurringTransaction `json:"recurringTransactions"`
+	Transactions          []*Transaction          `json:"transactions"`
+	CSVProfiles           []*CSVProfile           `json:"csvProfiles"`
+	Reconciliations       []*Reconciliation       `json:"reconciliations"`
+	Scenarios             []*Scenario             `json:"scenarios"`
+	Holdings              []*Holding              `json:"holdings"`
+	HoldingPrices         []*HoldingPrice         `json:"holdingPrices"`
+	HoldingMovements      []*HoldingMovement      `json:"holdingMovements"`
+	Loans                 []*Loan                 `json:"loans"`
+	LoanInstallments      []*LoanInstallment      `json:"loanInstallments"`
+	Workspaces            []*Workspace            `json:"workspaces"`
+	WorkspaceMembers      []*WorkspaceMember      `json:"workspaceMembers"`
+}
+
+type ReconciliationStatus string
+
+const (
+	ReconciliationStatusOpen      ReconciliationStatus = "open"
+	ReconciliationStatusCompleted ReconciliationStatus = "completed"
+)
+
+// Reconciliation checks an account against a bank statement closing balance
+type Reconciliation struct {
+	ID               uuid.UUID            `json:"id"`
+	AccountID        uuid.UUID            `json:"accountId"`
+	StatementDate    time.Time            `json:"statementDate"`
+	StatementBalance float32              `json:"statementBalance"`
+	Status           ReconciliationStatus `json:"status"`
+	CreatedAt        time.Time            `json:"createdAt"`
+	UpdatedAt        time.Time            `json:"updatedAt"`
+	CompletedAt      *time.Time           `json:"completedAt"`
+}
+
+type ReconciliationView struct {
+	*Reconciliation
+	// ClearedBalance sums the transactions reconciled before and the ones cleared in this reconciliation
+	ClearedBalance float32 `json:"clearedBalance"`
+	// Difference is what is missing for the cleared balance to match the statement balance
+	Difference float32        `json:"difference"`
+	Cleared    []*Transaction `json:"cleared"`
+	Uncleared  []*Transaction `json:"uncleared"`
+}
+
+type BalanceInterval string
+
+const (
+	BalanceIntervalDay   BalanceInterval = "day"
+	BalanceIntervalWeek  BalanceInterval = "week"
+	BalanceIntervalMonth BalanceInterval = "month"
+)
+
+// BalancePoint is the balance at the end of an interval
+type BalancePoint struct {
+	Date    time.Time `json:"date"`
+	Balance float32   `json:"balance"`
+}
+
+type BalanceHistory struct {
+	// AccountID is nil for the history of all the accounts together
+	AccountID *uuid.UUID      `json:"accountId"`
+	Interval  BalanceInterval `json:"interval"`
+	Points    []*BalancePoint `json:"points"`
+}
+
+type ScenarioItemKind string
+
+const (
+	// a hypothetical one-off transaction
+	ScenarioItemTransaction ScenarioItemKind = "transaction"
+	// a hypothetical monthly series
+	ScenarioItemRecurring ScenarioItemKind = "recurring"
+	// drops an existing unfulfilled transaction
+	ScenarioItemCancelTransaction ScenarioItemKind = "cancelTransaction"
+	// drops the occurrences of an existing recurring series from Date on
+	ScenarioItemCancelRecurring ScenarioItemKind = "cancelRecurring"
+)
+
+type ScenarioItem struct {
+	Kind                   ScenarioItemKind `json:"kind"`
+	AccountID              *uuid.UUID       `json:"accountId,omitempty"`
+	CreditCardID           *uuid.UUID       `json:"creditCardId,omitempty"`
+	TransactionID          *uuid.UUID       `json:"transactionId,omitempty"`
+	RecurringTransactionID *uuid.UUID       `json:"recurringTransactionId,omitempty"`
+	TransactionType        TransactionType  `json:"transactionType,omitempty"`
+	Description            string           `json:"description,omitempty"`
+	Amount                 float32          `json:"amount,omitempty"`
+	// Date of a transaction, or the first month of a recurring series or cancellation
+	Date *time.Time `json:"date,omitempty"`
+	// Day of the month of a recurring series
+	Day int `json:"day,omitempty"`
+	// EndDate optionally ends a recurring series
+	EndDate *time.Time `json:"endDate,omitempty"`
+}
+
+// Scenario holds hypothetical changes simulated on top of the forecast, it never touches real data
+type Scenario struct {
+	ID          uuid.UUID       `json:"id"`
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	Items       []*ScenarioItem `json:"items"`
+	CreatedAt   time.Time       `json:"createdAt"`
+	UpdatedAt   time.Time       `json:"updatedAt"`
+	WorkspaceID uuid.UUID       `json:"workspaceId"`
+}
+
+type MonthlyTotal struct {
+	Month  time.Time `json:"month"`
+	Credit float64   `json:"credit"`
+	Debit  float64   `json:"debit"`
+	Net    float64   `json:"net"`
+	// SavingsRate is the share of the credits left after the debits, nil in months without credits
+	SavingsRate *float64 `json:"savingsRate"`
+}
+
+type MonthlyAmount struct {
+	Month time.Time `json:"month"`
+	Total float64   `json:"total"`
+}
+
+// CategoryReport is the monthly series of a category, months without transactions count as zero in the statistics
+type CategoryReport struct {
+	CategoryID      uuid.UUID        `json:"categoryId"`
+	Category        string           `json:"category"`
+	TransactionType TransactionType  `json:"transactionType"`
+	Series          []*MonthlyAmount `json:"series"`
+	Total           float64          `json:"total"`
+	Average         float64          `json:"average"`
+	Median          float64          `json:"median"`
+	Min             float64          `json:"min"`
+	Max             float64          `json:"max"`
+}
+
+type MonthlyReport struct {
+	From       time.Time         `json:"from"`
+	To         time.Time         `json:"to"`
+	Months     []*MonthlyTotal   `json:"months"`
+	Categories []*CategoryReport `json:"categories"`
+}
+
+// NetWorthTotals is the wealth at a date: the account balances minus what is owed on credit cards and loans
+type NetWorthTotals struct {
+	Accounts float64 `json:"accounts"`
+	// CreditCardStatements are the unpaid card transactions due up to the next statement
+	CreditCardStatements float64 `json:"creditCardStatements"`
+	// Installments are the unpaid card transactions due after the next statement
+	Installments float64 `json:"installments"`
+	// InvestmentGains is the market value of the holdings over what the investment accounts hold,
+	// their unrealized return at the latest price plus the realized return of redemptions
+	InvestmentGains float64 `json:"investmentGains"`
+	// Loans is the principal of the loans taken up to the date not yet amortized by then
+	Loans    float64 `json:"loans"`
+	NetWorth float64 `json:"netWorth"`
+}
+
+type NetWorthByAccountType struct {
+	AccountType AccountType `json:"accountType"`
+	NetWorthTotals
+}
+
+type NetWorthPoint struct {
+	Date time.Time `json:"date"`
+	NetWorthTotals
+	ByAccountType []*NetWorthByAccountType `json:"byAccountType"`
+}
+
+type NetWorthReport struct {
+	Current *NetWorthPoint   `json:"current"`
+	Series  []*NetWorthPoint `json:"series"`
+}
+
+type AuditEntity string
+
+const (
+	AuditEntityTransaction          AuditEntity = "transaction"
+	AuditEntityAccount              AuditEntity = "account"
+	AuditEntityCategory             AuditEntity = "category"
+	AuditEntityCreditCard           AuditEntity = "creditCard"
+	AuditEntityRecurringTransaction AuditEntity = "recurringTransaction"
+	AuditEntityHolding              AuditEntity = "holding"
+	AuditEntityLoan                 AuditEntity = "loan"
+	AuditEntityReconciliation       AuditEntity = "reconciliation"
+)
+
+type AuditAction string
+
+const (
+	AuditActionCreate    AuditAction = "create"
+	AuditActionUpdate    AuditAction = "update"
+	AuditActionArchive   AuditAction = "archive"
+	AuditActionUnarchive AuditAction = "unarchive"
+	AuditActionDelete    AuditAction = "delete"
+	// a change of the stored account balance caused by a transaction
+	AuditActionBalance AuditAction = "balance"
+)
+
+// AuditEntry records a change of an entity, Before is null on creation and After on deletion
+type AuditEntry struct {
+	ID uuid.UUID `json:"id"`
+	// UserID is nil for changes made outside a session, like the command line
+	UserID *uuid.UUID `json:"userId"`
+	// RequestID groups the changes made by the same request
+	RequestID *uuid.UUID      `json:"requestId"`
+	Entity    AuditEntity     `json:"entity"`
+	EntityID  uuid.UUID       `json:"entityId"`
+	Action    AuditAction     `json:"action"`
+	Before    json.RawMessage `json:"before"`
+	After     json.RawMessage `json:"after"`
+	CreatedAt time.Time       `json:"createdAt"`
+}
+
+// TrashItem is an archived record that can still be restored
+type TrashItem struct {
+	Entity      AuditEntity `json:"entity"`
+	ID          uuid.UUID   `json:"id"`
+	Description string      `json:"description"`
+	// Amount and Date are only set for transactions and recurring transactions
+	Amount     *float32   `json:"amount"`
+	Date       *time.Time `json:"date"`
+	ArchivedAt time.Time  `json:"archivedAt"`
+}
+
+type AssetType string
+
+const (
+	AssetTypeCDB      AssetType = "cdb"
+	AssetTypeTreasury AssetType = "treasury"
+	AssetTypeFund     AssetType = "fund"
+	AssetTypeStock    AssetType = "stock"
+	AssetTypeOther    AssetType = "other"
+)
+
+func (at AssetType) Valid() bool {
+	switch at {
+	case AssetTypeCDB, AssetTypeTreasury, AssetTypeFund, AssetTypeStock, AssetTypeOther:
+		return true
+	}
+	return false
+}
+
+// Holding is an asset held in an investment account. Quantity and CostBasis are snapshots kept
+// up to date by the contributions and redemptions, CostBasis being the total paid for the quantity.
+type Holding struct {
+	ID        uuid.UUID `json:"id"`
+	AccountID uuid.UUID `json:"accountId"`
+	Asset     string    `json:"asset"`
+	AssetType AssetType `json:"assetType"`
+	Quantity  float64   `json:"quantity"`
+	CostBasis float64   `json:"costBasis"`
+	CreatedAt time.Time `json:"createdAt"`
 	UpdatedAt time.Time `json:"updatedAt"`
 }
+
+type HoldingPriceSource string
+
+const (
+	HoldingPriceSourceManual HoldingPriceSource = "manual"
+	HoldingPriceSourceImport HoldingPriceSource = "import"
+)
+
+// HoldingPrice is the unit price of a holding on a date, there is at most one per day
+type HoldingPrice struct {
+	ID        uuid.UUID          `json:"id"`
+	HoldingID uuid.UUID          `json:"holdingId"`
+	Date      time.Time          `json:"date"`
+	Price     float64            `json:"price"`
+	Source    HoldingPriceSource `json:"source"`
+	CreatedAt time.Time          `json:"createdAt"`
+}
+
+type HoldingMovementKind string
+
+const (
+	// money moved from an account into the holding
+	HoldingMovementContribution HoldingMovementKind = "contribution"
+	// money moved from the holding back to an account
+	HoldingMovementRedemption HoldingMovementKind = "redemption"
+)
+
+// HoldingMovement is a contribution or redemption, made as a transfer whose two
+// transactions share TransferID. The opening position of a holding has no transfer.
+type HoldingMovement struct {
+	ID         uuid.UUID           `json:"id"`
+	HoldingID  uuid.UUID           `json:"holdingId"`
+	TransferID *uuid.UUID          `json:"transferId"`
+	Kind       HoldingMovementKind `json:"kind"`
+	Date       time.Time           `json:"date"`
+	Amount     float64             `json:"amount"`
+	Quantity   float64             `json:"quantity"`
+	// CostBasis is the cost added by a contribution or removed by a redemption
+	CostBasis float64   `json:"costBasis"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Position values a holding at its latest price, holdings without prices are valued at cost
+type Position struct {
+	*Holding
+	Price     *float64   `json:"price"`
+	PriceDate *time.Time `json:"priceDate"`
+	Value     float64    `json:"value"`
+	Return    float64    `json:"return"`
+	// ReturnPercent is nil when there is no cost basis
+	ReturnPercent *float64 `json:"returnPercent"`
+	// Realized is the return of the redemptions, what was redeemed over its cost basis
+	Realized float64 `json:"realized"`
+}
+
+type AmortizationSystem string
+
+const (
+	// SAC amortizes the same principal every month, the payments decrease with the interest
+	AmortizationSAC AmortizationSystem = "sac"
+	// Price (french table) pays the same amount every month
+	AmortizationPrice AmortizationSystem = "price"
+)
+
+func (as AmortizationSystem) Valid() bool {
+	return as == AmortizationSAC || as == AmortizationPrice
+}
+
+// Loan is a financing paid in monthly installments from AccountID. Rate is the effective
+// annual rate in percent and StartDate the due date of the first installment.
+type Loan struct {
+	ID          uuid.UUID          `json:"id"`
+	Description string             `json:"description"`
+	AccountID   uuid.UUID          `json:"accountId"`
+	CategoryID  uuid.UUID          `json:"categoryId"`
+	Principal   float64            `json:"principal"`
+	Rate        float64            `json:"rate"`
+	Term        int                `json:"term"`
+	System      AmortizationSystem `json:"system"`
+	StartDate   time.Time          `json:"startDate"`
+	CreatedAt   time.Time          `json:"createdAt"`
+	UpdatedAt   time.Time          `json:"updatedAt"`
+	// Outstanding is the principal not yet amortized by fulfilled installments and extra payments
+	Outstanding float64 `json:"outstanding"`
+}
+
+// LoanInstallment is a row of the loan schedule, paid through its planned debit transaction.
+// Extra payments are kept as installments amortizing their whole amount.
+type LoanInstallment struct {
+	ID            uuid.UUID `json:"id"`
+	LoanID        uuid.UUID `json:"loanId"`
+	TransactionID uuid.UUID `json:"transactionId"`
+	Number        int       `json:"number"`
+	DueDate       time.Time `json:"dueDate"`
+	Payment       float64   `json:"payment"`
+	Interest      float64   `json:"interest"`
+	Amortization  float64   `json:"amortization"`
+	Balance       float64   `json:"balance"`
+	Extra         bool      `json:"extra"`
+	CreatedAt     time.Time `json:"createdAt"`
+	// Paid is set when the transaction is fulfilled and not archived
+	Paid bool `json:"paid"`
+}
+
+type LoanSchedule struct {
+	*Loan
+	Installments []*LoanInstallment `json:"installments"`
+}
+
+type PayoffStrategy string
+
+const (
+	// snowball pays off the smallest balance first
+	PayoffSnowball PayoffStrategy = "snowball"
+	// avalanche pays off the highest rate first
+	PayoffAvalanche PayoffStrategy = "avalanche"
+)
+
+func (ps PayoffStrategy) Valid() bool {
+	return ps == PayoffSnowball || ps == PayoffAvalanche
+}
+
+type PayoffDebtKind string
+
+const (
+	PayoffDebtLoan       PayoffDebtKind = "loan"
+	PayoffDebtCreditCard PayoffDebtKind = "creditCard"
+)
+
+// PayoffDebt is a loan or card balance to pay off, Rate is its effective annual rate in percent
+type PayoffDebt struct {
+	ID             uuid.UUID      `json:"id"`
+	Kind           PayoffDebtKind `json:"kind"`
+	Description    string         `json:"description"`
+	Balance        float64        `json:"balance"`
+	Rate           float64        `json:"rate"`
+	MinimumPayment float64        `json:"minimumPayment"`
+}
+
+// PayoffPayment is what a debt receives in a month, Extra being the part above its minimum payment
+type PayoffPayment struct {
+	DebtID   uuid.UUID `json:"debtId"`
+	Payment  float64   `json:"payment"`
+	Extra    float64   `json:"extra"`
+	Interest float64   `json:"interest"`
+	Balance  float64   `json:"balance"`
+}
+
+type PayoffMonth struct {
+	Date     time.Time        `json:"date"`
+	Payments []*PayoffPayment `json:"payments"`
+	Total    float64          `json:"total"`
+}
+
+type PayoffDebtResult struct {
+	*PayoffDebt
+	PayoffDate time.Time `json:"payoffDate"`
+	Interest   float64   `json:"interest"`
+}
+
+type PayoffPlan struct {
+	Strategy      PayoffStrategy      `json:"strategy"`
+	Debts         []*PayoffDebtResult `json:"debts"`
+	Months        []*PayoffMonth      `json:"months"`
+	TotalInterest float64             `json:"totalInterest"`
+	TotalPaid     float64             `json:"totalPaid"`
+	PayoffDate    *time.Time          `json:"payoffDate"`
+}
+
+type WorkspaceRole string
+
+const (
+	// owners manage the members besides editing
+	WorkspaceRoleOwner  WorkspaceRole = "owner"
+	WorkspaceRoleEditor WorkspaceRole = "editor"
+	WorkspaceRoleViewer WorkspaceRole = "viewer"
+)
+
+func (wr WorkspaceRole) Valid() bool {
+	return wr == WorkspaceRoleOwner || wr == WorkspaceRoleEditor || wr == WorkspaceRoleViewer
+}
+
+// Allows tells whether the role has at least the permissions of the required role
+func (wr WorkspaceRole) Allows(required WorkspaceRole) bool {
+	rank := map[WorkspaceRole]int{WorkspaceRoleViewer: 1, WorkspaceRoleEditor: 2, WorkspaceRoleOwner: 3}
+	return rank[wr] >= rank[required]
+}
+
+// Workspace groups the accounts, categories and cards shared by its members
+type Workspace struct {
+	ID        uuid.UUID `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// WorkspaceMember is invited by email, UserID is nil until a user with that email signs up
+type WorkspaceMember struct {
+	ID          uuid.UUID     `json:"id"`
+	WorkspaceID uuid.UUID     `json:"workspaceId"`
+	UserID      *uuid.UUID    `json:"userId"`
+	Email       string        `json:"email"`
+	Role        WorkspaceRole `json:"role"`
+	CreatedAt   time.Time     `json:"createdAt"`
+	UpdatedAt   time.Time     `json:"updatedAt"`
+}
+
+// UserWorkspace is a workspace with the role of the user in it
+type UserWorkspace struct {
+	*Workspace
+	Role WorkspaceRole `json:"role"`
+}
@@ -32,12 +32,27 @@ func main() {
 	if portString == "" {
 		log.Fatal("PORT is not found in the environment")
 	}
-	if len(os.Args) > 2 {
-		importData := os.Args[1]
-		if ok, _ := strconv.ParseBool(importData); ok && os.Args[2] != "" {
-			path := os.Args[2]
-			cmd.ImportData(path, store)
-
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "ofx":
+			cmd.ImportOFX(os.Args[2:], store)
+		case "xlsx":
+			cmd.ImportXlsx(os.Args[2:], store)
+		case "backup":
+			cmd.Backup(os.Args[2:], store)
+		case "restore":
+			cmd.Restore(os.Args[2:], store)
+		case "repair-balances":
+			cmd.RepairBalances(os.Args[2:], store)
+		default:
+			importData := os.Args[1]
+			if ok, _ := strconv.ParseBool(importData); ok && len(os.Args) > 2 && os.Args[2] != "" {
+				if len(os.Args) < 4 {
+					log.Fatal("usage: true <path> <workspace id>")
+				}
+				path := os.Args[2]
+				cmd.ImportDefaultXlsx(path, os.Args[3], store)
+			}
 		}
 	} else {
 		server := apiserver.NewServer(fmt.Sprintf(":%s", portString), store)